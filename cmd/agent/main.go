@@ -3,10 +3,27 @@ package main
 import (
 	"github.com/Guram-Gurych/metricserver.git/internal/agent"
 	"github.com/Guram-Gurych/metricserver.git/internal/config"
+	"log"
+	"net/http"
 )
 
 func main() {
 	cnfg := config.InitConfigAgent()
 	a := agent.NewAgent(cnfg.ServerAddress, cnfg.PollInterval, cnfg.ReportInterval)
+	a.SetWriteToken(cnfg.WriteToken)
+	a.SetSigningKey(cnfg.SigningKey)
+
+	if cnfg.AgentMode != "push" {
+		go func() {
+			log.Printf("Метрики доступны для опроса на %s/metrics", cnfg.AgentListen)
+			if err := http.ListenAndServe(cnfg.AgentListen, a.Handler()); err != nil {
+				log.Fatalf("Ошибка pull-сервера: %v", err)
+			}
+		}()
+	}
+	if cnfg.AgentMode == "pull" {
+		a.DisablePush()
+	}
+
 	a.Run()
 }
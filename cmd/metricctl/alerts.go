@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Guram-Gurych/metricserver.git/internal/model"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// alert - одна сработавшая проверка.
+type alert struct {
+	Kind    string `json:"kind"`
+	Subject string `json:"subject"`
+	Detail  string `json:"detail"`
+}
+
+// rule - порог вида name>value; name может быть шаблоном path.Match.
+type rule struct {
+	raw       string
+	pattern   string
+	op        string
+	threshold float64
+}
+
+// Двухсимвольные операторы идут первыми, чтобы ">=" не разобрался как ">".
+var ruleOps = []string{">=", "<=", "==", "!=", ">", "<"}
+
+func parseRule(raw string) (rule, error) {
+	for _, op := range ruleOps {
+		name, value, ok := strings.Cut(raw, op)
+		if !ok {
+			continue
+		}
+		name = strings.TrimSpace(name)
+		threshold, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || name == "" {
+			break
+		}
+		if _, err := path.Match(name, ""); err != nil {
+			break
+		}
+		return rule{raw: raw, pattern: name, op: op, threshold: threshold}, nil
+	}
+
+	return rule{}, fmt.Errorf("invalid rule %q, want name>value with one of %s", raw, strings.Join(ruleOps, " "))
+}
+
+func (r rule) fires(value float64) bool {
+	switch r.op {
+	case ">":
+		return value > r.threshold
+	case ">=":
+		return value >= r.threshold
+	case "<":
+		return value < r.threshold
+	case "<=":
+		return value <= r.threshold
+	case "==":
+		return value == r.threshold
+	}
+	return value != r.threshold
+}
+
+type ruleFlags []rule
+
+func (f *ruleFlags) String() string {
+	return fmt.Sprint(len(*f))
+}
+
+func (f *ruleFlags) Set(raw string) error {
+	r, err := parseRule(raw)
+	if err != nil {
+		return err
+	}
+	*f = append(*f, r)
+	return nil
+}
+
+// runAlerts собирает то, что сервер уже знает о проблемах: устаревшие метрики (при -ttl)
+// и недоступные цели pull-режима, и проверяет пороги из -rule. Если что-то сработало,
+// команда завершается с exitAlerts, чтобы её можно было звать из cron или healthcheck.
+func runAlerts(ctx context.Context, env *cmdEnv, args []string) error {
+	fs := newFlags("alerts", env)
+	var rules ruleFlags
+	fs.Var(&rules, "rule", "Threshold rule name>value (also >=, <, <=, ==, !=); name may be a glob; repeatable")
+	noStale := fs.Bool("no-stale", false, "Do not report stale metrics")
+	noTargets := fs.Bool("no-targets", false, "Do not report down scrape targets")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return errUsage
+	}
+
+	metrics, err := listMetrics(ctx, env.client, url.Values{}, 0)
+	if err != nil {
+		return err
+	}
+
+	alerts := make([]alert, 0)
+	for _, m := range metrics {
+		subject := m.MType + "/" + m.ID
+		if m.Stale && !*noStale {
+			alerts = append(alerts, alert{Kind: "stale", Subject: subject, Detail: "not updated within the server TTL"})
+		}
+
+		value, ok := numericValue(m)
+		if !ok {
+			continue
+		}
+		for _, r := range rules {
+			if matched, _ := path.Match(r.pattern, m.ID); matched && r.fires(value) {
+				alerts = append(alerts, alert{Kind: "threshold", Subject: subject, Detail: fmt.Sprintf("%s (value %s)", r.raw, formatFloat(value))})
+			}
+		}
+	}
+
+	if !*noTargets {
+		targets, err := downTargets(ctx, env.client)
+		if err != nil {
+			return err
+		}
+		alerts = append(alerts, targets...)
+	}
+
+	if err := printAlerts(env, alerts); err != nil {
+		return err
+	}
+	if len(alerts) > 0 {
+		return errAlertsFiring
+	}
+
+	return nil
+}
+
+// downTargets читает /api/v1/targets; если pull-режим на сервере выключен, целей нет.
+func downTargets(ctx context.Context, c *client) ([]alert, error) {
+	var targets []struct {
+		URL                 string `json:"url"`
+		Up                  bool   `json:"up"`
+		LastError           string `json:"last_error"`
+		ConsecutiveFailures int    `json:"consecutive_failures"`
+	}
+	err := c.doJSON(ctx, http.MethodGet, "/api/v1/targets", nil, nil, &targets, authNone)
+	var statusErr *statusError
+	if errors.As(err, &statusErr) && statusErr.code == http.StatusNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var alerts []alert
+	for _, t := range targets {
+		// До первого опроса цель ещё не проверена и не считается упавшей.
+		if t.Up || t.ConsecutiveFailures == 0 {
+			continue
+		}
+		detail := fmt.Sprintf("%d failed scrapes", t.ConsecutiveFailures)
+		if t.LastError != "" {
+			detail += ": " + t.LastError
+		}
+		alerts = append(alerts, alert{Kind: "target_down", Subject: t.URL, Detail: detail})
+	}
+
+	return alerts, nil
+}
+
+// numericValue - значение, с которым сравниваются пороги: квантиль для summary,
+// число уникальных элементов для set.
+func numericValue(m models.Metrics) (float64, bool) {
+	switch m.MType {
+	case models.Gauge, models.Summary:
+		if m.Value != nil {
+			return *m.Value, true
+		}
+	case models.Counter, models.Set:
+		if m.Delta != nil {
+			return float64(*m.Delta), true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/Guram-Gurych/metricserver.git/internal/middleware"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+type clientOptions struct {
+	address    string
+	writeToken string
+	adminToken string
+	key        string
+	timeout    time.Duration
+}
+
+// client - тонкая обёртка над HTTP API сервера.
+type client struct {
+	base    string
+	opts    clientOptions
+	httpCli *http.Client
+}
+
+// auth - какой токен нужен запросу.
+type auth int
+
+const (
+	authNone auth = iota
+	authWrite
+	authAdmin
+)
+
+// statusError - ответ сервера с кодом, отличным от 2xx.
+type statusError struct {
+	code int
+	body string
+}
+
+func (e *statusError) Error() string {
+	if e.body == "" {
+		return fmt.Sprintf("server responded %d %s", e.code, http.StatusText(e.code))
+	}
+	return fmt.Sprintf("server responded %d: %s", e.code, e.body)
+}
+
+func newClient(opts clientOptions) (*client, error) {
+	addr := opts.address
+	if !strings.Contains(addr, "://") {
+		addr = "http://" + addr
+	}
+	u, err := url.Parse(addr)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("invalid server address %q", opts.address)
+	}
+
+	return &client{base: strings.TrimSuffix(u.String(), "/"), opts: opts, httpCli: &http.Client{}}, nil
+}
+
+// newRequest собирает запрос с токеном и, для тела записи, подписью.
+func (c *client) newRequest(ctx context.Context, method, path string, query url.Values, body []byte, a auth) (*http.Request, error) {
+	target := c.base + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	switch a {
+	case authWrite:
+		if c.opts.writeToken != "" {
+			req.Header.Set("Authorization", "Bearer "+c.opts.writeToken)
+		}
+		if c.opts.key != "" && body != nil {
+			req.Header.Set(middleware.SignatureHeader, hex.EncodeToString(middleware.Sign([]byte(c.opts.key), body)))
+		}
+	case authAdmin:
+		if c.opts.adminToken != "" {
+			req.Header.Set("Authorization", "Bearer "+c.opts.adminToken)
+		}
+	}
+
+	return req, nil
+}
+
+// do выполняет запрос с таймаутом и возвращает тело успешного ответа.
+func (c *client) do(ctx context.Context, method, path string, query url.Values, body []byte, a auth) ([]byte, error) {
+	if c.opts.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.opts.timeout)
+		defer cancel()
+	}
+
+	req, err := c.newRequest(ctx, method, path, query, body, a)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.httpCli.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, &statusError{code: resp.StatusCode, body: strings.TrimSpace(string(data))}
+	}
+
+	return data, nil
+}
+
+func (c *client) doJSON(ctx context.Context, method, path string, query url.Values, in, out any, a auth) error {
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return err
+		}
+	}
+
+	data, err := c.do(ctx, method, path, query, body, a)
+	if err != nil {
+		return err
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+
+	return nil
+}
+
+// stream открывает долгий запрос без таймаута; закрыть тело должен вызывающий.
+func (c *client) stream(ctx context.Context, path string, query url.Values) (io.ReadCloser, error) {
+	req, err := c.newRequest(ctx, http.MethodGet, path, query, nil, authNone)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := c.httpCli.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, &statusError{code: resp.StatusCode, body: strings.TrimSpace(string(data))}
+	}
+
+	return resp.Body, nil
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/Guram-Gurych/metricserver.git/internal/model"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// newFlags создаёт набор флагов подкоманды; ошибки разбора печатает flag.
+func newFlags(name string, env *cmdEnv) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(env.stderr)
+	return fs
+}
+
+// parseFlags разбирает флаги подкоманды. Ошибку и справку flag уже напечатал,
+// поэтому наверх уходит flag.ErrHelp: run завершится с exitUsage без повтора сообщения.
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return flag.ErrHelp
+	}
+	return nil
+}
+
+func isMetricType(t string) bool {
+	switch t {
+	case models.Gauge, models.Counter, models.Summary, models.Set:
+		return true
+	}
+	return false
+}
+
+func runGet(ctx context.Context, env *cmdEnv, args []string) error {
+	fs := newFlags("get", env)
+	quantile := fs.Float64("q", -1, "Quantile for a summary, the server default if not set")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 || !isMetricType(fs.Arg(0)) {
+		return errUsage
+	}
+
+	req := models.Metrics{MType: fs.Arg(0), ID: fs.Arg(1)}
+	if *quantile >= 0 {
+		req.Quantile = quantile
+	}
+
+	var m models.Metrics
+	if err := env.client.doJSON(ctx, http.MethodPost, "/value/", nil, req, &m, authNone); err != nil {
+		return err
+	}
+
+	return printMetrics(env, []models.Metrics{m}, false)
+}
+
+func runList(ctx context.Context, env *cmdEnv, args []string) error {
+	fs := newFlags("list", env)
+	metricType := fs.String("type", "", "Only metrics of this type")
+	prefix := fs.String("prefix", "", "Only names with this prefix")
+	match := fs.String("match", "", "Only names matching this glob")
+	limit := fs.Int("limit", 0, "Stop after this many metrics, 0 for all")
+	quantile := fs.Float64("q", -1, "Quantile for summaries, the server default if not set")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 || *limit < 0 || (*metricType != "" && !isMetricType(*metricType)) {
+		return errUsage
+	}
+
+	query := url.Values{}
+	setQuery(query, "type", *metricType)
+	setQuery(query, "prefix", *prefix)
+	setQuery(query, "match", *match)
+	if *quantile >= 0 {
+		query.Set("q", strconv.FormatFloat(*quantile, 'f', -1, 64))
+	}
+
+	metrics, err := listMetrics(ctx, env.client, query, *limit)
+	if err != nil {
+		return err
+	}
+
+	return printMetrics(env, metrics, true)
+}
+
+// listMetrics проходит страницы /api/v1/metrics по next_cursor.
+func listMetrics(ctx context.Context, c *client, query url.Values, limit int) ([]models.Metrics, error) {
+	var metrics []models.Metrics
+	for {
+		var page struct {
+			Metrics    []models.Metrics `json:"metrics"`
+			NextCursor string           `json:"next_cursor"`
+		}
+		if err := c.doJSON(ctx, http.MethodGet, "/api/v1/metrics", query, nil, &page, authNone); err != nil {
+			return nil, err
+		}
+
+		metrics = append(metrics, page.Metrics...)
+		if limit > 0 && len(metrics) >= limit {
+			return metrics[:limit], nil
+		}
+		if page.NextCursor == "" {
+			return metrics, nil
+		}
+		query.Set("cursor", page.NextCursor)
+	}
+}
+
+func runPush(ctx context.Context, env *cmdEnv, args []string) error {
+	fs := newFlags("push", env)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() < 3 {
+		return errUsage
+	}
+
+	m := models.Metrics{MType: fs.Arg(0), ID: fs.Arg(1)}
+	raw := fs.Arg(2)
+	switch m.MType {
+	case models.Gauge, models.Summary:
+		value, err := strconv.ParseFloat(raw, 64)
+		if err != nil || fs.NArg() != 3 {
+			return fmt.Errorf("%w: invalid %s value %q", errUsage, m.MType, raw)
+		}
+		m.Value = &value
+	case models.Counter:
+		delta, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || fs.NArg() != 3 {
+			return fmt.Errorf("%w: invalid counter value %q", errUsage, raw)
+		}
+		m.Delta = &delta
+	case models.Set:
+		m.Members = fs.Args()[2:]
+	default:
+		return fmt.Errorf("%w: invalid metric type %q", errUsage, m.MType)
+	}
+
+	var updated models.Metrics
+	if err := env.client.doJSON(ctx, http.MethodPost, "/update/", nil, m, &updated, authWrite); err != nil {
+		return err
+	}
+
+	return printMetrics(env, []models.Metrics{updated}, false)
+}
+
+// streamEvent - событие /api/v1/stream; для lagged заполнено Dropped.
+type streamEvent struct {
+	Op      string          `json:"op"`
+	Metric  *models.Metrics `json:"metric,omitempty"`
+	Dropped uint64          `json:"dropped,omitempty"`
+}
+
+func runWatch(ctx context.Context, env *cmdEnv, args []string) error {
+	fs := newFlags("watch", env)
+	metricType := fs.String("type", "", "Only metrics of this type")
+	match := fs.String("match", "", "Only names matching this glob")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 || (*metricType != "" && !isMetricType(*metricType)) {
+		return errUsage
+	}
+
+	query := url.Values{}
+	setQuery(query, "type", *metricType)
+	setQuery(query, "match", *match)
+
+	body, err := env.client.stream(ctx, "/api/v1/stream", query)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	err = readSSE(body, func(data []byte) error {
+		var event streamEvent
+		if err := json.Unmarshal(data, &event); err != nil {
+			return fmt.Errorf("decode event: %w", err)
+		}
+		return printEvent(env, event, time.Now())
+	})
+	if ctx.Err() != nil {
+		// Прерывание пользователем - обычное завершение watch.
+		return nil
+	}
+	if err == nil {
+		err = errors.New("stream closed by server")
+	}
+
+	return err
+}
+
+// readSSE передаёт fn данные каждого события text/event-stream.
+func readSSE(r io.Reader, fn func(data []byte) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var data []byte
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			if len(data) > 0 {
+				if err := fn(data); err != nil {
+					return err
+				}
+				data = data[:0]
+			}
+			continue
+		}
+		if value, ok := strings.CutPrefix(line, "data:"); ok {
+			if len(data) > 0 {
+				data = append(data, '\n')
+			}
+			data = append(data, strings.TrimPrefix(value, " ")...)
+		}
+	}
+
+	return scanner.Err()
+}
+
+func runDelete(ctx context.Context, env *cmdEnv, args []string) error {
+	fs := newFlags("delete", env)
+	pattern := fs.String("pattern", "", "Delete all metrics whose names match this glob")
+	metricType := fs.String("type", "", "With -pattern, only metrics of this type")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *metricType != "" && !isMetricType(*metricType) {
+		return fmt.Errorf("%w: invalid metric type %q", errUsage, *metricType)
+	}
+
+	var resp struct {
+		Deleted int `json:"deleted"`
+	}
+	switch {
+	case *pattern != "" && fs.NArg() == 0:
+		query := url.Values{"pattern": {*pattern}}
+		setQuery(query, "type", *metricType)
+		if err := env.client.doJSON(ctx, http.MethodDelete, "/value/", query, nil, &resp, authAdmin); err != nil {
+			return err
+		}
+	case *pattern == "" && *metricType == "" && fs.NArg() == 2 && isMetricType(fs.Arg(0)):
+		path := "/value/" + url.PathEscape(fs.Arg(0)) + "/" + url.PathEscape(fs.Arg(1))
+		if _, err := env.client.do(ctx, http.MethodDelete, path, nil, nil, authAdmin); err != nil {
+			return err
+		}
+		resp.Deleted = 1
+	default:
+		return errUsage
+	}
+
+	if env.format == "json" {
+		return json.NewEncoder(env.stdout).Encode(resp)
+	}
+	_, err := fmt.Fprintf(env.stdout, "deleted %d\n", resp.Deleted)
+	return err
+}
+
+func runExport(ctx context.Context, env *cmdEnv, args []string) error {
+	fs := newFlags("export", env)
+	format := fs.String("format", "json", "Data format: json, csv or prometheus")
+	file := fs.String("file", "", "Write to this file instead of stdout")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return errUsage
+	}
+
+	data, err := env.client.do(ctx, http.MethodGet, "/admin/export", url.Values{"format": {*format}}, nil, authAdmin)
+	if err != nil {
+		return err
+	}
+
+	if *file != "" {
+		return os.WriteFile(*file, data, 0o644)
+	}
+	_, err = env.stdout.Write(data)
+	return err
+}
+
+func setQuery(query url.Values, key, value string) {
+	if value != "" {
+		query.Set(key, value)
+	}
+}
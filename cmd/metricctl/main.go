@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// Коды выхода для скриптов.
+const (
+	exitOK     = 0
+	exitError  = 1
+	exitUsage  = 2
+	exitAlerts = 3
+)
+
+// errUsage - неверные аргументы; run печатает справку и выходит с exitUsage.
+var errUsage = errors.New("invalid arguments")
+
+// errAlertsFiring возвращает alerts, если сработало хотя бы одно правило.
+var errAlertsFiring = errors.New("alerts firing")
+
+type command struct {
+	name    string
+	usage   string
+	summary string
+	run     func(ctx context.Context, env *cmdEnv, args []string) error
+}
+
+var commands = []command{
+	{"get", "get [-q quantile] <type> <name>", "Show the current value of a metric", runGet},
+	{"list", "list [-type T] [-prefix P] [-match GLOB] [-limit N] [-q quantile]", "List metrics, following pages", runList},
+	{"push", "push <type> <name> <value>...", "Send one update; a set takes one or more members", runPush},
+	{"watch", "watch [-type T] [-match GLOB]", "Print metric changes as they happen until interrupted", runWatch},
+	{"delete", "delete <type> <name> | delete -pattern GLOB [-type T]", "Delete metrics (admin token)", runDelete},
+	{"export", "export [-format json|csv|prometheus] [-file PATH]", "Dump all metrics (admin token)", runExport},
+	{"alerts", "alerts [-rule 'name>value']... [-no-stale] [-no-targets]", "Report stale metrics, down scrape targets and broken threshold rules", runAlerts},
+}
+
+// cmdEnv - общие для подкоманд клиент и вывод.
+type cmdEnv struct {
+	client *client
+	format string
+	stdout io.Writer
+	stderr io.Writer
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	code := run(ctx, os.Args[1:], os.Stdout, os.Stderr)
+	stop()
+	os.Exit(code)
+}
+
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("metricctl", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() { usage(fs, stderr) }
+
+	var opts clientOptions
+	var format string
+	fs.StringVar(&opts.address, "a", envOr("ADDRESS", "localhost:8080"), "Server address (host:port or URL)")
+	fs.StringVar(&opts.writeToken, "write-token", os.Getenv("WRITE_TOKEN"), "Bearer token for the write routes")
+	fs.StringVar(&opts.adminToken, "admin-token", os.Getenv("ADMIN_TOKEN"), "Bearer token for the admin API")
+	fs.StringVar(&opts.key, "k", os.Getenv("KEY"), "Key for the HashSHA256 signature of pushed metrics")
+	fs.DurationVar(&opts.timeout, "timeout", 10*time.Second, "Timeout of a single request; watch is not limited")
+	fs.StringVar(&format, "o", "table", "Output format: table or json")
+
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if format != "table" && format != "json" {
+		fmt.Fprintf(stderr, "metricctl: unknown output format %q\n", format)
+		return exitUsage
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	name := fs.Arg(0)
+	var cmd *command
+	for i := range commands {
+		if commands[i].name == name {
+			cmd = &commands[i]
+		}
+	}
+	if cmd == nil {
+		fmt.Fprintf(stderr, "metricctl: unknown command %q\n", name)
+		fs.Usage()
+		return exitUsage
+	}
+
+	c, err := newClient(opts)
+	if err != nil {
+		fmt.Fprintf(stderr, "metricctl: %v\n", err)
+		return exitUsage
+	}
+
+	err = cmd.run(ctx, &cmdEnv{client: c, format: format, stdout: stdout, stderr: stderr}, fs.Args()[1:])
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.Is(err, errAlertsFiring):
+		return exitAlerts
+	case errors.Is(err, flag.ErrHelp):
+		return exitUsage
+	case errors.Is(err, errUsage):
+		fmt.Fprintf(stderr, "%s: %v\nusage: metricctl %s\n", name, err, cmd.usage)
+		return exitUsage
+	}
+
+	fmt.Fprintf(stderr, "%s: %v\n", name, err)
+	return exitError
+}
+
+func usage(fs *flag.FlagSet, w io.Writer) {
+	fmt.Fprintln(w, "usage: metricctl [flags] <command> [args]")
+	fmt.Fprintln(w, "\nCommands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-8s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(w, "\nFlags:")
+	fs.PrintDefaults()
+	fmt.Fprintf(w, "\nExit codes: %d ok, %d error, %d usage, %d alerts firing.\n", exitOK, exitError, exitUsage, exitAlerts)
+}
+
+func envOr(name, def string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return def
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"github.com/Guram-Gurych/metricserver.git/internal/handler"
+	"github.com/Guram-Gurych/metricserver.git/internal/middleware"
+	"github.com/Guram-Gurych/metricserver.git/internal/repository"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newTestServer(t *testing.T) string {
+	h := handler.NewMetricHandler(repository.NewMemStorage(), nil)
+
+	r := chi.NewRouter()
+	r.Post("/value/", h.PostValue)
+	r.Get("/api/v1/metrics", h.ListMetrics)
+	r.Get("/api/v1/targets", h.Targets)
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.WriteAuth("write"))
+		r.Use(middleware.VerifySignature("key", 1<<20))
+		r.Post("/update/", h.Post)
+	})
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.AdminAuth("admin"))
+		r.Delete("/value/{metricType}/{metricName}", h.Delete)
+		r.Get("/admin/export", h.Export)
+	})
+
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+
+	return srv.URL
+}
+
+func TestRun(t *testing.T) {
+	addr := newTestServer(t)
+	flags := []string{"-a", addr, "-write-token", "write", "-admin-token", "admin", "-k", "key"}
+
+	// Шаги идут по порядку и работают с одним сервером.
+	steps := []struct {
+		name         string
+		args         []string
+		expectedCode int
+		contains     string
+	}{
+		{name: "push gauge", args: []string{"push", "gauge", "Alloc", "12.5"}, expectedCode: exitOK, contains: "12.5"},
+		{name: "push counter", args: []string{"push", "counter", "hits", "3"}, expectedCode: exitOK},
+		{name: "push с неверным значением", args: []string{"push", "counter", "hits", "1.5"}, expectedCode: exitUsage},
+		{name: "push с неверной подписью", args: []string{"-k", "wrong", "push", "gauge", "Alloc", "1"}, expectedCode: exitError},
+		{name: "get в JSON", args: []string{"-o", "json", "get", "gauge", "Alloc"}, expectedCode: exitOK, contains: `{"id":"Alloc","type":"gauge","value":12.5}`},
+		{name: "get несуществующей", args: []string{"get", "gauge", "missing"}, expectedCode: exitError},
+		{name: "list", args: []string{"list", "-type", "counter"}, expectedCode: exitOK, contains: "hits"},
+		{name: "alerts без правил", args: []string{"alerts"}, expectedCode: exitOK, contains: "no alerts"},
+		{name: "alerts с порогом", args: []string{"alerts", "-rule", "All*>10", "-rule", "hits>=100"}, expectedCode: exitAlerts, contains: "gauge/Alloc"},
+		{name: "alerts с неверным правилом", args: []string{"alerts", "-rule", "hits"}, expectedCode: exitUsage},
+		{name: "export", args: []string{"export", "-format", "prometheus"}, expectedCode: exitOK, contains: "hits 3"},
+		{name: "export без admin-токена", args: []string{"-admin-token", "", "export"}, expectedCode: exitError},
+		{name: "delete", args: []string{"delete", "counter", "hits"}, expectedCode: exitOK, contains: "deleted 1"},
+		{name: "Неизвестная команда", args: []string{"bogus"}, expectedCode: exitUsage},
+		{name: "Неизвестный формат вывода", args: []string{"-o", "yaml", "list"}, expectedCode: exitUsage},
+	}
+
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code := run(context.Background(), append(append([]string{}, flags...), step.args...), &stdout, &stderr)
+
+			require.Equal(t, step.expectedCode, code, "stdout: %s\nstderr: %s", stdout.String(), stderr.String())
+			assert.Contains(t, stdout.String(), step.contains)
+		})
+	}
+}
+
+func TestParseRule(t *testing.T) {
+	tests := []struct {
+		raw     string
+		value   float64
+		fires   bool
+		wantErr bool
+	}{
+		{raw: "Alloc>10", value: 11, fires: true},
+		{raw: "Alloc >= 10", value: 10, fires: true},
+		{raw: "Alloc<10", value: 10, fires: false},
+		{raw: "Alloc!=0", value: 0, fires: false},
+		{raw: "Alloc==1e3", value: 1000, fires: true},
+		{raw: "Alloc", wantErr: true},
+		{raw: ">10", wantErr: true},
+		{raw: "Alloc>ten", wantErr: true},
+		{raw: "[>1", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.raw, func(t *testing.T) {
+			r, err := parseRule(test.raw)
+			if test.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.fires, r.fires(test.value))
+		})
+	}
+}
+
+func TestReadSSE(t *testing.T) {
+	stream := ": keep-alive\n\nevent: update\ndata: {\"op\":\"update\"}\n\nevent: lagged\ndata: {\"op\":\"lagged\",\"dropped\":2}\n\n"
+
+	var events []string
+	err := readSSE(strings.NewReader(stream), func(data []byte) error {
+		events = append(events, string(data))
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{`{"op":"update"}`, `{"op":"lagged","dropped":2}`}, events, "Комментарии keep-alive должны пропускаться")
+}
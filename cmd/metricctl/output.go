@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/Guram-Gurych/metricserver.git/internal/model"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+// printMetrics печатает метрики таблицей или JSON; list в JSON всегда даёт массив.
+func printMetrics(env *cmdEnv, metrics []models.Metrics, asList bool) error {
+	if env.format == "json" {
+		if !asList && len(metrics) == 1 {
+			return json.NewEncoder(env.stdout).Encode(metrics[0])
+		}
+		if metrics == nil {
+			metrics = []models.Metrics{}
+		}
+		return json.NewEncoder(env.stdout).Encode(metrics)
+	}
+
+	tw := tabwriter.NewWriter(env.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TYPE\tNAME\tVALUE\tUPDATED\tSTALE")
+	for _, m := range metrics {
+		updated := "-"
+		if m.UpdatedAt != nil {
+			updated = m.UpdatedAt.Local().Format(time.DateTime)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%t\n", m.MType, m.ID, formatValue(m), updated, m.Stale)
+	}
+
+	return tw.Flush()
+}
+
+func printEvent(env *cmdEnv, event streamEvent, at time.Time) error {
+	if env.format == "json" {
+		return json.NewEncoder(env.stdout).Encode(event)
+	}
+
+	ts := at.Format(time.TimeOnly)
+	switch {
+	case event.Op == "lagged":
+		_, err := fmt.Fprintf(env.stdout, "%s  lagged  %d events dropped\n", ts, event.Dropped)
+		return err
+	case event.Metric == nil:
+		_, err := fmt.Fprintf(env.stdout, "%s  %s\n", ts, event.Op)
+		return err
+	case event.Op == "delete":
+		_, err := fmt.Fprintf(env.stdout, "%s  %s  %s  %s\n", ts, event.Op, event.Metric.MType, event.Metric.ID)
+		return err
+	}
+
+	_, err := fmt.Fprintf(env.stdout, "%s  %s  %s  %s  %s\n", ts, event.Op, event.Metric.MType, event.Metric.ID, formatValue(*event.Metric))
+	return err
+}
+
+func printAlerts(env *cmdEnv, alerts []alert) error {
+	if env.format == "json" {
+		return json.NewEncoder(env.stdout).Encode(alerts)
+	}
+	if len(alerts) == 0 {
+		_, err := fmt.Fprintln(env.stdout, "no alerts")
+		return err
+	}
+
+	tw := tabwriter.NewWriter(env.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "KIND\tSUBJECT\tDETAIL")
+	for _, a := range alerts {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", a.Kind, a.Subject, a.Detail)
+	}
+
+	return tw.Flush()
+}
+
+// formatValue показывает значение так, как его отдаёт /value/: квантиль и число
+// наблюдений для summary, оценку числа элементов для set.
+func formatValue(m models.Metrics) string {
+	switch m.MType {
+	case models.Gauge:
+		if m.Value != nil {
+			return formatFloat(*m.Value)
+		}
+	case models.Counter, models.Set:
+		if m.Delta != nil {
+			return strconv.FormatInt(*m.Delta, 10)
+		}
+	case models.Summary:
+		if m.Value != nil && m.Quantile != nil && m.Delta != nil {
+			return fmt.Sprintf("%s (q=%s, count=%d)", formatFloat(*m.Value), formatFloat(*m.Quantile), *m.Delta)
+		}
+		if m.Value != nil {
+			return formatFloat(*m.Value)
+		}
+	}
+	return "-"
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"github.com/Guram-Gurych/metricserver.git/internal/config"
+	"github.com/Guram-Gurych/metricserver.git/internal/ingest"
+	"github.com/Guram-Gurych/metricserver.git/internal/ingest/graphite"
+	"github.com/Guram-Gurych/metricserver.git/internal/ingest/statsd"
+	"github.com/Guram-Gurych/metricserver.git/internal/logger"
+	"github.com/Guram-Gurych/metricserver.git/internal/repository"
+	"go.uber.org/zap"
+	"net"
+	"os"
+	"time"
+)
+
+const statsdReportInterval = time.Minute
+
+// startIngest запускает включённые в конфигурации приёмники сторонних протоколов.
+func startIngest(cnfg *config.Config, repo repository.MetricRepository, ordering *ingest.Ordering) error {
+	if cnfg.StatsDUDP != "" || cnfg.StatsDTCP != "" {
+		if err := startStatsD(cnfg, repo); err != nil {
+			return err
+		}
+	}
+
+	if cnfg.GraphiteTCP != "" {
+		if err := startGraphite(cnfg, repo, ordering); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func startGraphite(cnfg *config.Config, repo repository.MetricRepository, ordering *ingest.Ordering) error {
+	var rules []graphite.Rule
+	if cnfg.GraphiteMapping != "" {
+		file, err := os.Open(cnfg.GraphiteMapping)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		if rules, err = graphite.ParseRules(file); err != nil {
+			return fmt.Errorf("graphite mapping %s: %w", cnfg.GraphiteMapping, err)
+		}
+	}
+
+	l, err := net.Listen("tcp", cnfg.GraphiteTCP)
+	if err != nil {
+		return err
+	}
+	logger.Log.Info("Graphite listener started", zap.String("address", cnfg.GraphiteTCP), zap.Int("rules", len(rules)))
+
+	srv := graphite.NewServer(repo, graphite.NewMapper(rules), logger.Log)
+	srv.SetOrdering(ordering)
+	go func() {
+		if err := srv.ServeTCP(l); err != nil {
+			logger.Log.Error("Graphite listener stopped", zap.Error(err))
+		}
+	}()
+
+	return nil
+}
+
+func startStatsD(cnfg *config.Config, repo repository.MetricRepository) error {
+	srv := statsd.NewServer(repo, logger.Log)
+
+	if cnfg.StatsDUDP != "" {
+		conn, err := net.ListenPacket("udp", cnfg.StatsDUDP)
+		if err != nil {
+			return err
+		}
+		logger.Log.Info("StatsD UDP listener started", zap.String("address", cnfg.StatsDUDP))
+
+		go func() {
+			if err := srv.ServeUDP(conn); err != nil {
+				logger.Log.Error("StatsD UDP listener stopped", zap.Error(err))
+			}
+		}()
+	}
+
+	if cnfg.StatsDTCP != "" {
+		l, err := net.Listen("tcp", cnfg.StatsDTCP)
+		if err != nil {
+			return err
+		}
+		logger.Log.Info("StatsD TCP listener started", zap.String("address", cnfg.StatsDTCP))
+
+		go func() {
+			if err := srv.ServeTCP(l); err != nil {
+				logger.Log.Error("StatsD TCP listener stopped", zap.Error(err))
+			}
+		}()
+	}
+
+	go reportStatsD(srv)
+
+	return nil
+}
+
+// reportStatsD раз в минуту пишет в лог статистику приёма, если за минуту были ошибки.
+func reportStatsD(srv *statsd.Server) {
+	var reported uint64
+	for range time.Tick(statsdReportInterval) {
+		stats := srv.Stats()
+		if stats.PacketErrors == reported {
+			continue
+		}
+		reported = stats.PacketErrors
+
+		logger.Log.Warn("StatsD packets with invalid lines",
+			zap.Uint64("packets", stats.Packets),
+			zap.Uint64("packet_errors", stats.PacketErrors),
+			zap.Uint64("lines", stats.Lines),
+			zap.Uint64("line_errors", stats.LineErrors))
+	}
+}
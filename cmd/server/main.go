@@ -1,29 +1,47 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"github.com/Guram-Gurych/metricserver.git/internal/config"
 	"github.com/Guram-Gurych/metricserver.git/internal/config/db"
 	"github.com/Guram-Gurych/metricserver.git/internal/handler"
+	"github.com/Guram-Gurych/metricserver.git/internal/ingest"
+	"github.com/Guram-Gurych/metricserver.git/internal/ingest/influx"
+	"github.com/Guram-Gurych/metricserver.git/internal/ingest/otlp"
+	"github.com/Guram-Gurych/metricserver.git/internal/ingest/remotewrite"
 	"github.com/Guram-Gurych/metricserver.git/internal/logger"
 	"github.com/Guram-Gurych/metricserver.git/internal/middleware"
-	"github.com/Guram-Gurych/metricserver.git/internal/persistence"
-	"github.com/Guram-Gurych/metricserver.git/internal/repository"
+	"github.com/Guram-Gurych/metricserver.git/internal/relay"
+	"github.com/Guram-Gurych/metricserver.git/internal/scrape"
+	"github.com/Guram-Gurych/metricserver.git/internal/stream"
 	"github.com/go-chi/chi/v5"
 	_ "github.com/jackc/pgx/v4/stdlib"
 	"go.uber.org/zap"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 )
 
+const shutdownTimeout = 10 * time.Second
+
 func main() {
 	if err := logger.Initalize("info"); err != nil {
 		panic(err)
 	}
 	defer logger.Log.Sync()
 
+	if runTool(os.Args[1:]) {
+		return
+	}
+
 	cnfg := config.InitConfigServer()
 
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	var dbConn *sql.DB
 	var err error
 	if cnfg.DatabaseDSN != "" {
@@ -35,59 +53,155 @@ func main() {
 		logger.Log.Info("DB connection established")
 	}
 
-	storage := repository.NewMemStorage()
-	persister := persistence.NewPersister(storage, cnfg.FileStoragePath, logger.Log)
+	metricRepo, closeStorage, err := openStorage(cnfg)
+	if err != nil {
+		logger.Log.Fatal("Failed to open storage", zap.String("storage", cnfg.Storage), zap.Error(err))
+	}
+	defer closeStorage()
 
-	if cnfg.Restore {
-		if err := persister.Load(); err != nil {
-			logger.Log.Error("Failed to load metrics from file", zap.Error(err))
-		} else {
-			logger.Log.Info("Metrics loaded from file", zap.String("file", cnfg.FileStoragePath))
+	// stopRelay останавливает forwarder и ждёт, пока последнее окно попадёт в outbox.
+	stopRelay := func() {}
+	if cnfg.RelayUpstream != "" {
+		outbox, err := relay.OpenOutbox(cnfg.RelayOutbox, relay.DefaultMaxBatches)
+		if err != nil {
+			logger.Log.Fatal("Failed to open relay outbox", zap.String("dir", cnfg.RelayOutbox), zap.Error(err))
+		}
+		if cnfg.RelayInterval <= 0 {
+			logger.Log.Fatal("Relay interval must be positive", zap.Duration("interval", cnfg.RelayInterval))
 		}
+		window := relay.NewWindow()
+		forwarder := relay.NewForwarder(cnfg.RelayUpstream, window, outbox, logger.Log)
+		forwarder.SetToken(cnfg.RelayToken)
+		forwarder.SetSigningKey(cnfg.RelayKey)
+		metricRepo = relay.NewStorage(metricRepo, window)
+		// Forwarder останавливается отдельно и после HTTP-сервера, чтобы в последнее окно
+		// попали все принятые запросы.
+		relayCtx, cancelRelay := context.WithCancel(context.Background())
+		relayDone := make(chan struct{})
+		go func() {
+			defer close(relayDone)
+			forwarder.Run(relayCtx, cnfg.RelayInterval)
+		}()
+		stopRelay = func() {
+			cancelRelay()
+			<-relayDone
+		}
+		logger.Log.Info("Relaying metrics upstream", zap.String("upstream", cnfg.RelayUpstream), zap.Duration("interval", cnfg.RelayInterval), zap.Int("pending", outbox.Len()))
 	}
 
-	defer func() {
-		logger.Log.Info("Shutting down, saving metrics...")
-		if err := persister.Save(); err != nil {
-			logger.Log.Error("Failed to save metrics on shutdown", zap.Error(err))
-		} else {
-			logger.Log.Info("Metrics saved on shutdown")
-		}
-	}()
+	// Приёмники с метками времени делят один порядок точек: у хранилища одно значение на имя.
+	ordering := ingest.NewOrdering()
+	metricRepo = ingest.NewStorage(metricRepo, ordering)
+
+	hub := stream.NewHub(stream.DefaultBufferSize)
+	metricRepo = stream.NewPublishingStorage(metricRepo, hub)
 
-	if cnfg.StoreInterval > 0 {
+	if cnfg.MetricTTL > 0 && cnfg.EvictStale {
 		go func() {
-			ticker := time.NewTicker(cnfg.StoreInterval)
+			ticker := time.NewTicker(max(cnfg.MetricTTL/2, time.Second))
 			for range ticker.C {
-				logger.Log.Debug("Saving metrics periodically")
-				if err := persister.Save(); err != nil {
-					logger.Log.Error("Failed to save metrics periodically", zap.Error(err))
+				evicted, err := metricRepo.EvictStale(context.Background(), time.Now().Add(-cnfg.MetricTTL))
+				if err != nil {
+					logger.Log.Error("Failed to evict stale metrics", zap.Error(err))
+				} else if evicted > 0 {
+					logger.Log.Info("Stale metrics evicted", zap.Int("count", evicted))
 				}
 			}
 		}()
 	}
 
-	var metricRepo repository.MetricRepository = storage
-	if cnfg.StoreInterval == 0 {
-		logger.Log.Info("Sync storage mode enabled")
-		metricRepo = persistence.NewPersistentStorage(storage, persister, true)
+	if err := startIngest(cnfg, metricRepo, ordering); err != nil {
+		logger.Log.Fatal("Failed to start ingestion listeners", zap.Error(err))
+	}
+
+	var scraper *scrape.Scraper
+	if len(cnfg.ScrapeTargets) > 0 {
+		scraper, err = scrape.NewScraper(metricRepo, cnfg.ScrapeTargets, cnfg.ScrapeInterval, logger.Log)
+		if err != nil {
+			logger.Log.Fatal("Invalid scrape targets", zap.Error(err))
+		}
+		scraper.SetToken(cnfg.WriteToken)
+		go scraper.Run(context.Background())
+		logger.Log.Info("Scraping agents", zap.Strings("targets", cnfg.ScrapeTargets), zap.Duration("interval", cnfg.ScrapeInterval))
+	}
+
+	influxRules, err := influx.ParseRules(cnfg.InfluxIntRules)
+	if err != nil {
+		logger.Log.Fatal("Invalid influx integer field rules", zap.Error(err))
 	}
 
 	metricHandler := handler.NewMetricHandler(metricRepo, dbConn)
+	metricHandler.SetTTL(cnfg.MetricTTL)
+	maxBodySize := int64(handler.DefaultMaxBodySize)
+	if cnfg.MaxRequestBody > 0 {
+		maxBodySize = cnfg.MaxRequestBody
+	}
+	metricHandler.SetMaxBodySize(maxBodySize)
+	metricHandler.SetHub(hub)
+	influxWriter := influx.NewWriter(metricRepo, influxRules)
+	influxWriter.SetOrdering(ordering)
+	metricHandler.SetInfluxWriter(influxWriter)
+	metricHandler.SetOTLPReceiver(otlp.NewReceiver(metricRepo))
+	remoteWriteReceiver := remotewrite.NewReceiver(metricRepo)
+	remoteWriteReceiver.SetOrdering(ordering)
+	metricHandler.SetRemoteWriteReceiver(remoteWriteReceiver)
+	metricHandler.SetScraper(scraper)
 
 	r := chi.NewRouter()
 	r.Use(middleware.RequestLogger)
 	r.Use(middleware.GzipMiddleware)
 	r.Get("/", metricHandler.GetAllMetricsHTML)
-	r.Post("/update/{metricType}/{metricName}/{metricValue}", metricHandler.Post)
 	r.Post("/value/", metricHandler.PostValue)
 	r.Get("/value/{metricType}/{metricName}", metricHandler.Get)
-	r.Post("/update/", metricHandler.Post)
 	r.Get("/ping", metricHandler.GetPing)
+	r.Get("/api/v1/metrics", metricHandler.ListMetrics)
+	r.Get("/api/v1/stream", metricHandler.Stream)
+	r.Get("/api/v1/targets", metricHandler.Targets)
+
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.WriteAuth(cnfg.WriteToken))
+		// Telegraf, Prometheus и OTLP-экспортёры не умеют подписывать тело,
+		// поэтому подпись проверяется только на собственных ручках сервера.
+		r.Post("/api/v1/write", metricHandler.ReceiveRemoteWrite)
+		r.Post("/api/v2/write", metricHandler.WriteInflux)
+		r.Post("/v1/metrics", metricHandler.ReceiveOTLP)
+
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.VerifySignature(cnfg.SigningKey, maxBodySize))
+			r.Post("/update/{metricType}/{metricName}/{metricValue}", metricHandler.Post)
+			r.Post("/update/", metricHandler.Post)
+			r.Post("/updates/", metricHandler.PostBatch)
+		})
+	})
+
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.AdminAuth(cnfg.AdminToken))
+		r.Delete("/value/{metricType}/{metricName}", metricHandler.Delete)
+		r.Delete("/value/", metricHandler.DeleteBulk)
+		r.Post("/reset/counter/{metricName}", metricHandler.ResetCounter)
+		r.Get("/admin/export", metricHandler.Export)
+		r.Post("/admin/import", metricHandler.Import)
+	})
 
 	logger.Log.Info("Starting server", zap.String("address", cnfg.ServerAddress))
 
-	if err := http.ListenAndServe(cnfg.ServerAddress, r); err != nil {
+	srv := &http.Server{Addr: cnfg.ServerAddress, Handler: r}
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
 		logger.Log.Fatal("The server crashed", zap.Error(err))
+	case <-ctx.Done():
+	}
+
+	logger.Log.Info("Shutting down server")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		logger.Log.Error("Failed to shut down the server gracefully", zap.Error(err))
 	}
+	stopRelay()
 }
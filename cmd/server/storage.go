@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/Guram-Gurych/metricserver.git/internal/config"
+	"github.com/Guram-Gurych/metricserver.git/internal/logger"
+	"github.com/Guram-Gurych/metricserver.git/internal/persistence"
+	"github.com/Guram-Gurych/metricserver.git/internal/repository"
+	"go.uber.org/zap"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// openStorage создаёт хранилище, выбранное параметром -storage.
+// Возвращаемую функцию нужно вызвать при остановке сервера.
+func openStorage(cnfg *config.Config) (repository.MetricRepository, func(), error) {
+	backend, path, _ := strings.Cut(cnfg.Storage, ":")
+	switch backend {
+	case "memory":
+		return openFileStorage(cnfg, repository.NewMemStorage())
+	case "sharded":
+		shards := repository.DefaultShards
+		if path != "" {
+			n, err := strconv.Atoi(path)
+			if err != nil || n <= 0 {
+				return nil, nil, fmt.Errorf("invalid number of shards %q", path)
+			}
+			shards = n
+		}
+		logger.Log.Info("Sharded in-memory storage enabled", zap.Int("shards", shards))
+		return openFileStorage(cnfg, repository.NewShardedStorage(shards))
+	case "kv":
+		return openKVStorage(path)
+	}
+
+	return nil, nil, fmt.Errorf("unknown storage %q", cnfg.Storage)
+}
+
+func openKVStorage(path string) (repository.MetricRepository, func(), error) {
+	if path == "" {
+		return nil, nil, fmt.Errorf("kv storage requires a path: -storage=kv:/path/to/metrics.db")
+	}
+
+	storage, err := repository.NewBoltStorage(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	logger.Log.Info("Embedded key-value storage opened", zap.String("path", path))
+
+	closeStorage := func() {
+		if err := storage.Close(); err != nil {
+			logger.Log.Error("Failed to close key-value storage", zap.Error(err))
+		}
+	}
+
+	return storage, closeStorage, nil
+}
+
+// openFileStorage подключает к хранилищу в памяти сохранение в файл метрик.
+func openFileStorage(cnfg *config.Config, storage repository.MetricRepository) (repository.MetricRepository, func(), error) {
+	persister := persistence.NewPersister(storage, cnfg.FileStoragePath, logger.Log)
+	if err := persister.SetFormat(cnfg.FileCompression, config.Version); err != nil {
+		return nil, nil, err
+	}
+
+	persister.SetSyncWindow(cnfg.StoreSyncWindow)
+	if err := persister.SetRestoreMode(repository.RestoreMode(cnfg.RestoreMode)); err != nil {
+		return nil, nil, fmt.Errorf("restore mode %q: %w", cnfg.RestoreMode, err)
+	}
+
+	if cnfg.Restore {
+		if err := persister.Load(context.Background()); err != nil {
+			logger.Log.Error("Failed to load metrics from file", zap.Error(err))
+		} else {
+			logger.Log.Info("Metrics loaded from file", zap.String("file", cnfg.FileStoragePath))
+		}
+	}
+
+	closeStorage := func() {
+		logger.Log.Info("Shutting down, saving metrics...")
+		if err := persister.Save(context.Background()); err != nil {
+			logger.Log.Error("Failed to save metrics on shutdown", zap.Error(err))
+		} else {
+			logger.Log.Info("Metrics saved on shutdown")
+		}
+		if err := persister.Close(); err != nil {
+			logger.Log.Error("Failed to close WAL", zap.Error(err))
+		}
+	}
+
+	if cnfg.StoreInterval > 0 {
+		go func() {
+			ticker := time.NewTicker(cnfg.StoreInterval)
+			for range ticker.C {
+				logger.Log.Debug("Saving metrics periodically")
+				if err := persister.Save(context.Background()); err != nil {
+					logger.Log.Error("Failed to save metrics periodically", zap.Error(err))
+				}
+			}
+		}()
+	}
+
+	if cnfg.StoreInterval == 0 {
+		logger.Log.Info("Sync storage mode enabled")
+		return persistence.NewPersistentStorage(storage, persister, true), closeStorage, nil
+	}
+
+	return storage, closeStorage, nil
+}
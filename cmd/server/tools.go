@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/Guram-Gurych/metricserver.git/internal/config"
+	"github.com/Guram-Gurych/metricserver.git/internal/export"
+	"github.com/Guram-Gurych/metricserver.git/internal/logger"
+	"github.com/Guram-Gurych/metricserver.git/internal/persistence"
+	"github.com/Guram-Gurych/metricserver.git/internal/repository"
+	"io"
+	"os"
+	"strings"
+)
+
+// runTool выполняет подкоманду export или import, если она указана первым аргументом.
+// Возвращает false, если аргументы относятся к обычному запуску сервера.
+func runTool(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+
+	var err error
+	switch args[0] {
+	case "export":
+		err = runExport(args[1:])
+	case "import":
+		err = runImport(args[1:])
+	default:
+		return false
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", args[0], err)
+		os.Exit(1)
+	}
+
+	return true
+}
+
+type toolFlags struct {
+	storage     string
+	filePath    string
+	compression string
+	format      string
+}
+
+func (f *toolFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.storage, "storage", "memory", "Metrics storage: memory or sharded[:N] (both with the metrics file), or kv:/path for the embedded key-value database")
+	fs.StringVar(&f.filePath, "f", "/tmp/metrics-db.json", "The name of the file where the current values are saved")
+	fs.StringVar(&f.compression, "file-compression", "none", "Compression of the metrics file: none, gzip or zstd")
+	fs.StringVar(&f.format, "format", export.FormatJSON, "Data format: json, csv or prometheus")
+}
+
+func (f *toolFlags) applyEnv() {
+	if envStorage := os.Getenv("STORAGE"); envStorage != "" {
+		f.storage = envStorage
+	}
+
+	if envFileStoragePath := os.Getenv("FILE_STORAGE_PATH"); envFileStoragePath != "" {
+		f.filePath = envFileStoragePath
+	}
+
+	if envFileCompression := os.Getenv("FILE_COMPRESSION"); envFileCompression != "" {
+		f.compression = envFileCompression
+	}
+}
+
+// openStorage открывает хранилище метрик. Для файла данные загружаются в память,
+// и save записывает их обратно; база kv сохраняет каждое изменение сама.
+func (f *toolFlags) openStorage(ctx context.Context) (repo repository.MetricRepository, save func(context.Context) error, closeStorage func() error, err error) {
+	if backend, path, _ := strings.Cut(f.storage, ":"); backend == "kv" {
+		storage, err := repository.NewBoltStorage(path)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		noSave := func(context.Context) error { return nil }
+		return storage, noSave, storage.Close, nil
+	}
+
+	storage := repository.NewMemStorage()
+	persister := persistence.NewPersister(storage, f.filePath, logger.Log)
+	if err := persister.SetFormat(f.compression, config.Version); err != nil {
+		return nil, nil, nil, err
+	}
+
+	if err := persister.Load(ctx); err != nil {
+		return nil, nil, nil, err
+	}
+
+	return storage, persister.Save, persister.Close, nil
+}
+
+func runExport(args []string) error {
+	var flags toolFlags
+	var output string
+
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	flags.register(fs)
+	fs.StringVar(&output, "o", "", "Output file, stdout if empty")
+	fs.Parse(args)
+	flags.applyEnv()
+
+	ctx := context.Background()
+	storage, _, closeStorage, err := flags.openStorage(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeStorage()
+
+	if output == "" {
+		return export.Export(ctx, storage, os.Stdout, flags.format)
+	}
+
+	file, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	if err := export.Export(ctx, storage, file, flags.format); err != nil {
+		file.Close()
+		return err
+	}
+
+	// Ошибка записи на диск может проявиться только при закрытии файла.
+	return file.Close()
+}
+
+func runImport(args []string) error {
+	var flags toolFlags
+	var input, mode string
+
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	flags.register(fs)
+	fs.StringVar(&input, "i", "", "Input file, stdin if empty")
+	fs.StringVar(&mode, "mode", string(repository.RestoreMerge), "How to apply imported data: replace or merge; the prometheus format is lossy and only supports merge")
+	fs.Parse(args)
+	flags.applyEnv()
+
+	restoreMode, err := repository.ParseRestoreMode(mode)
+	if err != nil {
+		return err
+	}
+
+	var r io.Reader = os.Stdin
+	if input != "" {
+		file, err := os.Open(input)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		r = file
+	}
+
+	ctx := context.Background()
+	storage, save, closeStorage, err := flags.openStorage(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeStorage()
+
+	if err := export.Import(ctx, storage, r, flags.format, restoreMode); err != nil {
+		return err
+	}
+
+	return save(ctx)
+}
@@ -3,7 +3,9 @@ package agent
 import (
 	"bytes"
 	"compress/gzip"
+	"encoding/hex"
 	"encoding/json"
+	"github.com/Guram-Gurych/metricserver.git/internal/middleware"
 	models "github.com/Guram-Gurych/metricserver.git/internal/model"
 	"github.com/go-resty/resty/v2"
 	"log"
@@ -21,6 +23,11 @@ type Agent struct {
 	serverAddress  string
 	pollInterval   time.Duration
 	reportInterval time.Duration
+	push           bool
+	token          string
+	key            string
+	// pollCount - PollCount с запуска агента для pull-ручки; меняется под storage.mu.
+	pollCount int64
 }
 
 func NewAgent(serverAddress string, pollInterval, reportInterval time.Duration) *Agent {
@@ -33,21 +40,72 @@ func NewAgent(serverAddress string, pollInterval, reportInterval time.Duration)
 		serverAddress:  serverAddress,
 		pollInterval:   pollInterval,
 		reportInterval: reportInterval,
+		push:           true,
 	}
 }
 
+// SetWriteToken задаёт токен, который агент передаёт серверу в заголовке Authorization.
+// Тот же токен требуется от сервера при чтении pull-ручки.
+func (a *Agent) SetWriteToken(token string) {
+	a.token = token
+	if token != "" {
+		a.client.SetAuthToken(token)
+	}
+}
+
+// SetSigningKey включает подпись тела каждого обновления HMAC-SHA256 ключом сервера (-k).
+func (a *Agent) SetSigningKey(key string) {
+	a.key = key
+}
+
+// DisablePush выключает отправку метрик: в pull-режиме сервер сам забирает их через Handler.
+func (a *Agent) DisablePush() {
+	a.push = false
+}
+
+// Handler отдаёт текущие метрики агента в JSON на GET /metrics. Counter-ы в pull-режиме
+// не обнуляются: сервер сам считает прирост между опросами. PollCount отдаётся с запуска
+// агента: в режиме both push вычитает отправленное, и сервер принял бы падение
+// значения за перезапуск агента.
+func (a *Agent) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", middleware.WriteAuth(a.token)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(a.pullSnapshot()); err != nil {
+			log.Printf("Ошибка отправки снимка метрик: %v", err)
+		}
+	})))
+
+	return mux
+}
+
+func (a *Agent) pullSnapshot() *AgentMetric {
+	snapshot := a.storage.Snapshot()
+
+	a.storage.mu.Lock()
+	snapshot.Counters["PollCount"] = a.pollCount
+	a.storage.mu.Unlock()
+
+	return snapshot
+}
+
 func (a *Agent) Run() {
 	pollTicker := time.NewTicker(a.pollInterval)
-	reportTicker := time.NewTicker(a.reportInterval)
 	defer pollTicker.Stop()
-	defer reportTicker.Stop()
+
+	var reportC <-chan time.Time
+	if a.push {
+		reportTicker := time.NewTicker(a.reportInterval)
+		defer reportTicker.Stop()
+		reportC = reportTicker.C
+	}
 
 	for {
 		select {
 		case <-pollTicker.C:
 			a.pollMetrics()
 			log.Println("Метрики собранны")
-		case <-reportTicker.C:
+		case <-reportC:
 			a.reportMetrics()
 			log.Println("Метрики отправлены")
 		}
@@ -59,6 +117,9 @@ func (a *Agent) pollMetrics() {
 	runtime.ReadMemStats(&m)
 	v := reflect.ValueOf(m)
 
+	a.storage.mu.Lock()
+	defer a.storage.mu.Unlock()
+
 	for _, metricName := range GaugeMetrics {
 		value := v.FieldByName(metricName)
 
@@ -74,15 +135,18 @@ func (a *Agent) pollMetrics() {
 
 	a.storage.Gauges["RandomValue"] = rand.Float64()
 	a.storage.Counters["PollCount"] += 1
+	a.pollCount++
 }
 
 func (a *Agent) reportMetrics() {
-	for name, value := range a.storage.Gauges {
+	snapshot := a.storage.Snapshot()
+
+	for name, value := range snapshot.Gauges {
 		valueStr := strconv.FormatFloat(value, 'f', -1, 64)
 		a.sendMetric("gauge", name, valueStr)
 	}
 
-	for name, value := range a.storage.Counters {
+	for name, value := range snapshot.Counters {
 		valueStr := strconv.FormatInt(value, 10)
 		a.sendMetric("counter", name, valueStr)
 	}
@@ -111,19 +175,26 @@ func (a *Agent) sendMetric(metricType, metricName, metricValue string) {
 		m.Delta = &value
 
 		if metricName == "PollCount" {
-			a.storage.Counters["PollCount"] = 0
+			// Вычитаем отправленное, а не обнуляем: опросы после снимка не должны теряться.
+			a.storage.mu.Lock()
+			a.storage.Counters["PollCount"] -= value
+			a.storage.mu.Unlock()
 		}
 	default:
 		log.Printf("Неизвестный тип метрики: %s", metricType)
 		return
 	}
 
+	body, err := json.Marshal(m)
+	if err != nil {
+		log.Printf("Ошибка кодирования метрики %s: %v", metricName, err)
+		return
+	}
+
 	var buf bytes.Buffer
 	gzWriter := gzip.NewWriter(&buf)
 
-	encoder := json.NewEncoder(gzWriter)
-
-	if err := encoder.Encode(m); err != nil {
+	if _, err := gzWriter.Write(body); err != nil {
 		log.Printf("Ошибка сжатия метрики %s: %v", metricName, err)
 		return
 	}
@@ -137,7 +208,13 @@ func (a *Agent) sendMetric(metricType, metricName, metricValue string) {
 
 	var responseMetrics models.Metrics
 
-	resp, err := a.client.R().
+	req := a.client.R()
+	if a.key != "" {
+		// Сервер проверяет подпись несжатого тела.
+		req.SetHeader(middleware.SignatureHeader, hex.EncodeToString(middleware.Sign([]byte(a.key), body)))
+	}
+
+	resp, err := req.
 		SetHeader("Content-Encoding", "gzip").
 		SetHeader("Content-Type", "application/json").
 		SetBody(&buf).
@@ -4,6 +4,7 @@ import (
 	"compress/gzip"
 	"encoding/json"
 	"fmt"
+	"github.com/Guram-Gurych/metricserver.git/internal/middleware"
 	models "github.com/Guram-Gurych/metricserver.git/internal/model"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -160,3 +161,52 @@ func TestAgent_reportMetrics(t *testing.T) {
 		})
 	}
 }
+
+func TestAgent_Handler(t *testing.T) {
+	a := NewAgent("http://localhost:8080", 1*time.Second, 2*time.Second)
+	a.SetWriteToken("secret")
+	a.storage.Gauges["Alloc"] = 1.5
+	a.storage.Counters["PollCount"] = 3
+	a.pollCount = 3
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	rr := httptest.NewRecorder()
+	a.Handler().ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code, "Без токена снимок не должен отдаваться")
+
+	req.Header.Set("Authorization", "Bearer secret")
+	rr = httptest.NewRecorder()
+	a.Handler().ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"gauges":{"Alloc":1.5},"counters":{"PollCount":3}}`, rr.Body.String())
+	assert.Equal(t, int64(3), a.storage.Counters["PollCount"], "Снимок не должен обнулять counter-ы")
+
+	// Режим both: push вычитает отправленное, а pull-ручка продолжает отдавать PollCount с запуска.
+	a.storage.Counters["PollCount"] = 0
+	rr = httptest.NewRecorder()
+	a.Handler().ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"gauges":{"Alloc":1.5},"counters":{"PollCount":3}}`, rr.Body.String())
+}
+
+func TestAgent_reportMetricsSigned(t *testing.T) {
+	var mu sync.Mutex
+	accepted := 0
+	server := httptest.NewServer(middleware.GzipMiddleware(middleware.VerifySignature("key", 1<<20)(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			accepted++
+			mu.Unlock()
+		}))))
+	defer server.Close()
+
+	a := NewAgent(server.URL, time.Second, 2*time.Second)
+	a.SetSigningKey("key")
+	a.storage.Gauges["Alloc"] = 1.5
+	a.storage.Counters["PollCount"] = 3
+
+	a.reportMetrics()
+
+	assert.Equal(t, 2, accepted, "Сервер с ключом должен принять подписанные обновления")
+	assert.Equal(t, int64(0), a.storage.Counters["PollCount"])
+}
@@ -1,8 +1,31 @@
 package agent
 
+import "sync"
+
+// AgentMetric пишется при опросе, а читается при отправке и из pull-ручки, поэтому доступ идёт под mu.
 type AgentMetric struct {
-	Gauges   map[string]float64
-	Counters map[string]int64
+	mu       sync.Mutex
+	Gauges   map[string]float64 `json:"gauges"`
+	Counters map[string]int64   `json:"counters"`
+}
+
+// Snapshot возвращает копию текущих значений.
+func (m *AgentMetric) Snapshot() *AgentMetric {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	snapshot := &AgentMetric{
+		Gauges:   make(map[string]float64, len(m.Gauges)),
+		Counters: make(map[string]int64, len(m.Counters)),
+	}
+	for k, v := range m.Gauges {
+		snapshot.Gauges[k] = v
+	}
+	for k, v := range m.Counters {
+		snapshot.Counters[k] = v
+	}
+
+	return snapshot
 }
 
 var GaugeMetrics = []string{
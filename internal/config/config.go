@@ -9,28 +9,82 @@ import (
 	"time"
 )
 
+// Version подставляется при сборке: go build -ldflags "-X .../internal/config.Version=v1.2.3".
+var Version = "dev"
+
 type Config struct {
 	ServerAddress   string
 	FileStoragePath string
+	FileCompression string
 	DatabaseDSN     string
+	AdminToken      string
+	WriteToken      string
+	SigningKey      string
 	ReportInterval  time.Duration
 	PollInterval    time.Duration
 	StoreInterval   time.Duration
+	StoreSyncWindow time.Duration
+	MetricTTL       time.Duration
+	EvictStale      bool
 	Restore         bool
+	RestoreMode     string
+	Storage         string
+	StatsDUDP       string
+	StatsDTCP       string
+	GraphiteTCP     string
+	GraphiteMapping string
+	InfluxIntRules  string
+	ScrapeTargets   []string
+	ScrapeInterval  time.Duration
+	RelayUpstream   string
+	RelayInterval   time.Duration
+	RelayOutbox     string
+	RelayToken      string
+	RelayKey        string
+	MaxRequestBody  int64
+	AgentMode       string
+	AgentListen     string
 }
 
 func InitConfigServer() *Config {
 	var config Config
-	var storeInterval int64
+	var storeInterval, storeSyncWindowMs, metricTTL, scrapeIntervalSec, relayIntervalSec int64
+	var scrapeTargets string
 
 	flag.StringVar(&config.ServerAddress, "a", "localhost:8080", "The address for launching the HTTP server")
 	flag.StringVar(&config.FileStoragePath, "f", "/tmp/metrics-db.json", "The name of the file where the current values are saved")
+	flag.StringVar(&config.FileCompression, "file-compression", "none", "Compression of the metrics file: none, gzip or zstd")
 	flag.StringVar(&config.DatabaseDSN, "d", "", "DB connection address")
 	flag.Int64Var(&storeInterval, "i", 300, "the time interval after which the server readings are saved to disk (in seconds)")
+	flag.StringVar(&config.WriteToken, "write-token", "", "Bearer token required by the metric write routes; writes are open if empty")
+	flag.StringVar(&config.SigningKey, "k", "", "Key for verifying the HashSHA256 signature of JSON metric updates; unsigned updates are rejected if set. Influx, remote write and OTLP are not signed and rely on -write-token")
+	flag.StringVar(&config.AdminToken, "admin-token", "", "Bearer token for the admin API (deleting and resetting metrics); the admin API is disabled if empty")
+	flag.Int64Var(&storeSyncWindowMs, "store-sync-window", 5, "In sync storage mode, the window for grouping concurrent updates into one disk flush (in milliseconds)")
+	flag.Int64Var(&metricTTL, "ttl", 0, "The time after which a metric that has not been updated is considered stale (in seconds), 0 disables expiry")
+	flag.BoolVar(&config.EvictStale, "ttl-evict", false, "Remove stale metrics instead of only marking them as stale")
 	flag.BoolVar(&config.Restore, "r", true, "The value that determines whether or not to load previously saved values from the specified file at server startup")
+	flag.StringVar(&config.RestoreMode, "restore-mode", "replace", "How saved values are loaded at startup: replace the storage contents or merge into them")
+	flag.StringVar(&config.StatsDUDP, "statsd-udp", "", "The UDP address for the StatsD listener, disabled if empty")
+	flag.StringVar(&config.StatsDTCP, "statsd-tcp", "", "The TCP address for the StatsD listener, disabled if empty")
+	flag.StringVar(&config.GraphiteTCP, "graphite-tcp", "", "The TCP address for the Graphite plaintext listener, disabled if empty")
+	flag.StringVar(&config.GraphiteMapping, "graphite-mapping", "", "File with Graphite path-to-name mapping rules, one \"pattern name\" per line")
+	flag.StringVar(&config.InfluxIntRules, "influx-int-rules", "", "Comma-separated pattern=counter|gauge rules for integer line protocol fields, gauge if none matches")
+	flag.StringVar(&scrapeTargets, "scrape-targets", "", "Comma-separated agents to scrape in pull mode (host:port or URL), disabled if empty")
+	flag.Int64Var(&scrapeIntervalSec, "scrape-interval", 10, "The frequency of scraping agents in pull mode (in seconds)")
+	flag.StringVar(&config.RelayUpstream, "relay-upstream", "", "The upstream server to forward metrics to in relay mode, disabled if empty")
+	flag.Int64Var(&relayIntervalSec, "relay-interval", 10, "The window for aggregating metrics before forwarding them upstream in relay mode (in seconds)")
+	flag.StringVar(&config.RelayOutbox, "relay-outbox", "/tmp/metrics-relay-outbox", "The directory where batches wait until the upstream accepts them")
+	flag.StringVar(&config.RelayToken, "relay-token", "", "Bearer token sent to the upstream write routes in relay mode")
+	flag.StringVar(&config.RelayKey, "relay-key", "", "Key for signing batches sent upstream in relay mode, required if the upstream runs with -k")
+	flag.Int64Var(&config.MaxRequestBody, "max-request-body", 32<<20, "The maximum body of remote write and OTLP requests, also after decompression (in bytes)")
+	flag.StringVar(&config.Storage, "storage", "memory", "Metrics storage: memory or sharded[:N] (both with the metrics file), or kv:/path for the embedded key-value database")
 	flag.Parse()
 
 	config.StoreInterval = time.Duration(storeInterval) * time.Second
+	config.StoreSyncWindow = time.Duration(storeSyncWindowMs) * time.Millisecond
+	config.MetricTTL = time.Duration(metricTTL) * time.Second
+	config.ScrapeInterval = time.Duration(scrapeIntervalSec) * time.Second
+	config.RelayInterval = time.Duration(relayIntervalSec) * time.Second
 
 	if envAddr := os.Getenv("ADDRESS"); envAddr != "" {
 		config.ServerAddress = envAddr
@@ -40,10 +94,26 @@ func InitConfigServer() *Config {
 		config.FileStoragePath = envFileStoragePath
 	}
 
+	if envFileCompression := os.Getenv("FILE_COMPRESSION"); envFileCompression != "" {
+		config.FileCompression = envFileCompression
+	}
+
 	if envDatabaseDSN := os.Getenv("DATABASE_DSN"); envDatabaseDSN != "" {
 		config.DatabaseDSN = envDatabaseDSN
 	}
 
+	if envAdminToken := os.Getenv("ADMIN_TOKEN"); envAdminToken != "" {
+		config.AdminToken = envAdminToken
+	}
+
+	if envWriteToken := os.Getenv("WRITE_TOKEN"); envWriteToken != "" {
+		config.WriteToken = envWriteToken
+	}
+
+	if envSigningKey := os.Getenv("KEY"); envSigningKey != "" {
+		config.SigningKey = envSigningKey
+	}
+
 	if envStoreInterval := os.Getenv("STORE_INTERVAL"); envStoreInterval != "" {
 		if val, err := strconv.ParseInt(envStoreInterval, 10, 64); err != nil {
 			// loger
@@ -52,6 +122,30 @@ func InitConfigServer() *Config {
 		}
 	}
 
+	if envStoreSyncWindow := os.Getenv("STORE_SYNC_WINDOW"); envStoreSyncWindow != "" {
+		if val, err := strconv.ParseInt(envStoreSyncWindow, 10, 64); err != nil {
+			log.Printf("WARN: неверное значение переменной STORE_SYNC_WINDOW: '%s'. Используется значение по умолчанию.", envStoreSyncWindow)
+		} else {
+			config.StoreSyncWindow = time.Duration(val) * time.Millisecond
+		}
+	}
+
+	if envMetricTTL := os.Getenv("METRIC_TTL"); envMetricTTL != "" {
+		if val, err := strconv.ParseInt(envMetricTTL, 10, 64); err != nil {
+			log.Printf("WARN: неверное значение переменной METRIC_TTL: '%s'. Используется значение по умолчанию.", envMetricTTL)
+		} else {
+			config.MetricTTL = time.Duration(val) * time.Second
+		}
+	}
+
+	if envEvictStale := os.Getenv("METRIC_TTL_EVICT"); envEvictStale != "" {
+		if val, err := strconv.ParseBool(envEvictStale); err != nil {
+			log.Printf("WARN: неверное значение переменной METRIC_TTL_EVICT: '%s'. Используется значение по умолчанию.", envEvictStale)
+		} else {
+			config.EvictStale = val
+		}
+	}
+
 	if envRestore := os.Getenv("RESTORE"); envRestore != "" {
 		if val, err := strconv.ParseBool(envRestore); err != nil {
 			// loger
@@ -60,6 +154,83 @@ func InitConfigServer() *Config {
 		}
 	}
 
+	if envRestoreMode := os.Getenv("RESTORE_MODE"); envRestoreMode != "" {
+		config.RestoreMode = envRestoreMode
+	}
+
+	if envStorage := os.Getenv("STORAGE"); envStorage != "" {
+		config.Storage = envStorage
+	}
+
+	if envStatsDUDP := os.Getenv("STATSD_UDP_ADDRESS"); envStatsDUDP != "" {
+		config.StatsDUDP = envStatsDUDP
+	}
+
+	if envStatsDTCP := os.Getenv("STATSD_TCP_ADDRESS"); envStatsDTCP != "" {
+		config.StatsDTCP = envStatsDTCP
+	}
+
+	if envGraphiteTCP := os.Getenv("GRAPHITE_TCP_ADDRESS"); envGraphiteTCP != "" {
+		config.GraphiteTCP = envGraphiteTCP
+	}
+
+	if envGraphiteMapping := os.Getenv("GRAPHITE_MAPPING_FILE"); envGraphiteMapping != "" {
+		config.GraphiteMapping = envGraphiteMapping
+	}
+
+	if envInfluxIntRules := os.Getenv("INFLUX_INT_RULES"); envInfluxIntRules != "" {
+		config.InfluxIntRules = envInfluxIntRules
+	}
+
+	if envScrapeTargets := os.Getenv("SCRAPE_TARGETS"); envScrapeTargets != "" {
+		scrapeTargets = envScrapeTargets
+	}
+	for _, target := range strings.Split(scrapeTargets, ",") {
+		if target = strings.TrimSpace(target); target != "" {
+			config.ScrapeTargets = append(config.ScrapeTargets, target)
+		}
+	}
+
+	if envScrapeInterval := os.Getenv("SCRAPE_INTERVAL"); envScrapeInterval != "" {
+		if val, err := strconv.ParseInt(envScrapeInterval, 10, 64); err != nil || val <= 0 {
+			log.Printf("WARN: неверное значение переменной SCRAPE_INTERVAL: '%s'. Используется значение по умолчанию.", envScrapeInterval)
+		} else {
+			config.ScrapeInterval = time.Duration(val) * time.Second
+		}
+	}
+
+	if envRelayUpstream := os.Getenv("RELAY_UPSTREAM"); envRelayUpstream != "" {
+		config.RelayUpstream = envRelayUpstream
+	}
+
+	if envRelayInterval := os.Getenv("RELAY_INTERVAL"); envRelayInterval != "" {
+		if val, err := strconv.ParseInt(envRelayInterval, 10, 64); err != nil || val <= 0 {
+			log.Printf("WARN: неверное значение переменной RELAY_INTERVAL: '%s'. Используется значение по умолчанию.", envRelayInterval)
+		} else {
+			config.RelayInterval = time.Duration(val) * time.Second
+		}
+	}
+
+	if envRelayOutbox := os.Getenv("RELAY_OUTBOX_DIR"); envRelayOutbox != "" {
+		config.RelayOutbox = envRelayOutbox
+	}
+
+	if envRelayToken := os.Getenv("RELAY_TOKEN"); envRelayToken != "" {
+		config.RelayToken = envRelayToken
+	}
+
+	if envRelayKey := os.Getenv("RELAY_KEY"); envRelayKey != "" {
+		config.RelayKey = envRelayKey
+	}
+
+	if envMaxRequestBody := os.Getenv("MAX_REQUEST_BODY"); envMaxRequestBody != "" {
+		if val, err := strconv.ParseInt(envMaxRequestBody, 10, 64); err != nil || val <= 0 {
+			log.Printf("WARN: неверное значение переменной MAX_REQUEST_BODY: '%s'. Используется значение по умолчанию.", envMaxRequestBody)
+		} else {
+			config.MaxRequestBody = val
+		}
+	}
+
 	return &config
 }
 
@@ -70,6 +241,10 @@ func InitConfigAgent() *Config {
 	flag.StringVar(&config.ServerAddress, "a", "localhost:8080", "HTTP Server endpoint address")
 	flag.Int64Var(&reportIntervalSec, "r", 10, "The frequency of sending metrics to the server (in seconds)")
 	flag.Int64Var(&pollIntervalSec, "p", 2, "The frequency of polling metrics (in seconds)")
+	flag.StringVar(&config.WriteToken, "write-token", "", "Bearer token sent with metric updates")
+	flag.StringVar(&config.SigningKey, "k", "", "Key for signing metric updates with HashSHA256, required if the server runs with -k")
+	flag.StringVar(&config.AgentMode, "mode", "push", "push sends metrics to the server, pull serves them for the server to scrape, both does both")
+	flag.StringVar(&config.AgentListen, "listen", ":9101", "The address for serving metrics in pull mode")
 	flag.Parse()
 
 	config.ReportInterval = time.Duration(reportIntervalSec) * time.Second
@@ -95,6 +270,27 @@ func InitConfigAgent() *Config {
 		}
 	}
 
+	if envWriteToken := os.Getenv("WRITE_TOKEN"); envWriteToken != "" {
+		config.WriteToken = envWriteToken
+	}
+
+	if envSigningKey := os.Getenv("KEY"); envSigningKey != "" {
+		config.SigningKey = envSigningKey
+	}
+
+	if envAgentMode := os.Getenv("AGENT_MODE"); envAgentMode != "" {
+		config.AgentMode = envAgentMode
+	}
+
+	if envAgentListen := os.Getenv("AGENT_LISTEN_ADDRESS"); envAgentListen != "" {
+		config.AgentListen = envAgentListen
+	}
+
+	if config.AgentMode != "push" && config.AgentMode != "pull" && config.AgentMode != "both" {
+		log.Printf("WARN: неверный режим агента: '%s'. Используется push.", config.AgentMode)
+		config.AgentMode = "push"
+	}
+
 	if !strings.HasPrefix(config.ServerAddress, "http://") {
 		config.ServerAddress = "http://" + config.ServerAddress
 	}
@@ -0,0 +1,382 @@
+package export
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Guram-Gurych/metricserver.git/internal/model"
+	"github.com/Guram-Gurych/metricserver.git/internal/repository"
+	"github.com/Guram-Gurych/metricserver.git/internal/sketch"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	FormatJSON       = "json"
+	FormatCSV        = "csv"
+	FormatPrometheus = "prometheus"
+)
+
+var (
+	ErrUnknownFormat  = errors.New("unknown export format")
+	ErrInvalidPayload = errors.New("invalid import payload")
+	// ErrLossyReplace - replace из формата Prometheus стёр бы summary и set, которых в нём нет.
+	ErrLossyReplace = errors.New("prometheus format cannot be imported with mode=replace")
+)
+
+var csvHeader = []string{"type", "name", "value"}
+
+// summaryQuantiles - квантили summary, которые попадают в текстовый формат Prometheus.
+var summaryQuantiles = []float64{0.5, 0.9, 0.99}
+
+func ValidFormat(format string) bool {
+	switch format {
+	case FormatJSON, FormatCSV, FormatPrometheus:
+		return true
+	}
+
+	return false
+}
+
+func ContentType(format string) string {
+	switch format {
+	case FormatJSON:
+		return "application/json"
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatPrometheus:
+		return "text/plain; version=0.0.4; charset=utf-8"
+	}
+
+	return "application/octet-stream"
+}
+
+// Export выгружает хранилище в w. CSV и Prometheus пишутся потоком, страница за страницей,
+// JSON требует полного снимка.
+func Export(ctx context.Context, repo repository.MetricRepository, w io.Writer, format string) error {
+	switch format {
+	case FormatJSON:
+		snapshot, err := repository.TakeSnapshot(ctx, repo)
+		if err != nil {
+			return err
+		}
+		return json.NewEncoder(w).Encode(snapshot)
+	case FormatCSV:
+		return writeCSV(ctx, repo, w)
+	case FormatPrometheus:
+		return writePrometheus(ctx, repo, w)
+	}
+
+	return fmt.Errorf("%w: %s", ErrUnknownFormat, format)
+}
+
+// CheckImportMode отклоняет сочетания формата и режима, при которых импорт теряет данные.
+// Текстовый формат Prometheus переносит только gauge и counter, да и имена в нём
+// приведены к PrometheusName, поэтому его можно только накладывать режимом merge.
+func CheckImportMode(format string, mode repository.RestoreMode) error {
+	if format == FormatPrometheus && mode == repository.RestoreReplace {
+		return ErrLossyReplace
+	}
+
+	return nil
+}
+
+func Import(ctx context.Context, repo repository.MetricRepository, r io.Reader, format string, mode repository.RestoreMode) error {
+	if err := CheckImportMode(format, mode); err != nil {
+		return err
+	}
+
+	snapshot, err := Read(r, format)
+	if err != nil {
+		return err
+	}
+
+	return repo.Restore(ctx, *snapshot, mode)
+}
+
+// Read разбирает выгрузку и проверяет скетчи: null или испорченный скетч
+// отклоняется с ErrInvalidPayload до того, как попадёт в хранилище.
+func Read(r io.Reader, format string) (*repository.Snapshot, error) {
+	var snapshot *repository.Snapshot
+	var err error
+	switch format {
+	case FormatJSON:
+		snapshot = &repository.Snapshot{}
+		err = json.NewDecoder(r).Decode(snapshot)
+	case FormatCSV:
+		snapshot, err = readCSV(r)
+	case FormatPrometheus:
+		snapshot, err = readPrometheus(r)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownFormat, format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateSnapshot(snapshot); err != nil {
+		return nil, err
+	}
+
+	return snapshot, nil
+}
+
+func validateSnapshot(snapshot *repository.Snapshot) error {
+	for name, s := range snapshot.Summaries {
+		if err := s.Validate(); err != nil {
+			return fmt.Errorf("%w: summary %q: %w", ErrInvalidPayload, name, err)
+		}
+	}
+	for name, s := range snapshot.Sets {
+		if err := s.Validate(); err != nil {
+			return fmt.Errorf("%w: set %q: %w", ErrInvalidPayload, name, err)
+		}
+	}
+
+	return nil
+}
+
+// writeCSV пишет строки type,name,value. Скетчи summary и set кладутся в value как JSON,
+// поэтому CSV переносит все типы без потерь.
+func writeCSV(ctx context.Context, repo repository.MetricRepository, w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+
+	err := repository.Each(ctx, repo, repository.ListOptions{}, func(m models.Metrics) error {
+		var value string
+		switch m.MType {
+		case models.Gauge:
+			value = strconv.FormatFloat(*m.Value, 'f', -1, 64)
+		case models.Counter:
+			value = strconv.FormatInt(*m.Delta, 10)
+		case models.Summary:
+			data, err := json.Marshal(m.Sketch)
+			if err != nil {
+				return err
+			}
+			value = string(data)
+		case models.Set:
+			data, err := json.Marshal(m.HLL)
+			if err != nil {
+				return err
+			}
+			value = string(data)
+		}
+
+		return cw.Write([]string{m.MType, m.ID, value})
+	})
+	if err != nil {
+		return err
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+func readCSV(r io.Reader) (*repository.Snapshot, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = len(csvHeader)
+
+	records, err := cr.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := newSnapshot()
+	for i, record := range records {
+		if i == 0 && record[0] == csvHeader[0] {
+			continue
+		}
+
+		metricType, name, value := record[0], record[1], record[2]
+		switch metricType {
+		case models.Gauge:
+			v, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", i+1, err)
+			}
+			snapshot.Gauges[name] = v
+		case models.Counter:
+			v, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", i+1, err)
+			}
+			snapshot.Counters[name] = v
+		case models.Summary:
+			var s sketch.DDSketch
+			if err := json.Unmarshal([]byte(value), &s); err != nil {
+				return nil, fmt.Errorf("line %d: %w", i+1, err)
+			}
+			snapshot.Summaries[name] = &s
+		case models.Set:
+			var s sketch.HyperLogLog
+			if err := json.Unmarshal([]byte(value), &s); err != nil {
+				return nil, fmt.Errorf("line %d: %w", i+1, err)
+			}
+			snapshot.Sets[name] = &s
+		default:
+			return nil, fmt.Errorf("line %d: unknown metric type %q", i+1, metricType)
+		}
+	}
+
+	return snapshot, nil
+}
+
+// writePrometheus пишет текстовый формат экспозиции. Set выгружается как gauge
+// с оценкой мощности, summary - квантилями, суммой и числом наблюдений. Формат
+// предназначен для чтения сторонними системами и обратно загружается с потерями,
+// поэтому для резервных копий нужен json или csv.
+func writePrometheus(ctx context.Context, repo repository.MetricRepository, w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	err := repository.Each(ctx, repo, repository.ListOptions{}, func(m models.Metrics) error {
+		metric := PrometheusName(m.ID)
+		switch m.MType {
+		case models.Gauge:
+			fmt.Fprintf(bw, "# TYPE %s gauge\n%s %s\n", metric, metric, formatFloat(*m.Value))
+		case models.Counter:
+			fmt.Fprintf(bw, "# TYPE %s counter\n%s %d\n", metric, metric, *m.Delta)
+		case models.Summary:
+			fmt.Fprintf(bw, "# TYPE %s summary\n", metric)
+			for _, q := range summaryQuantiles {
+				value, err := m.Sketch.Quantile(q)
+				if err != nil {
+					continue
+				}
+				fmt.Fprintf(bw, "%s{quantile=\"%s\"} %s\n", metric, formatFloat(q), formatFloat(value))
+			}
+			fmt.Fprintf(bw, "%s_sum %s\n%s_count %d\n", metric, formatFloat(m.Sketch.Sum), metric, m.Sketch.Count)
+		case models.Set:
+			fmt.Fprintf(bw, "# TYPE %s gauge\n%s %d\n", metric, metric, m.HLL.Estimate())
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return bw.Flush()
+}
+
+// readPrometheus читает gauge и counter. Summary из текстового формата не восстановить,
+// поэтому такие серии, как и строки с метками, пропускаются.
+func readPrometheus(r io.Reader) (*repository.Snapshot, error) {
+	snapshot := newSnapshot()
+	types := make(map[string]string)
+
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		if strings.HasPrefix(text, "#") {
+			fields := strings.Fields(text)
+			if len(fields) == 4 && fields[1] == "TYPE" {
+				types[fields[2]] = fields[3]
+			}
+			continue
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("line %d: malformed sample", line)
+		}
+		name := fields[0]
+		if strings.Contains(name, "{") || isSummarySeries(types, name) {
+			continue
+		}
+
+		switch types[name] {
+		case "counter":
+			v, err := parseCounter(fields[1])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			snapshot.Counters[name] = v
+		case "gauge", "untyped", "":
+			v, err := strconv.ParseFloat(fields[1], 64)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			snapshot.Gauges[name] = v
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return snapshot, nil
+}
+
+// parseCounter читает значение counter-а без потерь: целое как есть, а запись вроде
+// 1e+06 - только если это целое число в пределах int64.
+func parseCounter(value string) (int64, error) {
+	if v, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return v, nil
+	}
+
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, err
+	}
+	if v != math.Trunc(v) || v < math.MinInt64 || v >= math.MaxInt64 {
+		return 0, fmt.Errorf("%w: counter value %s is not an exact integer", ErrInvalidPayload, value)
+	}
+
+	return int64(v), nil
+}
+
+func isSummarySeries(types map[string]string, name string) bool {
+	for _, suffix := range []string{"_sum", "_count", "_bucket"} {
+		base, ok := strings.CutSuffix(name, suffix)
+		if !ok {
+			continue
+		}
+		if t := types[base]; t == "summary" || t == "histogram" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// PrometheusName заменяет символы, недопустимые в имени метрики Prometheus, на '_'.
+func PrometheusName(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_', r == ':':
+			b.WriteRune(r)
+		case r >= '0' && r <= '9' && i > 0:
+			b.WriteRune(r)
+		default:
+			b.WriteRune('_')
+		}
+	}
+
+	return b.String()
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func newSnapshot() *repository.Snapshot {
+	return &repository.Snapshot{
+		Gauges:    make(map[string]float64),
+		Counters:  make(map[string]int64),
+		Summaries: make(map[string]*sketch.DDSketch),
+		Sets:      make(map[string]*sketch.HyperLogLog),
+	}
+}
@@ -0,0 +1,145 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"github.com/Guram-Gurych/metricserver.git/internal/repository"
+	"github.com/Guram-Gurych/metricserver.git/internal/sketch"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func newTestRepo(t *testing.T) repository.MetricRepository {
+	ctx := context.Background()
+	repo := repository.NewMemStorage()
+
+	require.NoError(t, repo.UpdateGauge(ctx, "Alloc", 1.5))
+	require.NoError(t, repo.UpdateCounter(ctx, "PollCount", 7))
+
+	summary := sketch.NewDDSketch(sketch.DefaultRelativeAccuracy)
+	for i := 1; i <= 100; i++ {
+		require.NoError(t, summary.Add(float64(i)))
+	}
+	require.NoError(t, repo.UpdateSummary(ctx, "Latency", summary))
+
+	set := sketch.NewHyperLogLog(sketch.DefaultPrecision)
+	set.Add("alice")
+	set.Add("bob")
+	require.NoError(t, repo.UpdateSet(ctx, "Users", set))
+
+	return repo
+}
+
+func TestExportImport_RoundTrip(t *testing.T) {
+	for _, format := range []string{FormatJSON, FormatCSV} {
+		t.Run(format, func(t *testing.T) {
+			ctx := context.Background()
+			src := newTestRepo(t)
+
+			var buf bytes.Buffer
+			require.NoError(t, Export(ctx, src, &buf, format))
+
+			dst := repository.NewMemStorage()
+			require.NoError(t, Import(ctx, dst, &buf, format, repository.RestoreReplace))
+
+			expected, err := repository.TakeSnapshot(ctx, src)
+			require.NoError(t, err)
+			actual, err := repository.TakeSnapshot(ctx, dst)
+			require.NoError(t, err)
+			// CSV не хранит время обновления, сравниваются только значения.
+			expected.UpdatedAt, actual.UpdatedAt = nil, nil
+			assert.Equal(t, expected, actual, "Снимок после импорта не совпадает с исходным")
+		})
+	}
+}
+
+func TestExport_Prometheus(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepo(t)
+	require.NoError(t, repo.UpdateGauge(ctx, "cpu.load-1", 0.25))
+
+	var buf bytes.Buffer
+	require.NoError(t, Export(ctx, repo, &buf, FormatPrometheus))
+
+	out := buf.String()
+	assert.Contains(t, out, "# TYPE Alloc gauge\nAlloc 1.5\n")
+	assert.Contains(t, out, "# TYPE cpu_load_1 gauge\ncpu_load_1 0.25\n")
+	assert.Contains(t, out, "# TYPE PollCount counter\nPollCount 7\n")
+	assert.Contains(t, out, "# TYPE Latency summary\n")
+	assert.Contains(t, out, "Latency_count 100\n")
+	assert.Contains(t, out, "# TYPE Users gauge\nUsers 2\n")
+
+	dst := repository.NewMemStorage()
+	err := Import(ctx, dst, strings.NewReader(out), FormatPrometheus, repository.RestoreReplace)
+	require.ErrorIs(t, err, ErrLossyReplace, "Replace из Prometheus стёр бы summary и set")
+	require.NoError(t, Import(ctx, dst, strings.NewReader(out), FormatPrometheus, repository.RestoreMerge))
+
+	counter, err := dst.GetCounter(ctx, "PollCount")
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), counter)
+
+	gauge, err := dst.GetGauge(ctx, "cpu_load_1")
+	require.NoError(t, err)
+	assert.Equal(t, 0.25, gauge)
+
+	_, err = dst.GetGauge(ctx, "Latency_count")
+	assert.ErrorIs(t, err, repository.ErrNotFound, "Серии summary не должны импортироваться")
+}
+
+func TestExport_UnknownFormat(t *testing.T) {
+	var buf bytes.Buffer
+	assert.ErrorIs(t, Export(context.Background(), repository.NewMemStorage(), &buf, "xml"), ErrUnknownFormat)
+
+	_, err := Read(strings.NewReader(""), "xml")
+	assert.ErrorIs(t, err, ErrUnknownFormat)
+}
+
+func TestRead_InvalidSketches(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		body   string
+	}{
+		{name: "summary null", format: FormatJSON, body: `{"summaries":{"x":null}}`},
+		{name: "set null", format: FormatJSON, body: `{"sets":{"x":null}}`},
+		{name: "Точность summary вне (0, 1)", format: FormatJSON, body: `{"summaries":{"x":{"relative_accuracy":2,"count":0}}}`},
+		{name: "Count summary не совпадает с бакетами", format: FormatJSON, body: `{"summaries":{"x":{"relative_accuracy":0.01,"zero":1,"count":3}}}`},
+		{name: "Регистры set не совпадают с точностью", format: FormatJSON, body: `{"sets":{"x":{"precision":14,"registers":"AAAA"}}}`},
+		{name: "CSV с null", format: FormatCSV, body: "type,name,value\nsummary,x,null\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Read(strings.NewReader(test.body), test.format)
+			assert.ErrorIs(t, err, ErrInvalidPayload)
+		})
+	}
+}
+
+func TestRead_PrometheusCounter(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected int64
+		wantErr  bool
+	}{
+		{name: "Целое больше 2^53", value: "9007199254740993", expected: 9007199254740993},
+		{name: "Экспоненциальная запись", value: "1e+06", expected: 1000000},
+		{name: "Дробное", value: "1.5", wantErr: true},
+		{name: "Вне int64", value: "1e+19", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			snapshot, err := Read(strings.NewReader("# TYPE hits counter\nhits "+test.value+"\n"), FormatPrometheus)
+			if test.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, snapshot.Counters["hits"])
+		})
+	}
+}
@@ -0,0 +1,212 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/Guram-Gurych/metricserver.git/internal/export"
+	"github.com/Guram-Gurych/metricserver.git/internal/logger"
+	"github.com/Guram-Gurych/metricserver.git/internal/model"
+	"github.com/Guram-Gurych/metricserver.git/internal/repository"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+	"net/http"
+	"path"
+)
+
+// maxImportBodySize ограничивает тело /admin/import: снимок читается в память целиком.
+const maxImportBodySize = 256 << 20
+
+var metricTypes = []string{models.Gauge, models.Counter, models.Summary, models.Set}
+
+type deleteResponse struct {
+	Deleted int `json:"deleted"`
+}
+
+func (h *MetricHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	metricType := chi.URLParam(r, "metricType")
+	metricName := chi.URLParam(r, "metricName")
+
+	if !isMetricType(metricType) {
+		http.Error(w, "Invalid metric type", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.deleteMetric(r.Context(), metricType, metricName); err != nil {
+		writeRepoError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+}
+
+// DeleteBulk удаляет все метрики, имена которых подходят под шаблон path.Match.
+// Если тип не указан, удаление идёт по всем типам.
+func (h *MetricHandler) DeleteBulk(w http.ResponseWriter, r *http.Request) {
+	pattern := r.URL.Query().Get("pattern")
+	if pattern == "" {
+		http.Error(w, "Bad Request: pattern is required", http.StatusBadRequest)
+		return
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		http.Error(w, "Bad Request: Invalid pattern", http.StatusBadRequest)
+		return
+	}
+
+	opts := repository.ListOptions{Match: pattern}
+	if metricType := r.URL.Query().Get("type"); metricType != "" {
+		if !isMetricType(metricType) {
+			http.Error(w, "Bad Request: Invalid metric type", http.StatusBadRequest)
+			return
+		}
+		opts.Type = metricType
+	}
+
+	ctx := r.Context()
+	var resp deleteResponse
+	err := repository.Each(ctx, h.repo, opts, func(m models.Metrics) error {
+		err := h.deleteMetric(ctx, m.MType, m.ID)
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		resp.Deleted++
+		return nil
+	})
+	if err != nil {
+		writeRepoError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logger.Log.Error("Failed to encode response", zap.Error(err))
+	}
+}
+
+func (h *MetricHandler) ResetCounter(w http.ResponseWriter, r *http.Request) {
+	metricName := chi.URLParam(r, "metricName")
+
+	if err := h.repo.ResetCounter(r.Context(), metricName); err != nil {
+		writeRepoError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+}
+
+// Export выгружает весь репозиторий в формате из параметра format (по умолчанию json).
+func (h *MetricHandler) Export(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = export.FormatJSON
+	}
+	if !export.ValidFormat(format) {
+		http.Error(w, "Bad Request: Invalid format", http.StatusBadRequest)
+		return
+	}
+
+	ew := &exportWriter{w: w, contentType: export.ContentType(format)}
+	if err := export.Export(r.Context(), h.repo, ew, format); err != nil {
+		if !ew.started {
+			writeRepoError(w, err)
+			return
+		}
+		// Заголовки уже ушли клиенту: остаётся оборвать выгрузку и записать причину в лог.
+		logger.Log.Error("Failed to write export", zap.String("format", format), zap.Error(err))
+		return
+	}
+	if !ew.started {
+		// Пустая выгрузка: заголовки всё равно должны уйти.
+		ew.Write(nil)
+	}
+}
+
+// exportWriter пишет выгрузку прямо в ответ и отправляет заголовки только с первыми данными,
+// чтобы ошибку до начала выгрузки ещё можно было вернуть кодом ответа.
+type exportWriter struct {
+	w           http.ResponseWriter
+	contentType string
+	started     bool
+}
+
+func (ew *exportWriter) Write(p []byte) (int, error) {
+	if !ew.started {
+		ew.w.Header().Set("Content-Type", ew.contentType)
+		ew.w.WriteHeader(http.StatusOK)
+		ew.started = true
+	}
+	return ew.w.Write(p)
+}
+
+// Import загружает метрики из тела запроса. mode=replace заменяет содержимое
+// репозитория, mode=merge (по умолчанию) накладывает данные поверх текущих.
+func (h *MetricHandler) Import(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = export.FormatJSON
+	}
+	if !export.ValidFormat(format) {
+		http.Error(w, "Bad Request: Invalid format", http.StatusBadRequest)
+		return
+	}
+
+	mode := repository.RestoreMerge
+	if m := r.URL.Query().Get("mode"); m != "" {
+		parsed, err := repository.ParseRestoreMode(m)
+		if err != nil {
+			http.Error(w, "Bad Request: Invalid mode", http.StatusBadRequest)
+			return
+		}
+		mode = parsed
+	}
+	if err := export.CheckImportMode(format, mode); err != nil {
+		http.Error(w, "Bad Request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	snapshot, err := export.Read(http.MaxBytesReader(w, r.Body, maxImportBodySize), format)
+	if err != nil {
+		writeBodyError(w, err, "Bad Request: Invalid body")
+		return
+	}
+
+	if err := h.repo.Restore(r.Context(), *snapshot, mode); err != nil {
+		writeRepoError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *MetricHandler) deleteMetric(ctx context.Context, metricType, name string) error {
+	switch metricType {
+	case models.Gauge:
+		return h.repo.DeleteGauge(ctx, name)
+	case models.Counter:
+		return h.repo.DeleteCounter(ctx, name)
+	case models.Summary:
+		return h.repo.DeleteSummary(ctx, name)
+	case models.Set:
+		return h.repo.DeleteSet(ctx, name)
+	}
+
+	return repository.ErrNotFound
+}
+
+func isMetricType(metricType string) bool {
+	for _, t := range metricTypes {
+		if t == metricType {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,213 @@
+package handler
+
+import (
+	"errors"
+	"github.com/Guram-Gurych/metricserver.git/internal/model"
+	"github.com/Guram-Gurych/metricserver.git/internal/repository"
+	"github.com/Guram-Gurych/metricserver.git/internal/repository/mocks"
+	"github.com/go-chi/chi/v5"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetricHandler_Delete(t *testing.T) {
+	tests := []struct {
+		name           string
+		method         string
+		url            string
+		setupMock      func(mockRepo *mocks.MockMetricRepository)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:   "Удаление gauge",
+			method: http.MethodDelete,
+			url:    "/value/gauge/Alloc",
+			setupMock: func(mockRepo *mocks.MockMetricRepository) {
+				mockRepo.EXPECT().DeleteGauge(gomock.Any(), "Alloc").Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:   "Удаление несуществующего counter",
+			method: http.MethodDelete,
+			url:    "/value/counter/Unknown",
+			setupMock: func(mockRepo *mocks.MockMetricRepository) {
+				mockRepo.EXPECT().DeleteCounter(gomock.Any(), "Unknown").Return(repository.ErrNotFound)
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "Неверный тип метрики",
+			method:         http.MethodDelete,
+			url:            "/value/invalid/Alloc",
+			setupMock:      func(mockRepo *mocks.MockMetricRepository) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "Массовое удаление по шаблону",
+			method: http.MethodDelete,
+			url:    "/value/?type=gauge&pattern=Heap*",
+			setupMock: func(mockRepo *mocks.MockMetricRepository) {
+				mockRepo.EXPECT().List(gomock.Any(), repository.ListOptions{Type: models.Gauge, Match: "Heap*", Limit: 1000}).Return(&repository.ListPage{
+					Metrics: []models.Metrics{{ID: "HeapAlloc", MType: models.Gauge}, {ID: "HeapSys", MType: models.Gauge}},
+				}, nil)
+				mockRepo.EXPECT().DeleteGauge(gomock.Any(), "HeapAlloc").Return(nil)
+				mockRepo.EXPECT().DeleteGauge(gomock.Any(), "HeapSys").Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"deleted":2}`,
+		},
+		{
+			name:           "Массовое удаление без шаблона",
+			method:         http.MethodDelete,
+			url:            "/value/",
+			setupMock:      func(mockRepo *mocks.MockMetricRepository) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "Сброс counter",
+			method: http.MethodPost,
+			url:    "/reset/counter/PollCount",
+			setupMock: func(mockRepo *mocks.MockMetricRepository) {
+				mockRepo.EXPECT().ResetCounter(gomock.Any(), "PollCount").Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockRepo := mocks.NewMockMetricRepository(ctrl)
+			handler := NewMetricHandler(mockRepo, nil)
+			test.setupMock(mockRepo)
+
+			req := httptest.NewRequest(test.method, test.url, nil)
+			rec := httptest.NewRecorder()
+
+			router := chi.NewRouter()
+			router.Delete("/value/{metricType}/{metricName}", handler.Delete)
+			router.Delete("/value/", handler.DeleteBulk)
+			router.Post("/reset/counter/{metricName}", handler.ResetCounter)
+			router.ServeHTTP(rec, req)
+
+			assert.Equal(t, test.expectedStatus, rec.Code, "Код ответа не совпадает")
+
+			if test.expectedBody != "" {
+				assert.JSONEq(t, test.expectedBody, rec.Body.String(), "Тело ответа не совпадает")
+			}
+		})
+	}
+}
+
+func TestMetricHandler_ExportImport(t *testing.T) {
+	tests := []struct {
+		name           string
+		method         string
+		url            string
+		body           string
+		setupMock      func(mockRepo *mocks.MockMetricRepository)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:   "Экспорт в CSV",
+			method: http.MethodGet,
+			url:    "/admin/export?format=csv",
+			setupMock: func(mockRepo *mocks.MockMetricRepository) {
+				alloc, pollCount := 1.5, int64(3)
+				mockRepo.EXPECT().List(gomock.Any(), gomock.Any()).Return(&repository.ListPage{Metrics: []models.Metrics{
+					{ID: "Alloc", MType: models.Gauge, Value: &alloc},
+					{ID: "PollCount", MType: models.Counter, Delta: &pollCount},
+				}}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   "type,name,value\ngauge,Alloc,1.5\ncounter,PollCount,3\n",
+		},
+		{
+			name:   "Ошибка хранилища до начала выгрузки",
+			method: http.MethodGet,
+			url:    "/admin/export",
+			setupMock: func(mockRepo *mocks.MockMetricRepository) {
+				mockRepo.EXPECT().List(gomock.Any(), gomock.Any()).Return(nil, errors.New("storage is down"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+		{
+			name:           "Экспорт в неизвестный формат",
+			method:         http.MethodGet,
+			url:            "/admin/export?format=xml",
+			setupMock:      func(mockRepo *mocks.MockMetricRepository) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "Импорт JSON с заменой",
+			method: http.MethodPost,
+			url:    "/admin/import?mode=replace",
+			body:   `{"gauges":{"Alloc":1.5},"counters":{"PollCount":3}}`,
+			setupMock: func(mockRepo *mocks.MockMetricRepository) {
+				mockRepo.EXPECT().Restore(gomock.Any(), repository.Snapshot{
+					Gauges:   map[string]float64{"Alloc": 1.5},
+					Counters: map[string]int64{"PollCount": 3},
+				}, repository.RestoreReplace).Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Импорт с неверным режимом",
+			method:         http.MethodPost,
+			url:            "/admin/import?mode=append",
+			body:           `{}`,
+			setupMock:      func(mockRepo *mocks.MockMetricRepository) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Импорт битого CSV",
+			method:         http.MethodPost,
+			url:            "/admin/import?format=csv",
+			body:           "type,name,value\ngauge,Alloc,abc\n",
+			setupMock:      func(mockRepo *mocks.MockMetricRepository) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Импорт summary null",
+			method:         http.MethodPost,
+			url:            "/admin/import",
+			body:           `{"summaries":{"x":null}}`,
+			setupMock:      func(mockRepo *mocks.MockMetricRepository) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockRepo := mocks.NewMockMetricRepository(ctrl)
+			handler := NewMetricHandler(mockRepo, nil)
+			test.setupMock(mockRepo)
+
+			req := httptest.NewRequest(test.method, test.url, strings.NewReader(test.body))
+			rec := httptest.NewRecorder()
+
+			router := chi.NewRouter()
+			router.Get("/admin/export", handler.Export)
+			router.Post("/admin/import", handler.Import)
+			router.ServeHTTP(rec, req)
+
+			assert.Equal(t, test.expectedStatus, rec.Code, "Код ответа не совпадает")
+
+			if test.expectedBody != "" {
+				assert.Equal(t, test.expectedBody, rec.Body.String(), "Тело ответа не совпадает")
+			}
+		})
+	}
+}
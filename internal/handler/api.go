@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"encoding/json"
+	"github.com/Guram-Gurych/metricserver.git/internal/logger"
+	"github.com/Guram-Gurych/metricserver.git/internal/model"
+	"github.com/Guram-Gurych/metricserver.git/internal/repository"
+	"go.uber.org/zap"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultListLimit = 100
+	maxListLimit     = 1000
+)
+
+type listResponse struct {
+	Metrics    []models.Metrics `json:"metrics"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+}
+
+// ListMetrics отдаёт страницу метрик в JSON. Фильтры type, prefix и match (шаблон path.Match)
+// и курсор передаются в List как есть; summary и set отдаются так же, как в /value/.
+func (h *MetricHandler) ListMetrics(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	opts := repository.ListOptions{
+		Type:   query.Get("type"),
+		Prefix: query.Get("prefix"),
+		Match:  query.Get("match"),
+		Cursor: query.Get("cursor"),
+		Limit:  defaultListLimit,
+	}
+	if limit := query.Get("limit"); limit != "" {
+		val, err := strconv.Atoi(limit)
+		if err != nil || val <= 0 || val > maxListLimit {
+			http.Error(w, "Bad Request: Invalid limit", http.StatusBadRequest)
+			return
+		}
+		opts.Limit = val
+	}
+
+	var quantile *float64
+	if qStr := query.Get("q"); qStr != "" {
+		parsed, err := strconv.ParseFloat(qStr, 64)
+		if err != nil || parsed < 0 || parsed > 1 {
+			http.Error(w, "Bad Request: Invalid quantile", http.StatusBadRequest)
+			return
+		}
+		quantile = &parsed
+	}
+
+	page, err := h.repo.List(r.Context(), opts)
+	if err != nil {
+		writeRepoError(w, err)
+		return
+	}
+
+	resp := listResponse{Metrics: make([]models.Metrics, 0, len(page.Metrics)), NextCursor: page.NextCursor}
+	for _, m := range page.Metrics {
+		switch m.MType {
+		case models.Summary:
+			m.Quantile = quantile
+			if !fillSummary(w, &m, m.Sketch) {
+				return
+			}
+		case models.Set:
+			fillSet(&m, m.HLL)
+		}
+		m.Stale = h.ttl > 0 && m.UpdatedAt != nil && time.Since(*m.UpdatedAt) > h.ttl
+		resp.Metrics = append(resp.Metrics, m)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logger.Log.Error("Failed to encode response", zap.Error(err))
+	}
+}
@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"github.com/Guram-Gurych/metricserver.git/internal/model"
+	"github.com/Guram-Gurych/metricserver.git/internal/repository"
+	"github.com/Guram-Gurych/metricserver.git/internal/repository/mocks"
+	"github.com/Guram-Gurych/metricserver.git/internal/sketch"
+	"github.com/go-chi/chi/v5"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMetricHandler_ListMetrics(t *testing.T) {
+	updatedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	alloc := 1.5
+	summary := sketch.NewDDSketch(sketch.DefaultRelativeAccuracy)
+	summary.Add(10)
+
+	tests := []struct {
+		name           string
+		url            string
+		setupMock      func(mockRepo *mocks.MockMetricRepository)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name: "Страница с фильтрами и курсором",
+			url:  "/api/v1/metrics?type=gauge&prefix=A&match=A*&limit=1&cursor=abc",
+			setupMock: func(mockRepo *mocks.MockMetricRepository) {
+				mockRepo.EXPECT().List(gomock.Any(), repository.ListOptions{
+					Type: models.Gauge, Prefix: "A", Match: "A*", Cursor: "abc", Limit: 1,
+				}).Return(&repository.ListPage{
+					Metrics:    []models.Metrics{{ID: "Alloc", MType: models.Gauge, Value: &alloc, UpdatedAt: &updatedAt}},
+					NextCursor: "next",
+				}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"metrics":[{"id":"Alloc","type":"gauge","value":1.5,"updated_at":"2024-01-02T03:04:05Z"}],"next_cursor":"next"}`,
+		},
+		{
+			name: "Summary отдаётся квантилем",
+			url:  "/api/v1/metrics?type=summary&q=0.5",
+			setupMock: func(mockRepo *mocks.MockMetricRepository) {
+				mockRepo.EXPECT().List(gomock.Any(), gomock.Any()).Return(&repository.ListPage{
+					Metrics: []models.Metrics{{ID: "Latency", MType: models.Summary, Sketch: summary}},
+				}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"metrics":[{"id":"Latency","type":"summary","delta":1,"value":10,"quantile":0.5}]}`,
+		},
+		{
+			name:           "Неверный лимит",
+			url:            "/api/v1/metrics?limit=0",
+			setupMock:      func(mockRepo *mocks.MockMetricRepository) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Неверный курсор",
+			url:  "/api/v1/metrics?cursor=bad",
+			setupMock: func(mockRepo *mocks.MockMetricRepository) {
+				mockRepo.EXPECT().List(gomock.Any(), gomock.Any()).Return(nil, repository.ErrInvalidCursor)
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockRepo := mocks.NewMockMetricRepository(ctrl)
+			handler := NewMetricHandler(mockRepo, nil)
+			test.setupMock(mockRepo)
+
+			req := httptest.NewRequest(http.MethodGet, test.url, nil)
+			rec := httptest.NewRecorder()
+
+			router := chi.NewRouter()
+			router.Get("/api/v1/metrics", handler.ListMetrics)
+			router.ServeHTTP(rec, req)
+
+			assert.Equal(t, test.expectedStatus, rec.Code, "Код ответа не совпадает")
+
+			if test.expectedBody != "" {
+				assert.JSONEq(t, test.expectedBody, rec.Body.String(), "Тело ответа не совпадает")
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/Guram-Gurych/metricserver.git/internal/logger"
+	"github.com/Guram-Gurych/metricserver.git/internal/model"
+	"github.com/Guram-Gurych/metricserver.git/internal/repository"
+	"go.uber.org/zap"
+	"net/http"
+	"sync"
+)
+
+const (
+	// BatchIDHeader - идентификатор пакета /updates/; повтор пакета с тем же идентификатором не применяется.
+	BatchIDHeader = "X-Batch-ID"
+
+	recentBatchesSize = 4096
+)
+
+type batchResponse struct {
+	Updated   int  `json:"updated"`
+	Duplicate bool `json:"duplicate,omitempty"`
+}
+
+// recentBatches помнит идентификаторы последних применённых пакетов. Relay повторяет пакет,
+// если не дождался ответа, и без этого counter-ы из такого пакета учитывались бы дважды.
+// Пакет, который ещё применяется, тоже отмечен, чтобы параллельный повтор не применил его второй раз.
+type recentBatches struct {
+	mu       sync.Mutex
+	applied  map[string]struct{}
+	inflight map[string]struct{}
+	order    []string
+	next     int
+}
+
+type batchState int
+
+const (
+	batchNew batchState = iota
+	batchInFlight
+	batchApplied
+)
+
+func newRecentBatches(size int) *recentBatches {
+	return &recentBatches{
+		applied:  make(map[string]struct{}, size),
+		inflight: make(map[string]struct{}),
+		order:    make([]string, size),
+	}
+}
+
+// claim атомарно проверяет пакет и, если он новый, помечает его как применяемый.
+func (rb *recentBatches) claim(id string) batchState {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	if _, ok := rb.applied[id]; ok {
+		return batchApplied
+	}
+	if _, ok := rb.inflight[id]; ok {
+		return batchInFlight
+	}
+	rb.inflight[id] = struct{}{}
+
+	return batchNew
+}
+
+// finish снимает отметку claim; применённый пакет запоминается, неудачный можно прислать снова.
+func (rb *recentBatches) finish(id string, applied bool) {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	delete(rb.inflight, id)
+	if !applied {
+		return
+	}
+
+	if old := rb.order[rb.next]; old != "" {
+		delete(rb.applied, old)
+	}
+	rb.order[rb.next] = id
+	rb.applied[id] = struct{}{}
+	rb.next = (rb.next + 1) % len(rb.order)
+}
+
+// PostBatch принимает массив обновлений в JSON-формате /update/. До записи проверяются все
+// элементы, в том числе совместимость скетчей с уже сохранёнными, чтобы пакет не отклонился
+// на середине: relay не повторяет пакеты с ответом 400. Если передан X-Batch-ID, уже
+// применённый пакет подтверждается без записи, а применяемый прямо сейчас получает 409.
+func (h *MetricHandler) PostBatch(w http.ResponseWriter, r *http.Request) {
+	var batch []models.Metrics
+	if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+		http.Error(w, "Failed to decode request body", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	resp := batchResponse{}
+	batchID := r.Header.Get(BatchIDHeader)
+	if batchID != "" {
+		switch h.batches.claim(batchID) {
+		case batchApplied:
+			resp.Duplicate = true
+			writeBatchResponse(w, resp)
+			return
+		case batchInFlight:
+			http.Error(w, "Batch is being applied", http.StatusConflict)
+			return
+		}
+	}
+
+	applied := false
+	if batchID != "" {
+		defer func() { h.batches.finish(batchID, applied) }()
+	}
+
+	ctx := r.Context()
+	updates := make([]preparedUpdate, 0, len(batch))
+	for i := range batch {
+		u, err := prepareUpdate(&batch[i])
+		if err != nil {
+			writeUpdateError(w, err)
+			return
+		}
+		updates = append(updates, u)
+	}
+	if err := h.checkCompatible(ctx, updates); err != nil {
+		writeUpdateError(w, err)
+		return
+	}
+
+	for _, u := range updates {
+		if err := h.writeUpdate(ctx, u); err != nil {
+			writeUpdateError(w, err)
+			return
+		}
+		resp.Updated++
+	}
+
+	applied = true
+	writeBatchResponse(w, resp)
+}
+
+// checkCompatible проверяет, что скетчи пакета сольются с сохранёнными и друг с другом
+// по тем же правилам, что и Merge: пустой summary принимает точность первого слитого
+// в него скетча, а у set точность должна совпадать всегда.
+func (h *MetricHandler) checkCompatible(ctx context.Context, updates []preparedUpdate) error {
+	accuracies := make(map[string]float64)
+	precisions := make(map[string]uint8)
+
+	for _, u := range updates {
+		name := u.metrics.ID
+		switch {
+		case u.summary != nil:
+			if u.summary.Count == 0 {
+				continue
+			}
+			want, ok := accuracies[name]
+			if !ok {
+				current, err := h.repo.GetSummary(ctx, name)
+				if err != nil && !errors.Is(err, repository.ErrNotFound) {
+					return err
+				}
+				if err == nil && current.Count > 0 {
+					want, ok = current.RelativeAccuracy, true
+				}
+			}
+			if ok && want != u.summary.RelativeAccuracy {
+				return updateError("Bad Request: Incompatible sketch for summary " + name)
+			}
+			accuracies[name] = u.summary.RelativeAccuracy
+		case u.set != nil:
+			want, ok := precisions[name]
+			if !ok {
+				current, err := h.repo.GetSet(ctx, name)
+				if err != nil && !errors.Is(err, repository.ErrNotFound) {
+					return err
+				}
+				if err == nil {
+					want, ok = current.Precision, true
+				}
+			}
+			if ok && want != u.set.Precision {
+				return updateError("Bad Request: Incompatible sketch for set " + name)
+			}
+			precisions[name] = u.set.Precision
+		}
+	}
+
+	return nil
+}
+
+func writeBatchResponse(w http.ResponseWriter, resp batchResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logger.Log.Error("Failed to encode response", zap.Error(err))
+	}
+}
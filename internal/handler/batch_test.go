@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"github.com/Guram-Gurych/metricserver.git/internal/repository"
+	"github.com/Guram-Gurych/metricserver.git/internal/sketch"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetricHandler_PostBatch(t *testing.T) {
+	tests := []struct {
+		name            string
+		body            string
+		expectedStatus  int
+		expectedBody    string
+		expectedCounter int64
+	}{
+		{
+			name:            "Gauge и counter",
+			body:            `[{"id":"Alloc","type":"gauge","value":1.5},{"id":"PollCount","type":"counter","delta":3}]`,
+			expectedStatus:  http.StatusOK,
+			expectedBody:    `{"updated":2}`,
+			expectedCounter: 3,
+		},
+		{
+			name:           "Невалидный элемент не применяет пакет",
+			body:           `[{"id":"PollCount","type":"counter","delta":3},{"id":"Alloc","type":"gauge"}]`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Не массив",
+			body:           `{"id":"Alloc","type":"gauge","value":1.5}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			repo := repository.NewMemStorage()
+			h := NewMetricHandler(repo, nil)
+
+			req := httptest.NewRequest(http.MethodPost, "/updates/", strings.NewReader(test.body))
+			rr := httptest.NewRecorder()
+			h.PostBatch(rr, req)
+
+			assert.Equal(t, test.expectedStatus, rr.Code)
+			if test.expectedBody != "" {
+				assert.JSONEq(t, test.expectedBody, rr.Body.String())
+			}
+
+			counter, err := repo.GetCounter(req.Context(), "PollCount")
+			if test.expectedCounter == 0 {
+				assert.ErrorIs(t, err, repository.ErrNotFound, "Пакет с ошибкой не должен частично применяться")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedCounter, counter)
+		})
+	}
+}
+
+func TestMetricHandler_PostBatch_Duplicate(t *testing.T) {
+	repo := repository.NewMemStorage()
+	h := NewMetricHandler(repo, nil)
+	body := `[{"id":"PollCount","type":"counter","delta":5}]`
+
+	for i, expected := range []string{`{"updated":1}`, `{"updated":0,"duplicate":true}`} {
+		req := httptest.NewRequest(http.MethodPost, "/updates/", strings.NewReader(body))
+		req.Header.Set(BatchIDHeader, "relay-1")
+		rr := httptest.NewRecorder()
+		h.PostBatch(rr, req)
+
+		require.Equal(t, http.StatusOK, rr.Code, "Попытка %d", i+1)
+		assert.JSONEq(t, expected, rr.Body.String())
+	}
+
+	counter, err := repo.GetCounter(t.Context(), "PollCount")
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), counter, "Повтор пакета не должен учитываться дважды")
+}
+
+func TestMetricHandler_PostBatch_IncompatibleSketch(t *testing.T) {
+	tests := []struct {
+		name   string
+		stored func(repo repository.MetricRepository) error
+		body   string
+	}{
+		{
+			name: "Точность summary не совпадает с сохранённой",
+			stored: func(repo repository.MetricRepository) error {
+				s := sketch.NewDDSketch(0.05)
+				if err := s.Add(1); err != nil {
+					return err
+				}
+				return repo.UpdateSummary(t.Context(), "latency", s)
+			},
+			body: `[{"id":"PollCount","type":"counter","delta":3},{"id":"latency","type":"summary","value":2}]`,
+		},
+		{
+			name:   "Точность set различается внутри пакета",
+			stored: func(repository.MetricRepository) error { return nil },
+			body: `[{"id":"PollCount","type":"counter","delta":3},` +
+				`{"id":"users","type":"set","members":["a"]},` +
+				`{"id":"users","type":"set","hll":{"precision":10,"registers":"` + strings.Repeat("A", 1366) + `=="}}]`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			repo := repository.NewMemStorage()
+			require.NoError(t, test.stored(repo))
+			h := NewMetricHandler(repo, nil)
+
+			req := httptest.NewRequest(http.MethodPost, "/updates/", strings.NewReader(test.body))
+			req.Header.Set(BatchIDHeader, "relay-1")
+			rr := httptest.NewRecorder()
+			h.PostBatch(rr, req)
+
+			assert.Equal(t, http.StatusBadRequest, rr.Code)
+			_, err := repo.GetCounter(t.Context(), "PollCount")
+			assert.ErrorIs(t, err, repository.ErrNotFound, "Пакет с несовместимым скетчем не должен частично применяться")
+			_, err = repo.GetSet(t.Context(), "users")
+			assert.ErrorIs(t, err, repository.ErrNotFound)
+		})
+	}
+}
+
+func TestRecentBatches_Claim(t *testing.T) {
+	rb := newRecentBatches(1)
+
+	assert.Equal(t, batchNew, rb.claim("a"))
+	assert.Equal(t, batchInFlight, rb.claim("a"), "Применяемый пакет не должен применяться параллельно")
+
+	rb.finish("a", false)
+	assert.Equal(t, batchNew, rb.claim("a"), "Неудачный пакет можно прислать снова")
+
+	rb.finish("a", true)
+	assert.Equal(t, batchApplied, rb.claim("a"))
+
+	assert.Equal(t, batchNew, rb.claim("b"))
+	rb.finish("b", true)
+	assert.Equal(t, batchNew, rb.claim("a"), "Старый идентификатор вытесняется")
+}
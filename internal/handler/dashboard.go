@@ -0,0 +1,165 @@
+package handler
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"github.com/Guram-Gurych/metricserver.git/internal/logger"
+	"github.com/Guram-Gurych/metricserver.git/internal/model"
+	"github.com/Guram-Gurych/metricserver.git/internal/repository"
+	"go.uber.org/zap"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	dashboardPageSize = 500
+	dashboardRefresh  = 5 * time.Second
+	dashboardTime     = "2006-01-02T15:04:05.000Z"
+)
+
+//go:embed templates/dashboard.html
+var templatesFS embed.FS
+
+var dashboardTemplate = template.Must(template.ParseFS(templatesFS, "templates/dashboard.html"))
+
+type dashboardRow struct {
+	Name      string
+	Type      string
+	Value     string
+	UpdatedAt string
+	Stale     bool
+}
+
+type dashboardData struct {
+	Rows      []dashboardRow
+	Types     []string
+	Type      string
+	Search    string
+	NextURL   string
+	PollURL   string
+	StreamURL string
+	RefreshMs int64
+}
+
+// GetAllMetricsHTML отдаёт дашборд с одной страницей метрик. Параметры: search - подстрока
+// имени, type, prefix, limit и cursor. Значения на странице обновляются из /api/v1/stream,
+// а если браузер не поддерживает SSE или поток недоступен - опросом /api/v1/metrics.
+func (h *MetricHandler) GetAllMetricsHTML(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	opts := repository.ListOptions{
+		Type:   query.Get("type"),
+		Prefix: query.Get("prefix"),
+		Cursor: query.Get("cursor"),
+		Limit:  dashboardPageSize,
+	}
+	if search := query.Get("search"); search != "" {
+		opts.Match = "*" + escapeGlob(search) + "*"
+	}
+	if limit := query.Get("limit"); limit != "" {
+		val, err := strconv.Atoi(limit)
+		if err != nil || val <= 0 || val > maxListLimit {
+			http.Error(w, "Bad Request: Invalid limit", http.StatusBadRequest)
+			return
+		}
+		opts.Limit = val
+	}
+
+	page, err := h.repo.List(r.Context(), opts)
+	if err != nil {
+		writeRepoError(w, err)
+		return
+	}
+
+	data := dashboardData{
+		Rows:      make([]dashboardRow, 0, len(page.Metrics)),
+		Types:     repository.MetricTypes,
+		Type:      opts.Type,
+		Search:    query.Get("search"),
+		PollURL:   "/api/v1/metrics?" + listQuery(opts).Encode(),
+		StreamURL: "/api/v1/stream?" + streamQuery(opts).Encode(),
+		RefreshMs: dashboardRefresh.Milliseconds(),
+	}
+	for _, m := range page.Metrics {
+		row := dashboardRow{Name: m.ID, Type: m.MType, Value: formatMetricValue(m)}
+		if m.UpdatedAt != nil {
+			row.UpdatedAt = m.UpdatedAt.UTC().Format(dashboardTime)
+			row.Stale = h.ttl > 0 && time.Since(*m.UpdatedAt) > h.ttl
+		}
+		data.Rows = append(data.Rows, row)
+	}
+	if page.NextCursor != "" {
+		query.Set("cursor", page.NextCursor)
+		data.NextURL = "?" + query.Encode()
+	}
+
+	var buf bytes.Buffer
+	if err := dashboardTemplate.Execute(&buf, data); err != nil {
+		logger.Log.Error("Failed to render dashboard", zap.Error(err))
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
+
+// formatMetricValue совпадает с форматированием значений в скрипте дашборда.
+func formatMetricValue(m models.Metrics) string {
+	switch m.MType {
+	case models.Gauge:
+		return strconv.FormatFloat(*m.Value, 'g', -1, 64)
+	case models.Counter:
+		return strconv.FormatInt(*m.Delta, 10)
+	case models.Summary:
+		p50, _ := m.Sketch.Quantile(defaultQuantile)
+		return fmt.Sprintf("p50=%s count=%d", strconv.FormatFloat(p50, 'g', -1, 64), m.Sketch.Count)
+	case models.Set:
+		return strconv.FormatUint(m.HLL.Estimate(), 10)
+	}
+
+	return ""
+}
+
+func listQuery(opts repository.ListOptions) url.Values {
+	values := url.Values{}
+	for key, value := range map[string]string{"type": opts.Type, "prefix": opts.Prefix, "match": opts.Match, "cursor": opts.Cursor} {
+		if value != "" {
+			values.Set(key, value)
+		}
+	}
+	values.Set("limit", strconv.Itoa(opts.Limit))
+
+	return values
+}
+
+func streamQuery(opts repository.ListOptions) url.Values {
+	values := url.Values{}
+	if opts.Type != "" {
+		values.Set("type", opts.Type)
+	}
+	if opts.Match != "" {
+		values.Set("match", opts.Match)
+	}
+
+	return values
+}
+
+// escapeGlob экранирует спецсимволы path.Match, чтобы поиск шёл по подстроке буквально.
+func escapeGlob(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(`*?[]\`, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
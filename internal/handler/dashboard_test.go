@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"github.com/Guram-Gurych/metricserver.git/internal/model"
+	"github.com/Guram-Gurych/metricserver.git/internal/repository"
+	"github.com/Guram-Gurych/metricserver.git/internal/repository/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMetricHandler_GetAllMetricsHTML(t *testing.T) {
+	updatedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	value := 0.1
+	delta := int64(42)
+
+	tests := []struct {
+		name           string
+		url            string
+		setupMock      func(mockRepo *mocks.MockMetricRepository)
+		expectedStatus int
+		contains       []string
+		notContains    []string
+	}{
+		{
+			name: "Имена экранируются, значения без фиксированной точности",
+			url:  "/",
+			setupMock: func(mockRepo *mocks.MockMetricRepository) {
+				mockRepo.EXPECT().List(gomock.Any(), repository.ListOptions{Limit: dashboardPageSize}).Return(&repository.ListPage{
+					Metrics: []models.Metrics{
+						{ID: "<script>alert(1)</script>", MType: models.Gauge, Value: &value, UpdatedAt: &updatedAt},
+						{ID: "PollCount", MType: models.Counter, Delta: &delta},
+					},
+					NextCursor: "next",
+				}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			contains: []string{
+				"&lt;script&gt;alert(1)&lt;/script&gt;",
+				`<td class="value">0.1</td>`,
+				"2024-01-02T03:04:05.000Z",
+				`<td class="value">42</td>`,
+				`href="?cursor=next"`,
+				`<td class="trend"></td>`,
+				`addEventListener("delete"`,
+			},
+			notContains: []string{"<script>alert(1)</script>", "0.100000"},
+		},
+		{
+			name: "Поиск по подстроке и фильтр по типу",
+			url:  "/?search=heap*&type=gauge",
+			setupMock: func(mockRepo *mocks.MockMetricRepository) {
+				mockRepo.EXPECT().List(gomock.Any(), repository.ListOptions{
+					Type: models.Gauge, Match: `*heap\**`, Limit: dashboardPageSize,
+				}).Return(&repository.ListPage{}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			contains:       []string{"No metrics", `<option value="gauge" selected>`},
+		},
+		{
+			name:           "Неверный лимит",
+			url:            "/?limit=abc",
+			setupMock:      func(mockRepo *mocks.MockMetricRepository) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockRepo := mocks.NewMockMetricRepository(ctrl)
+			handler := NewMetricHandler(mockRepo, nil)
+			test.setupMock(mockRepo)
+
+			req := httptest.NewRequest(http.MethodGet, test.url, nil)
+			rec := httptest.NewRecorder()
+			handler.GetAllMetricsHTML(rec, req)
+
+			assert.Equal(t, test.expectedStatus, rec.Code, "Код ответа не совпадает")
+			for _, s := range test.contains {
+				assert.Contains(t, rec.Body.String(), s)
+			}
+			for _, s := range test.notContains {
+				assert.NotContains(t, rec.Body.String(), s)
+			}
+		})
+	}
+}
@@ -4,30 +4,110 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
-	"fmt"
+	"errors"
+	"github.com/Guram-Gurych/metricserver.git/internal/ingest/influx"
+	"github.com/Guram-Gurych/metricserver.git/internal/ingest/otlp"
+	"github.com/Guram-Gurych/metricserver.git/internal/ingest/remotewrite"
 	"github.com/Guram-Gurych/metricserver.git/internal/logger"
 	"github.com/Guram-Gurych/metricserver.git/internal/model"
 	"github.com/Guram-Gurych/metricserver.git/internal/repository"
+	"github.com/Guram-Gurych/metricserver.git/internal/scrape"
+	"github.com/Guram-Gurych/metricserver.git/internal/sketch"
+	"github.com/Guram-Gurych/metricserver.git/internal/stream"
 	"github.com/go-chi/chi/v5"
 	"go.uber.org/zap"
-	"io"
 	"net/http"
-	"sort"
 	"strconv"
 	"strings"
 	"time"
 )
 
+const (
+	defaultQuantile = 0.5
+
+	// DefaultMaxBodySize ограничивает тело запросов приёма метрик, если не задано иное.
+	DefaultMaxBodySize = 32 << 20
+)
+
 type MetricHandler struct {
 	repo repository.MetricRepository
 	db   *sql.DB
+	ttl  time.Duration
+	hub  *stream.Hub
+
+	batches     *recentBatches
+	maxBodySize int64
+
+	influx      *influx.Writer
+	otlp        *otlp.Receiver
+	remoteWrite *remotewrite.Receiver
+	scraper     *scrape.Scraper
 }
 
 func NewMetricHandler(repo repository.MetricRepository, db *sql.DB) *MetricHandler {
 	return &MetricHandler{
-		repo: repo,
-		db:   db,
+		repo:        repo,
+		db:          db,
+		batches:     newRecentBatches(recentBatchesSize),
+		maxBodySize: DefaultMaxBodySize,
+	}
+}
+
+// SetMaxBodySize задаёт предел тела для ручек приёма метрик (remote write, OTLP);
+// у сжатых форматов он же ограничивает размер после распаковки.
+func (h *MetricHandler) SetMaxBodySize(n int64) {
+	h.maxBodySize = n
+}
+
+// SetTTL задаёт время, после которого необновлявшаяся метрика помечается как устаревшая.
+func (h *MetricHandler) SetTTL(ttl time.Duration) {
+	h.ttl = ttl
+}
+
+func (h *MetricHandler) isStale(ctx context.Context, metricType, name string) bool {
+	if h.ttl <= 0 {
+		return false
+	}
+
+	updatedAt, err := h.repo.GetUpdatedAt(ctx, metricType, name)
+	return err == nil && time.Since(updatedAt) > h.ttl
+}
+
+// writeRepoError переводит ошибку хранилища в HTTP-статус.
+func writeRepoError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		http.Error(w, "Metric not found", http.StatusNotFound)
+	case errors.Is(err, sketch.ErrIncompatible):
+		http.Error(w, "Bad Request: Incompatible sketch", http.StatusBadRequest)
+	case errors.Is(err, repository.ErrEmptyName):
+		http.Error(w, "Bad Request: Metric name is required", http.StatusBadRequest)
+	case errors.Is(err, repository.ErrInvalidCursor):
+		http.Error(w, "Bad Request: Invalid cursor", http.StatusBadRequest)
+	case errors.Is(err, repository.ErrInvalidMetricType):
+		http.Error(w, "Bad Request: Invalid metric type", http.StatusBadRequest)
+	case errors.Is(err, repository.ErrInvalidPattern):
+		http.Error(w, "Bad Request: Invalid pattern", http.StatusBadRequest)
+	case errors.Is(err, context.DeadlineExceeded):
+		http.Error(w, "Storage timeout", http.StatusGatewayTimeout)
+	case errors.Is(err, context.Canceled):
+		http.Error(w, "Request canceled", http.StatusServiceUnavailable)
+	default:
+		logger.Log.Error("Storage error", zap.Error(err))
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
+
+// writeBodyError отвечает на ошибку разбора тела: 413, если тело упёрлось в
+// http.MaxBytesReader, иначе 400 с сообщением msg.
+func writeBodyError(w http.ResponseWriter, err error, msg string) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+		return
 	}
+
+	http.Error(w, msg, http.StatusBadRequest)
 }
 
 func (h *MetricHandler) Post(w http.ResponseWriter, r *http.Request) {
@@ -39,6 +119,7 @@ func (h *MetricHandler) Post(w http.ResponseWriter, r *http.Request) {
 	metricType := chi.URLParam(r, "metricType")
 	metricName := chi.URLParam(r, "metricName")
 	metricValue := chi.URLParam(r, "metricValue")
+	ctx := r.Context()
 
 	if metricName == "" {
 		http.Error(w, "Not Found: Metric name is required", http.StatusNotFound)
@@ -53,21 +134,38 @@ func (h *MetricHandler) Post(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "Bad Request: Invalid gauge value", http.StatusBadRequest)
 			return
 		}
-		err = h.repo.UpdateGauge(metricName, value)
+		err = h.repo.UpdateGauge(ctx, metricName, value)
 	case models.Counter:
 		value, parseErr := strconv.ParseInt(metricValue, 10, 64)
 		if parseErr != nil {
 			http.Error(w, "Bad Request: Invalid counter value", http.StatusBadRequest)
 			return
 		}
-		err = h.repo.UpdateCounter(metricName, value)
+		err = h.repo.UpdateCounter(ctx, metricName, value)
+	case models.Summary:
+		value, parseErr := strconv.ParseFloat(metricValue, 64)
+		if parseErr != nil {
+			http.Error(w, "Bad Request: Invalid summary value", http.StatusBadRequest)
+			return
+		}
+
+		s := sketch.NewDDSketch(sketch.DefaultRelativeAccuracy)
+		if addErr := s.Add(value); addErr != nil {
+			http.Error(w, "Bad Request: Invalid summary value", http.StatusBadRequest)
+			return
+		}
+		err = h.repo.UpdateSummary(ctx, metricName, s)
+	case models.Set:
+		s := sketch.NewHyperLogLog(sketch.DefaultPrecision)
+		s.Add(metricValue)
+		err = h.repo.UpdateSet(ctx, metricName, s)
 	default:
 		http.Error(w, "Bad Request: Invalid metric type", http.StatusBadRequest)
 		return
 	}
 
 	if err != nil {
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		writeRepoError(w, err)
 		return
 	}
 
@@ -83,46 +181,43 @@ func (h *MetricHandler) handlePostJSON(w http.ResponseWriter, r *http.Request) {
 	}
 	defer r.Body.Close()
 
-	var err error
+	ctx := r.Context()
+	if err := h.applyUpdate(ctx, &metrics); err != nil {
+		writeUpdateError(w, err)
+		return
+	}
+
 	switch metrics.MType {
 	case models.Gauge:
-		if metrics.Value == nil {
-			http.Error(w, "Bad Request: Invalid gauge value", http.StatusBadRequest)
-			return
-		}
-		err = h.repo.UpdateGauge(metrics.ID, *metrics.Value)
+		newValue, err := h.repo.GetGauge(ctx, metrics.ID)
 		if err != nil {
-			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-			return
-		}
-
-		newValue, ok := h.repo.GetGauge(metrics.ID)
-		if !ok {
-			http.Error(w, "Internal Server Error after update", http.StatusInternalServerError)
+			writeRepoError(w, err)
 			return
 		}
 		metrics.Value = &newValue
-
 	case models.Counter:
-		if metrics.Delta == nil {
-			http.Error(w, "Bad Request: Invalid counter value", http.StatusBadRequest)
+		newDelta, err := h.repo.GetCounter(ctx, metrics.ID)
+		if err != nil {
+			writeRepoError(w, err)
 			return
 		}
-		err = h.repo.UpdateCounter(metrics.ID, *metrics.Delta)
+		metrics.Delta = &newDelta
+	case models.Summary:
+		current, err := h.repo.GetSummary(ctx, metrics.ID)
 		if err != nil {
-			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			writeRepoError(w, err)
 			return
 		}
-
-		newDelta, ok := h.repo.GetCounter(metrics.ID)
-		if !ok {
-			http.Error(w, "Internal Server Error after update", http.StatusInternalServerError)
+		if !fillSummary(w, &metrics, current) {
 			return
 		}
-		metrics.Delta = &newDelta
-	default:
-		http.Error(w, "Bad Request: Invalid metric type", http.StatusBadRequest)
-		return
+	case models.Set:
+		current, err := h.repo.GetSet(ctx, metrics.ID)
+		if err != nil {
+			writeRepoError(w, err)
+			return
+		}
+		fillSet(&metrics, current)
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -133,6 +228,154 @@ func (h *MetricHandler) handlePostJSON(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// updateError - ошибка проверки обновления; текст уходит клиенту как есть.
+type updateError string
+
+func (e updateError) Error() string {
+	return string(e)
+}
+
+func writeUpdateError(w http.ResponseWriter, err error) {
+	var invalid updateError
+	if errors.As(err, &invalid) {
+		http.Error(w, invalid.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeRepoError(w, err)
+}
+
+// validateUpdate проверяет обновление до записи, чтобы пакет /updates/ не записался наполовину.
+func validateUpdate(metrics *models.Metrics) error {
+	if metrics.ID == "" {
+		return updateError("Bad Request: Metric name is required")
+	}
+
+	switch metrics.MType {
+	case models.Gauge:
+		if metrics.Value == nil {
+			return updateError("Bad Request: Invalid gauge value")
+		}
+	case models.Counter:
+		if metrics.Delta == nil {
+			return updateError("Bad Request: Invalid counter value")
+		}
+	case models.Summary:
+		if metrics.Sketch == nil && metrics.Value == nil {
+			return updateError("Bad Request: Invalid summary value")
+		}
+		// Испорченный скетч нельзя сохранять: с ним потом не сольётся ни одно обновление.
+		if metrics.Sketch != nil && metrics.Sketch.Validate() != nil {
+			return updateError("Bad Request: Invalid summary sketch")
+		}
+	case models.Set:
+		if metrics.HLL == nil && len(metrics.Members) == 0 {
+			return updateError("Bad Request: Invalid set value")
+		}
+		if metrics.HLL != nil && metrics.HLL.Validate() != nil {
+			return updateError("Bad Request: Invalid set sketch")
+		}
+	default:
+		return updateError("Bad Request: Invalid metric type")
+	}
+
+	return nil
+}
+
+// preparedUpdate - проверенное обновление с уже собранным скетчем, готовое к записи.
+type preparedUpdate struct {
+	metrics *models.Metrics
+	summary *sketch.DDSketch
+	set     *sketch.HyperLogLog
+}
+
+// prepareUpdate проверяет обновление и собирает скетч, ничего не записывая.
+func prepareUpdate(metrics *models.Metrics) (preparedUpdate, error) {
+	if err := validateUpdate(metrics); err != nil {
+		return preparedUpdate{}, err
+	}
+
+	u := preparedUpdate{metrics: metrics}
+	switch metrics.MType {
+	case models.Summary:
+		u.summary = metrics.Sketch
+		if u.summary == nil {
+			u.summary = sketch.NewDDSketch(sketch.DefaultRelativeAccuracy)
+			if err := u.summary.Add(*metrics.Value); err != nil {
+				return preparedUpdate{}, updateError("Bad Request: Invalid summary value")
+			}
+		}
+	case models.Set:
+		precision := uint8(sketch.DefaultPrecision)
+		if metrics.HLL != nil {
+			precision = metrics.HLL.Precision
+		}
+
+		u.set = sketch.NewHyperLogLog(precision)
+		for _, member := range metrics.Members {
+			u.set.Add(member)
+		}
+		if err := u.set.Merge(metrics.HLL); err != nil {
+			return preparedUpdate{}, err
+		}
+	}
+
+	return u, nil
+}
+
+func (h *MetricHandler) writeUpdate(ctx context.Context, u preparedUpdate) error {
+	switch u.metrics.MType {
+	case models.Gauge:
+		return h.repo.UpdateGauge(ctx, u.metrics.ID, *u.metrics.Value)
+	case models.Counter:
+		return h.repo.UpdateCounter(ctx, u.metrics.ID, *u.metrics.Delta)
+	case models.Summary:
+		return h.repo.UpdateSummary(ctx, u.metrics.ID, u.summary)
+	}
+
+	return h.repo.UpdateSet(ctx, u.metrics.ID, u.set)
+}
+
+// applyUpdate записывает одно обновление в JSON-формате /update/.
+func (h *MetricHandler) applyUpdate(ctx context.Context, metrics *models.Metrics) error {
+	u, err := prepareUpdate(metrics)
+	if err != nil {
+		return err
+	}
+
+	return h.writeUpdate(ctx, u)
+}
+
+// fillSummary заполняет ответ для summary: Value - значение квантиля, Delta - число наблюдений.
+func fillSummary(w http.ResponseWriter, metrics *models.Metrics, s *sketch.DDSketch) bool {
+	q := defaultQuantile
+	if metrics.Quantile != nil {
+		q = *metrics.Quantile
+	}
+
+	value, err := s.Quantile(q)
+	if err != nil && !errors.Is(err, sketch.ErrEmpty) {
+		http.Error(w, "Bad Request: Invalid quantile", http.StatusBadRequest)
+		return false
+	}
+
+	count := int64(s.Count)
+	metrics.Quantile = &q
+	metrics.Value = &value
+	metrics.Delta = &count
+	metrics.Sketch = nil
+
+	return true
+}
+
+// fillSet заполняет ответ для set: Delta - оценка числа уникальных элементов.
+func fillSet(metrics *models.Metrics, s *sketch.HyperLogLog) {
+	cardinality := int64(s.Estimate())
+	metrics.Delta = &cardinality
+	metrics.Members = nil
+	metrics.HLL = nil
+}
+
 func (h *MetricHandler) PostValue(w http.ResponseWriter, r *http.Request) {
 	if !strings.Contains(r.Header.Get("Content-Type"), "application/json") {
 		http.Error(w, "invalid content type", http.StatusUnsupportedMediaType)
@@ -146,26 +389,44 @@ func (h *MetricHandler) PostValue(w http.ResponseWriter, r *http.Request) {
 	}
 	defer r.Body.Close()
 
+	ctx := r.Context()
 	switch metrics.MType {
 	case models.Gauge:
-		value, ok := h.repo.GetGauge(metrics.ID)
-		if !ok {
-			http.Error(w, "Metric not found", http.StatusNotFound)
+		value, err := h.repo.GetGauge(ctx, metrics.ID)
+		if err != nil {
+			writeRepoError(w, err)
 			return
 		}
 
 		metrics.Value = &value
 	case models.Counter:
-		delta, ok := h.repo.GetCounter(metrics.ID)
-		if !ok {
-			http.Error(w, "Metric not found", http.StatusNotFound)
+		delta, err := h.repo.GetCounter(ctx, metrics.ID)
+		if err != nil {
+			writeRepoError(w, err)
 			return
 		}
 		metrics.Delta = &delta
+	case models.Summary:
+		s, err := h.repo.GetSummary(ctx, metrics.ID)
+		if err != nil {
+			writeRepoError(w, err)
+			return
+		}
+		if !fillSummary(w, &metrics, s) {
+			return
+		}
+	case models.Set:
+		s, err := h.repo.GetSet(ctx, metrics.ID)
+		if err != nil {
+			writeRepoError(w, err)
+			return
+		}
+		fillSet(&metrics, s)
 	default:
 		http.Error(w, "Bad Request: Invalid metric type", http.StatusBadRequest)
 		return
 	}
+	metrics.Stale = h.isStale(ctx, metrics.MType, metrics.ID)
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -178,80 +439,75 @@ func (h *MetricHandler) PostValue(w http.ResponseWriter, r *http.Request) {
 func (h *MetricHandler) Get(w http.ResponseWriter, r *http.Request) {
 	metricType := chi.URLParam(r, "metricType")
 	metricName := chi.URLParam(r, "metricName")
+	ctx := r.Context()
 
 	var valueStr string
-	var ok bool
+	var err error
 
 	switch metricType {
 	case models.Gauge:
 		var value float64
 
-		value, ok = h.repo.GetGauge(metricName)
-		if ok {
+		value, err = h.repo.GetGauge(ctx, metricName)
+		if err == nil {
 			valueStr = strconv.FormatFloat(value, 'f', -1, 64)
 		}
 	case models.Counter:
 		var value int64
 
-		value, ok = h.repo.GetCounter(metricName)
-		if ok {
+		value, err = h.repo.GetCounter(ctx, metricName)
+		if err == nil {
 			valueStr = strconv.FormatInt(value, 10)
 		}
+	case models.Summary:
+		q := defaultQuantile
+		if qStr := r.URL.Query().Get("q"); qStr != "" {
+			parsed, parseErr := strconv.ParseFloat(qStr, 64)
+			if parseErr != nil {
+				http.Error(w, "Invalid quantile", http.StatusBadRequest)
+				return
+			}
+			q = parsed
+		}
+
+		var s *sketch.DDSketch
+		s, err = h.repo.GetSummary(ctx, metricName)
+		if err == nil {
+			value, qErr := s.Quantile(q)
+			if qErr != nil && !errors.Is(qErr, sketch.ErrEmpty) {
+				http.Error(w, "Invalid quantile", http.StatusBadRequest)
+				return
+			}
+			valueStr = strconv.FormatFloat(value, 'f', -1, 64)
+		}
+	case models.Set:
+		var s *sketch.HyperLogLog
+		s, err = h.repo.GetSet(ctx, metricName)
+		if err == nil {
+			valueStr = strconv.FormatUint(s.Estimate(), 10)
+		}
 	default:
 		http.Error(w, "Invalid metric type", http.StatusBadRequest)
 		return
 	}
 
-	if !ok {
-		http.Error(w, "Metric not found", http.StatusNotFound)
+	if err != nil {
+		writeRepoError(w, err)
 		return
 	}
 
+	if h.isStale(ctx, metricType, metricName) {
+		w.Header().Set("X-Metric-Stale", "true")
+	}
 	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 	w.WriteHeader(http.StatusOK)
-	_, err := w.Write([]byte(valueStr))
+	_, err = w.Write([]byte(valueStr))
 	if err != nil {
 		http.Error(w, "Server error", http.StatusInternalServerError)
 		return
 	}
 }
 
-func (h *MetricHandler) GetAllMetricsHTML(w http.ResponseWriter, r *http.Request) {
-	gauges := h.repo.GetAllGauges()
-	counters := h.repo.GetAllCounters()
-
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	w.WriteHeader(http.StatusOK)
-
-	gaugeNames := make([]string, 0, len(gauges))
-	for k := range gauges {
-		gaugeNames = append(gaugeNames, k)
-	}
-	sort.Strings(gaugeNames)
-
-	counterNames := make([]string, 0, len(counters))
-	for k := range counters {
-		counterNames = append(counterNames, k)
-	}
-	sort.Strings(counterNames)
-
-	io.WriteString(w, "<html><head><title>Metrics</title></head><body>")
-	io.WriteString(w, "<h1>Metrics</h1>")
-	io.WriteString(w, "<h2>Gauges</h2><ul>")
-	for _, name := range gaugeNames {
-		io.WriteString(w, fmt.Sprintf("<li>%s: %f</li>", name, gauges[name]))
-	}
-	io.WriteString(w, "</ul>")
-
-	io.WriteString(w, "<h2>Counters</h2><ul>")
-	for _, name := range counterNames {
-		io.WriteString(w, fmt.Sprintf("<li>%s: %d</li>", name, counters[name]))
-	}
-	io.WriteString(w, "</ul>")
-
-	io.WriteString(w, "</body></html>")
-}
-
 func (h *MetricHandler) GetPing(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), 1*time.Second)
 	defer cancel()
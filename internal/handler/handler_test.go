@@ -1,8 +1,12 @@
 package handler
 
 import (
+	"context"
+	"errors"
 	models "github.com/Guram-Gurych/metricserver.git/internal/model"
+	"github.com/Guram-Gurych/metricserver.git/internal/repository"
 	"github.com/Guram-Gurych/metricserver.git/internal/repository/mocks"
+	"github.com/Guram-Gurych/metricserver.git/internal/sketch"
 	"github.com/go-chi/chi/v5"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
@@ -11,6 +15,7 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestMetricHandler_Post(t *testing.T) {
@@ -31,7 +36,7 @@ func TestMetricHandler_Post(t *testing.T) {
 			body:        "",
 			contentType: "text/plain",
 			setupMock: func(mockRepo *mocks.MockMetricRepository) {
-				mockRepo.EXPECT().UpdateGauge("TestGauge", 123.45).Return(nil)
+				mockRepo.EXPECT().UpdateGauge(gomock.Any(), "TestGauge", 123.45).Return(nil)
 			},
 			expectedStatus: http.StatusOK,
 			expectedBody:   "",
@@ -43,11 +48,50 @@ func TestMetricHandler_Post(t *testing.T) {
 			body:        "",
 			contentType: "text/plain",
 			setupMock: func(mockRepo *mocks.MockMetricRepository) {
-				mockRepo.EXPECT().UpdateCounter("TestCounter", int64(123)).Return(nil)
+				mockRepo.EXPECT().UpdateCounter(gomock.Any(), "TestCounter", int64(123)).Return(nil)
 			},
 			expectedStatus: http.StatusOK,
 			expectedBody:   "",
 		},
+		{
+			name:        "Success - Summary Update",
+			method:      http.MethodPost,
+			url:         "/update/summary/TestSummary/12.5",
+			body:        "",
+			contentType: "text/plain",
+			setupMock: func(mockRepo *mocks.MockMetricRepository) {
+				mockRepo.EXPECT().UpdateSummary(gomock.Any(), "TestSummary", gomock.Any()).Return(nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   "",
+		},
+		{
+			name:        "Error - Storage Failure",
+			method:      http.MethodPost,
+			url:         "/update/gauge/TestGauge/1",
+			contentType: "text/plain",
+			setupMock: func(mockRepo *mocks.MockMetricRepository) {
+				mockRepo.EXPECT().UpdateGauge(gomock.Any(), "TestGauge", float64(1)).Return(errors.New("connection refused"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+		{
+			name:        "Error - Storage Timeout",
+			method:      http.MethodPost,
+			url:         "/update/counter/TestCounter/1",
+			contentType: "text/plain",
+			setupMock: func(mockRepo *mocks.MockMetricRepository) {
+				mockRepo.EXPECT().UpdateCounter(gomock.Any(), "TestCounter", int64(1)).Return(context.DeadlineExceeded)
+			},
+			expectedStatus: http.StatusGatewayTimeout,
+		},
+		{
+			name:           "Error - Invalid Summary Value",
+			method:         http.MethodPost,
+			url:            "/update/summary/TestSummary/abc",
+			setupMock:      func(mockRepo *mocks.MockMetricRepository) {},
+			expectedStatus: http.StatusBadRequest,
+		},
 		{
 			name:           "Error - Method GET Not Allowed",
 			method:         http.MethodGet,
@@ -70,8 +114,8 @@ func TestMetricHandler_Post(t *testing.T) {
 			contentType: "application/json",
 			setupMock: func(mockRepo *mocks.MockMetricRepository) {
 				gomock.InOrder(
-					mockRepo.EXPECT().UpdateGauge("TestGaugeJSON", 123.45).Return(nil),
-					mockRepo.EXPECT().GetGauge("TestGaugeJSON").Return(123.45, true),
+					mockRepo.EXPECT().UpdateGauge(gomock.Any(), "TestGaugeJSON", 123.45).Return(nil),
+					mockRepo.EXPECT().GetGauge(gomock.Any(), "TestGaugeJSON").Return(123.45, nil),
 				)
 			},
 			expectedStatus: http.StatusOK,
@@ -85,13 +129,66 @@ func TestMetricHandler_Post(t *testing.T) {
 			contentType: "application/json",
 			setupMock: func(mockRepo *mocks.MockMetricRepository) {
 				gomock.InOrder(
-					mockRepo.EXPECT().UpdateCounter("TestCounterJSON", int64(123)).Return(nil),
-					mockRepo.EXPECT().GetCounter("TestCounterJSON").Return(int64(123), true),
+					mockRepo.EXPECT().UpdateCounter(gomock.Any(), "TestCounterJSON", int64(123)).Return(nil),
+					mockRepo.EXPECT().GetCounter(gomock.Any(), "TestCounterJSON").Return(int64(123), nil),
 				)
 			},
 			expectedStatus: http.StatusOK,
 			expectedBody:   `{"id":"TestCounterJSON","type":"counter","delta":123}`,
 		},
+		{
+			name:        "Success - Summary Update",
+			method:      http.MethodPost,
+			url:         "/update/",
+			body:        `{"id":"TestSummaryJSON","type":"summary","value":10}`,
+			contentType: "application/json",
+			setupMock: func(mockRepo *mocks.MockMetricRepository) {
+				s := sketch.NewDDSketch(sketch.DefaultRelativeAccuracy)
+				s.Add(10)
+				gomock.InOrder(
+					mockRepo.EXPECT().UpdateSummary(gomock.Any(), "TestSummaryJSON", gomock.Any()).Return(nil),
+					mockRepo.EXPECT().GetSummary(gomock.Any(), "TestSummaryJSON").Return(s, nil),
+				)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"id":"TestSummaryJSON","type":"summary","value":10,"delta":1,"quantile":0.5}`,
+		},
+		{
+			name:        "Success - Set Update",
+			method:      http.MethodPost,
+			url:         "/update/",
+			body:        `{"id":"TestSetJSON","type":"set","members":["a","b","a"]}`,
+			contentType: "application/json",
+			setupMock: func(mockRepo *mocks.MockMetricRepository) {
+				s := sketch.NewHyperLogLog(sketch.DefaultPrecision)
+				s.Add("a")
+				s.Add("b")
+				gomock.InOrder(
+					mockRepo.EXPECT().UpdateSet(gomock.Any(), "TestSetJSON", gomock.Any()).Return(nil),
+					mockRepo.EXPECT().GetSet(gomock.Any(), "TestSetJSON").Return(s, nil),
+				)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"id":"TestSetJSON","type":"set","delta":2}`,
+		},
+		{
+			name:           "Error - Incompatible Set Sketch",
+			method:         http.MethodPost,
+			url:            "/update/",
+			body:           `{"id":"TestSet","type":"set","hll":{"precision":14,"registers":"AAAA"}}`,
+			contentType:    "application/json",
+			setupMock:      func(mockRepo *mocks.MockMetricRepository) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Error - Missing Summary Value",
+			method:         http.MethodPost,
+			url:            "/update/",
+			body:           `{"id":"TestSummary","type":"summary"}`,
+			contentType:    "application/json",
+			setupMock:      func(mockRepo *mocks.MockMetricRepository) {},
+			expectedStatus: http.StatusBadRequest,
+		},
 		{
 			name:           "Error - Bad Request",
 			method:         http.MethodPost,
@@ -101,6 +198,15 @@ func TestMetricHandler_Post(t *testing.T) {
 			setupMock:      func(mockRepo *mocks.MockMetricRepository) {},
 			expectedStatus: http.StatusBadRequest,
 		},
+		{
+			name:           "Error - Empty Metric Name",
+			method:         http.MethodPost,
+			url:            "/update/",
+			body:           `{"id":"","type":"gauge","value":1}`,
+			contentType:    "application/json",
+			setupMock:      func(mockRepo *mocks.MockMetricRepository) {},
+			expectedStatus: http.StatusBadRequest,
+		},
 		{
 			name:           "Error - Invalid Metric Type",
 			method:         http.MethodPost,
@@ -128,6 +234,33 @@ func TestMetricHandler_Post(t *testing.T) {
 			setupMock:      func(mockRepo *mocks.MockMetricRepository) {},
 			expectedStatus: http.StatusBadRequest,
 		},
+		{
+			name:           "Error - Summary Sketch Accuracy Out Of Range",
+			method:         http.MethodPost,
+			url:            "/update/",
+			body:           `{"id":"TestSummary","type":"summary","sketch":{"relative_accuracy":1.5,"zero":1,"count":1}}`,
+			contentType:    "application/json",
+			setupMock:      func(mockRepo *mocks.MockMetricRepository) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Error - Summary Sketch Count Mismatch",
+			method:         http.MethodPost,
+			url:            "/update/",
+			body:           `{"id":"TestSummary","type":"summary","sketch":{"relative_accuracy":0.01,"positive":{"1":2},"count":7}}`,
+			contentType:    "application/json",
+			setupMock:      func(mockRepo *mocks.MockMetricRepository) {},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Error - Set Sketch Invalid Precision",
+			method:         http.MethodPost,
+			url:            "/update/",
+			body:           `{"id":"TestSet","type":"set","hll":{"precision":2,"registers":"AAAA"}}`,
+			contentType:    "application/json",
+			setupMock:      func(mockRepo *mocks.MockMetricRepository) {},
+			expectedStatus: http.StatusBadRequest,
+		},
 	}
 
 	for _, test := range tests {
@@ -174,7 +307,7 @@ func TestMetricHandler_PostValue(t *testing.T) {
 		mockMetricType   string
 		mockGaugeValue   float64
 		mockCounterValue int64
-		mockFound        bool
+		mockErr          error
 		expectedStatus   int
 		expectedBody     string
 	}{
@@ -184,7 +317,6 @@ func TestMetricHandler_PostValue(t *testing.T) {
 			mockMetricName: "TestGauge",
 			mockMetricType: models.Gauge,
 			mockGaugeValue: 123.456,
-			mockFound:      true,
 			expectedStatus: http.StatusOK,
 			expectedBody:   `{"id":"TestGauge","type":"gauge","value":123.456}`,
 		},
@@ -194,7 +326,6 @@ func TestMetricHandler_PostValue(t *testing.T) {
 			mockMetricName:   "TestCounter",
 			mockMetricType:   models.Counter,
 			mockCounterValue: 789,
-			mockFound:        true,
 			expectedStatus:   http.StatusOK,
 			expectedBody:     `{"id":"TestCounter","type":"counter","delta":789}`,
 		},
@@ -203,7 +334,7 @@ func TestMetricHandler_PostValue(t *testing.T) {
 			body:           `{"id":"NotFoundMetric","type":"gauge"}`,
 			mockMetricName: "NotFoundMetric",
 			mockMetricType: models.Gauge,
-			mockFound:      false,
+			mockErr:        repository.ErrNotFound,
 			expectedStatus: http.StatusNotFound,
 			expectedBody:   "",
 		},
@@ -230,10 +361,10 @@ func TestMetricHandler_PostValue(t *testing.T) {
 			handler := NewMetricHandler(mockRepo, nil)
 
 			if test.mockMetricType == models.Gauge {
-				mockRepo.EXPECT().GetGauge(test.mockMetricName).Return(test.mockGaugeValue, test.mockFound)
+				mockRepo.EXPECT().GetGauge(gomock.Any(), test.mockMetricName).Return(test.mockGaugeValue, test.mockErr)
 			}
 			if test.mockMetricType == models.Counter {
-				mockRepo.EXPECT().GetCounter(test.mockMetricName).Return(test.mockCounterValue, test.mockFound)
+				mockRepo.EXPECT().GetCounter(gomock.Any(), test.mockMetricName).Return(test.mockCounterValue, test.mockErr)
 			}
 
 			reqBody := strings.NewReader(test.body)
@@ -263,7 +394,7 @@ func TestMetricHandler_Get(t *testing.T) {
 		mockMetricType   string
 		mockGaugeValue   float64
 		mockCounterValue int64
-		mockFound        bool
+		mockErr          error
 		expectedStatus   int
 		expectedBody     string
 	}{
@@ -273,7 +404,6 @@ func TestMetricHandler_Get(t *testing.T) {
 			mockMetricName: "TestGauge",
 			mockMetricType: "gauge",
 			mockGaugeValue: 123.456,
-			mockFound:      true,
 			expectedStatus: http.StatusOK,
 			expectedBody:   "123.456",
 		},
@@ -283,7 +413,6 @@ func TestMetricHandler_Get(t *testing.T) {
 			mockMetricName:   "TestCounter",
 			mockMetricType:   "counter",
 			mockCounterValue: 789,
-			mockFound:        true,
 			expectedStatus:   http.StatusOK,
 			expectedBody:     "789",
 		},
@@ -292,7 +421,7 @@ func TestMetricHandler_Get(t *testing.T) {
 			url:            "/value/gauge/NotFoundMetric",
 			mockMetricName: "NotFoundMetric",
 			mockMetricType: "gauge",
-			mockFound:      false,
+			mockErr:        repository.ErrNotFound,
 			expectedStatus: http.StatusNotFound,
 			expectedBody:   "Metric not found\n",
 		},
@@ -314,10 +443,10 @@ func TestMetricHandler_Get(t *testing.T) {
 			handler := NewMetricHandler(mockRepo, nil)
 
 			if test.mockMetricType == "gauge" {
-				mockRepo.EXPECT().GetGauge(test.mockMetricName).Return(test.mockGaugeValue, test.mockFound)
+				mockRepo.EXPECT().GetGauge(gomock.Any(), test.mockMetricName).Return(test.mockGaugeValue, test.mockErr)
 			}
 			if test.mockMetricType == "counter" {
-				mockRepo.EXPECT().GetCounter(test.mockMetricName).Return(test.mockCounterValue, test.mockFound)
+				mockRepo.EXPECT().GetCounter(gomock.Any(), test.mockMetricName).Return(test.mockCounterValue, test.mockErr)
 			}
 
 			req := httptest.NewRequest(http.MethodGet, test.url, nil)
@@ -332,3 +461,39 @@ func TestMetricHandler_Get(t *testing.T) {
 		})
 	}
 }
+
+func TestMetricHandler_GetStale(t *testing.T) {
+	tests := []struct {
+		name          string
+		updatedAgo    time.Duration
+		expectedStale string
+	}{
+		{name: "Свежая метрика", updatedAgo: time.Second, expectedStale: ""},
+		{name: "Устаревшая метрика", updatedAgo: time.Hour, expectedStale: "true"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockRepo := mocks.NewMockMetricRepository(ctrl)
+			handler := NewMetricHandler(mockRepo, nil)
+			handler.SetTTL(time.Minute)
+
+			mockRepo.EXPECT().GetGauge(gomock.Any(), "Alloc").Return(1.5, nil)
+			mockRepo.EXPECT().GetUpdatedAt(gomock.Any(), models.Gauge, "Alloc").Return(time.Now().Add(-test.updatedAgo), nil)
+
+			req := httptest.NewRequest(http.MethodGet, "/value/gauge/Alloc", nil)
+			rec := httptest.NewRecorder()
+
+			router := chi.NewRouter()
+			router.Get("/value/{metricType}/{metricName}", handler.Get)
+			router.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, "1.5", rec.Body.String())
+			assert.Equal(t, test.expectedStale, rec.Header().Get("X-Metric-Stale"))
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/Guram-Gurych/metricserver.git/internal/ingest/otlp"
+	"github.com/Guram-Gurych/metricserver.git/internal/logger"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type otlpPartialSuccess struct {
+	RejectedDataPoints string `json:"rejectedDataPoints,omitempty"`
+	ErrorMessage       string `json:"errorMessage,omitempty"`
+}
+
+type otlpResponse struct {
+	PartialSuccess *otlpPartialSuccess `json:"partialSuccess,omitempty"`
+}
+
+// SetOTLPReceiver включает приём OTLP/HTTP на /v1/metrics.
+func (h *MetricHandler) SetOTLPReceiver(receiver *otlp.Receiver) {
+	h.otlp = receiver
+}
+
+// ReceiveOTLP принимает ExportMetricsServiceRequest в protobuf или JSON, в зависимости от
+// Content-Type, и отвечает в той же кодировке. Точки, которые не удалось перевести,
+// возвращаются клиенту как partial success, а не ошибка, как требует спецификация OTLP.
+func (h *MetricHandler) ReceiveOTLP(w http.ResponseWriter, r *http.Request) {
+	if h.otlp == nil {
+		http.Error(w, "OTLP receiver is disabled", http.StatusNotFound)
+		return
+	}
+
+	contentType := r.Header.Get("Content-Type")
+	isJSON := strings.HasPrefix(contentType, "application/json")
+	if !isJSON && !strings.HasPrefix(contentType, "application/x-protobuf") {
+		http.Error(w, "Unsupported Media Type", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, h.maxBodySize))
+	if err != nil {
+		writeBodyError(w, err, "Bad Request")
+		return
+	}
+
+	var req *otlp.ExportRequest
+	if isJSON {
+		req, err = otlp.DecodeJSON(body)
+	} else {
+		req, err = otlp.DecodeProto(body)
+	}
+	if err != nil {
+		http.Error(w, "Bad Request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	res, err := h.otlp.Export(r.Context(), req)
+	if err != nil {
+		writeRepoError(w, err)
+		return
+	}
+
+	message := ""
+	if res.Rejected > 0 {
+		message = fmt.Sprintf("%d data points had no value or an unsupported type", res.Rejected)
+	}
+
+	if !isJSON {
+		w.Header().Set("Content-Type", "application/x-protobuf")
+		w.WriteHeader(http.StatusOK)
+		w.Write(otlp.EncodeProtoResponse(int64(res.Rejected), message))
+		return
+	}
+
+	resp := otlpResponse{}
+	if res.Rejected > 0 {
+		resp.PartialSuccess = &otlpPartialSuccess{RejectedDataPoints: strconv.Itoa(res.Rejected), ErrorMessage: message}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logger.Log.Error("Failed to encode response", zap.Error(err))
+	}
+}
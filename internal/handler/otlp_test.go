@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"github.com/Guram-Gurych/metricserver.git/internal/ingest/otlp"
+	"github.com/Guram-Gurych/metricserver.git/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetricHandler_ReceiveOTLP(t *testing.T) {
+	tests := []struct {
+		name           string
+		contentType    string
+		body           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "JSON",
+			contentType:    "application/json",
+			body:           `{"resourceMetrics":[{"scopeMetrics":[{"metrics":[{"name":"temperature","gauge":{"dataPoints":[{"asDouble":21.5}]}}]}]}]}`,
+			expectedStatus: http.StatusOK,
+			expectedBody:   "{}\n",
+		},
+		{
+			name:           "Частичный приём",
+			contentType:    "application/json",
+			body:           `{"resourceMetrics":[{"scopeMetrics":[{"metrics":[{"name":"temperature","gauge":{"dataPoints":[{"flags":1}]}}]}]}]}`,
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"partialSuccess":{"rejectedDataPoints":"1","errorMessage":"1 data points had no value or an unsupported type"}}` + "\n",
+		},
+		{name: "Пустой protobuf", contentType: "application/x-protobuf", body: "", expectedStatus: http.StatusOK},
+		{name: "Битый protobuf", contentType: "application/x-protobuf", body: "\x0a\x05\x01", expectedStatus: http.StatusBadRequest},
+		{name: "Неизвестный Content-Type", contentType: "text/plain", body: "x", expectedStatus: http.StatusUnsupportedMediaType},
+		{name: "Тело больше предела", contentType: "application/x-protobuf", body: strings.Repeat("\x00", 2048), expectedStatus: http.StatusRequestEntityTooLarge},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			repo := repository.NewMemStorage()
+			h := NewMetricHandler(repo, nil)
+			h.SetOTLPReceiver(otlp.NewReceiver(repo))
+			h.SetMaxBodySize(1024)
+
+			req := httptest.NewRequest(http.MethodPost, "/v1/metrics", strings.NewReader(test.body))
+			req.Header.Set("Content-Type", test.contentType)
+			rr := httptest.NewRecorder()
+			h.ReceiveOTLP(rr, req)
+
+			assert.Equal(t, test.expectedStatus, rr.Code)
+			if test.expectedBody != "" {
+				assert.Equal(t, test.expectedBody, rr.Body.String())
+			}
+		})
+	}
+}
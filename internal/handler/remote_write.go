@@ -0,0 +1,46 @@
+package handler
+
+import (
+	"errors"
+	"github.com/Guram-Gurych/metricserver.git/internal/ingest/remotewrite"
+	"io"
+	"net/http"
+)
+
+// SetRemoteWriteReceiver включает приём Prometheus remote write на /api/v1/write.
+func (h *MetricHandler) SetRemoteWriteReceiver(receiver *remotewrite.Receiver) {
+	h.remoteWrite = receiver
+}
+
+// ReceiveRemoteWrite принимает WriteRequest remote write 1.0 (protobuf, сжатый snappy).
+// На 4xx Prometheus не повторяет отправку, поэтому 400 только для неразбираемого тела,
+// а ошибки хранилища отдаются как 5xx, чтобы данные пришли повторно.
+func (h *MetricHandler) ReceiveRemoteWrite(w http.ResponseWriter, r *http.Request) {
+	if h.remoteWrite == nil {
+		http.Error(w, "Remote write is disabled", http.StatusNotFound)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, h.maxBodySize))
+	if err != nil {
+		writeBodyError(w, err, "Bad Request")
+		return
+	}
+
+	req, err := remotewrite.Decode(body, int(h.maxBodySize))
+	if errors.Is(err, remotewrite.ErrTooLarge) {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Bad Request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.remoteWrite.Write(r.Context(), req); err != nil {
+		writeRepoError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"bytes"
+	"github.com/Guram-Gurych/metricserver.git/internal/ingest/remotewrite"
+	"github.com/Guram-Gurych/metricserver.git/internal/repository"
+	"github.com/golang/snappy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMetricHandler_ReceiveRemoteWrite(t *testing.T) {
+	var label, sample, series, payload []byte
+	label = protowire.AppendTag(label, 1, protowire.BytesType)
+	label = protowire.AppendString(label, "__name__")
+	label = protowire.AppendTag(label, 2, protowire.BytesType)
+	label = protowire.AppendString(label, "up")
+	sample = protowire.AppendTag(sample, 1, protowire.Fixed64Type)
+	sample = protowire.AppendFixed64(sample, math.Float64bits(1))
+	series = protowire.AppendTag(series, 1, protowire.BytesType)
+	series = protowire.AppendBytes(series, label)
+	series = protowire.AppendTag(series, 2, protowire.BytesType)
+	series = protowire.AppendBytes(series, sample)
+	payload = protowire.AppendTag(payload, 1, protowire.BytesType)
+	payload = protowire.AppendBytes(payload, series)
+
+	tests := []struct {
+		name           string
+		body           []byte
+		expectedStatus int
+	}{
+		{name: "WriteRequest", body: snappy.Encode(nil, payload), expectedStatus: http.StatusNoContent},
+		{name: "Без snappy", body: payload, expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			repo := repository.NewMemStorage()
+			h := NewMetricHandler(repo, nil)
+			h.SetRemoteWriteReceiver(remotewrite.NewReceiver(repo))
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/write", bytes.NewReader(test.body))
+			req.Header.Set("Content-Encoding", "snappy")
+			req.Header.Set("Content-Type", "application/x-protobuf")
+			rr := httptest.NewRecorder()
+			h.ReceiveRemoteWrite(rr, req)
+
+			assert.Equal(t, test.expectedStatus, rr.Code)
+			if test.expectedStatus == http.StatusNoContent {
+				gauge, err := repo.GetGauge(req.Context(), "up")
+				require.NoError(t, err)
+				assert.Equal(t, float64(1), gauge)
+			}
+		})
+	}
+}
+
+func TestMetricHandler_ReceiveRemoteWrite_TooLarge(t *testing.T) {
+	tests := []struct {
+		name string
+		body []byte
+	}{
+		{name: "Тело больше предела", body: bytes.Repeat([]byte{0}, 2048)},
+		{name: "Распакованное больше предела", body: snappy.Encode(nil, bytes.Repeat([]byte{0}, 4096))},
+		{name: "Заголовок snappy обещает 4 ГиБ", body: []byte{0xff, 0xff, 0xff, 0xff, 0x0f}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			repo := repository.NewMemStorage()
+			h := NewMetricHandler(repo, nil)
+			h.SetRemoteWriteReceiver(remotewrite.NewReceiver(repo))
+			h.SetMaxBodySize(1024)
+
+			rr := httptest.NewRecorder()
+			h.ReceiveRemoteWrite(rr, httptest.NewRequest(http.MethodPost, "/api/v1/write", bytes.NewReader(test.body)))
+
+			assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/Guram-Gurych/metricserver.git/internal/logger"
+	"github.com/Guram-Gurych/metricserver.git/internal/stream"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+	"net/http"
+	"time"
+)
+
+const (
+	streamKeepAlive    = 15 * time.Second
+	streamWriteTimeout = 10 * time.Second
+)
+
+// streamLagged отправляется вместо пропущенных событий, когда подписчик не успевает их читать.
+type streamLagged struct {
+	Op      string `json:"op"`
+	Dropped uint64 `json:"dropped"`
+}
+
+// upgrader с проверкой Origin по умолчанию: браузер может открыть WebSocket только со
+// страницы того же хоста, поэтому чужой сайт не прочитает поток от имени пользователя.
+var upgrader = websocket.Upgrader{}
+
+// SetHub включает /api/v1/stream; без него поток недоступен.
+func (h *MetricHandler) SetHub(hub *stream.Hub) {
+	h.hub = hub
+}
+
+// Stream отдаёт изменения метрик через SSE или, если клиент просит Upgrade, через WebSocket.
+// Фильтры type и match (шаблон path.Match) применяются на стороне сервера.
+// Медленному подписчику события не копятся без предела: лишние отбрасываются,
+// а клиент получает событие lagged с их числом и должен перечитать состояние.
+func (h *MetricHandler) Stream(w http.ResponseWriter, r *http.Request) {
+	if h.hub == nil {
+		http.Error(w, "Streaming is disabled", http.StatusNotFound)
+		return
+	}
+
+	filter := stream.Filter{Type: r.URL.Query().Get("type"), Match: r.URL.Query().Get("match")}
+	if filter.Type != "" && !isMetricType(filter.Type) {
+		http.Error(w, "Bad Request: Invalid metric type", http.StatusBadRequest)
+		return
+	}
+	if !filter.Valid() {
+		http.Error(w, "Bad Request: Invalid pattern", http.StatusBadRequest)
+		return
+	}
+
+	if websocket.IsWebSocketUpgrade(r) {
+		h.streamWebSocket(w, r, filter)
+		return
+	}
+
+	h.streamSSE(w, r, filter)
+}
+
+func (h *MetricHandler) streamSSE(w http.ResponseWriter, r *http.Request, filter stream.Filter) {
+	rc := http.NewResponseController(w)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		logger.Log.Error("Streaming is not supported by the response writer", zap.Error(err))
+		return
+	}
+
+	sub := h.hub.Subscribe(filter)
+	defer sub.Close()
+
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case event := <-sub.Events():
+			if dropped := sub.Dropped(); dropped > 0 {
+				if err := writeSSE(w, "lagged", streamLagged{Op: "lagged", Dropped: dropped}); err != nil {
+					return
+				}
+			}
+			if err := writeSSE(w, event.Op, event); err != nil {
+				return
+			}
+		}
+
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func writeSSE(w http.ResponseWriter, name string, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, data)
+	return err
+}
+
+func (h *MetricHandler) streamWebSocket(w http.ResponseWriter, r *http.Request, filter stream.Filter) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	sub := h.hub.Subscribe(filter)
+	defer sub.Close()
+
+	// Входящие сообщения не нужны, но их надо читать, чтобы заметить закрытие соединения.
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+
+		select {
+		case <-closed:
+			return
+		case <-keepAlive.C:
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		case event := <-sub.Events():
+			if dropped := sub.Dropped(); dropped > 0 {
+				if err := conn.WriteJSON(streamLagged{Op: "lagged", Dropped: dropped}); err != nil {
+					return
+				}
+			}
+			if err := conn.WriteJSON(event); err != nil {
+				return
+			}
+		}
+	}
+}
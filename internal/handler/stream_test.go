@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"bufio"
+	"github.com/Guram-Gurych/metricserver.git/internal/model"
+	"github.com/Guram-Gurych/metricserver.git/internal/stream"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func publishWhenSubscribed(t *testing.T, hub *stream.Hub, events ...stream.Event) {
+	require.Eventually(t, func() bool { return hub.Subscribers() == 1 }, time.Second, 5*time.Millisecond)
+	for _, e := range events {
+		hub.Publish(e)
+	}
+}
+
+func TestMetricHandler_StreamSSE(t *testing.T) {
+	hub := stream.NewHub(8)
+	handler := NewMetricHandler(nil, nil)
+	handler.SetHub(hub)
+
+	server := httptest.NewServer(http.HandlerFunc(handler.Stream))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "?type=gauge")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	value := 1.5
+	publishWhenSubscribed(t, hub,
+		stream.Event{Op: stream.OpUpdate, Metric: &models.Metrics{ID: "PollCount", MType: models.Counter}},
+		stream.Event{Op: stream.OpUpdate, Metric: &models.Metrics{ID: "Alloc", MType: models.Gauge, Value: &value}},
+	)
+
+	reader := bufio.NewReader(resp.Body)
+	eventLine, err := reader.ReadString('\n')
+	require.NoError(t, err)
+	dataLine, err := reader.ReadString('\n')
+	require.NoError(t, err)
+
+	assert.Equal(t, "event: update\n", eventLine)
+	assert.JSONEq(t, `{"op":"update","metric":{"id":"Alloc","type":"gauge","value":1.5}}`, strings.TrimPrefix(strings.TrimSpace(dataLine), "data: "))
+}
+
+func TestMetricHandler_StreamWebSocket(t *testing.T) {
+	hub := stream.NewHub(8)
+	handler := NewMetricHandler(nil, nil)
+	handler.SetHub(hub)
+
+	server := httptest.NewServer(http.HandlerFunc(handler.Stream))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"?match=Heap*", nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	publishWhenSubscribed(t, hub,
+		stream.Event{Op: stream.OpDelete, Metric: &models.Metrics{ID: "Alloc", MType: models.Gauge}},
+		stream.Event{Op: stream.OpDelete, Metric: &models.Metrics{ID: "HeapAlloc", MType: models.Gauge}},
+	)
+
+	var event stream.Event
+	require.NoError(t, conn.ReadJSON(&event))
+	assert.Equal(t, stream.OpDelete, event.Op)
+	assert.Equal(t, "HeapAlloc", event.Metric.ID)
+}
+
+func TestMetricHandler_StreamWebSocketOrigin(t *testing.T) {
+	handler := NewMetricHandler(nil, nil)
+	handler.SetHub(stream.NewHub(1))
+
+	server := httptest.NewServer(http.HandlerFunc(handler.Stream))
+	defer server.Close()
+
+	_, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), http.Header{"Origin": {"https://evil.example"}})
+	require.Error(t, err)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode, "Страница с чужого хоста не должна подключаться к потоку")
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), http.Header{"Origin": {server.URL}})
+	require.NoError(t, err)
+	conn.Close()
+}
+
+func TestMetricHandler_StreamErrors(t *testing.T) {
+	rec := httptest.NewRecorder()
+	NewMetricHandler(nil, nil).Stream(rec, httptest.NewRequest(http.MethodGet, "/api/v1/stream", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code, "Без хаба поток недоступен")
+
+	handler := NewMetricHandler(nil, nil)
+	handler.SetHub(stream.NewHub(1))
+
+	rec = httptest.NewRecorder()
+	handler.Stream(rec, httptest.NewRequest(http.MethodGet, "/api/v1/stream?match=[", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = httptest.NewRecorder()
+	handler.Stream(rec, httptest.NewRequest(http.MethodGet, "/api/v1/stream?type=histogram", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
@@ -0,0 +1,29 @@
+package handler
+
+import (
+	"encoding/json"
+	"github.com/Guram-Gurych/metricserver.git/internal/logger"
+	"github.com/Guram-Gurych/metricserver.git/internal/scrape"
+	"go.uber.org/zap"
+	"net/http"
+)
+
+// SetScraper включает /api/v1/targets с состоянием опрашиваемых агентов.
+func (h *MetricHandler) SetScraper(scraper *scrape.Scraper) {
+	h.scraper = scraper
+}
+
+// Targets отдаёт состояние целей pull-режима: доступность, время и ошибку последнего опроса.
+func (h *MetricHandler) Targets(w http.ResponseWriter, r *http.Request) {
+	if h.scraper == nil {
+		http.Error(w, "Scraping is disabled", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(h.scraper.Health()); err != nil {
+		logger.Log.Error("Failed to encode response", zap.Error(err))
+	}
+}
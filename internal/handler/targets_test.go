@@ -0,0 +1,30 @@
+package handler
+
+import (
+	"github.com/Guram-Gurych/metricserver.git/internal/repository"
+	"github.com/Guram-Gurych/metricserver.git/internal/scrape"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMetricHandler_Targets(t *testing.T) {
+	h := NewMetricHandler(repository.NewMemStorage(), nil)
+
+	rr := httptest.NewRecorder()
+	h.Targets(rr, httptest.NewRequest(http.MethodGet, "/api/v1/targets", nil))
+	assert.Equal(t, http.StatusNotFound, rr.Code, "Без scraper ручка должна быть выключена")
+
+	scraper, err := scrape.NewScraper(repository.NewMemStorage(), []string{"agent1:9101"}, time.Second, zap.NewNop())
+	require.NoError(t, err)
+	h.SetScraper(scraper)
+
+	rr = httptest.NewRecorder()
+	h.Targets(rr, httptest.NewRequest(http.MethodGet, "/api/v1/targets", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `[{"url":"http://agent1:9101/metrics","up":false,"last_duration_ns":0,"consecutive_failures":0}]`, rr.Body.String())
+}
@@ -0,0 +1,34 @@
+package handler
+
+import (
+	"errors"
+	"github.com/Guram-Gurych/metricserver.git/internal/ingest/influx"
+	"net/http"
+)
+
+// SetInfluxWriter включает приём InfluxDB line protocol на /api/v2/write.
+func (h *MetricHandler) SetInfluxWriter(writer *influx.Writer) {
+	h.influx = writer
+}
+
+// WriteInflux принимает line protocol, как /api/v2/write InfluxDB 2.x, чтобы Telegraf мог писать напрямую.
+// bucket и org игнорируются; сжатое тело распаковывает GzipMiddleware. Как и в InfluxDB,
+// при ошибочных строках остальные всё равно применяются, а клиент получает 400.
+func (h *MetricHandler) WriteInflux(w http.ResponseWriter, r *http.Request) {
+	if h.influx == nil {
+		http.Error(w, "Influx write is disabled", http.StatusNotFound)
+		return
+	}
+
+	_, err := h.influx.Write(r.Context(), r.Body, r.URL.Query().Get("precision"))
+	switch {
+	case err == nil:
+		w.WriteHeader(http.StatusNoContent)
+	case errors.Is(err, influx.ErrInvalidPrecision):
+		http.Error(w, "Bad Request: Invalid precision", http.StatusBadRequest)
+	case errors.Is(err, influx.ErrInvalidLine):
+		http.Error(w, "Bad Request: "+err.Error(), http.StatusBadRequest)
+	default:
+		writeRepoError(w, err)
+	}
+}
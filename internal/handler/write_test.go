@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"github.com/Guram-Gurych/metricserver.git/internal/ingest/influx"
+	"github.com/Guram-Gurych/metricserver.git/internal/middleware"
+	"github.com/Guram-Gurych/metricserver.git/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetricHandler_WriteInflux(t *testing.T) {
+	tests := []struct {
+		name           string
+		url            string
+		body           string
+		gzip           bool
+		expectedStatus int
+		expectedGauge  float64
+	}{
+		{name: "Запись line protocol", url: "/api/v2/write?bucket=b&org=o&precision=s", body: "cpu,host=web1 usage=12.5 1700000000\n", expectedStatus: http.StatusNoContent, expectedGauge: 12.5},
+		{name: "Сжатое тело", url: "/api/v2/write", body: "cpu,host=web1 usage=7\n", gzip: true, expectedStatus: http.StatusNoContent, expectedGauge: 7},
+		{name: "Ошибочная строка не мешает остальным", url: "/api/v2/write", body: "broken\ncpu,host=web1 usage=3\n", expectedStatus: http.StatusBadRequest, expectedGauge: 3},
+		{name: "Неверная точность", url: "/api/v2/write?precision=h", body: "cpu,host=web1 usage=1\n", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			repo := repository.NewMemStorage()
+			h := NewMetricHandler(repo, nil)
+			h.SetInfluxWriter(influx.NewWriter(repo, nil))
+
+			body := []byte(test.body)
+			if test.gzip {
+				var buf bytes.Buffer
+				zw := gzip.NewWriter(&buf)
+				_, err := zw.Write(body)
+				require.NoError(t, err)
+				require.NoError(t, zw.Close())
+				body = buf.Bytes()
+			}
+
+			req := httptest.NewRequest(http.MethodPost, test.url, bytes.NewReader(body))
+			if test.gzip {
+				req.Header.Set("Content-Encoding", "gzip")
+			}
+			rr := httptest.NewRecorder()
+			middleware.GzipMiddleware(http.HandlerFunc(h.WriteInflux)).ServeHTTP(rr, req)
+
+			assert.Equal(t, test.expectedStatus, rr.Code)
+			if test.expectedGauge != 0 {
+				gauge, err := repo.GetGauge(req.Context(), `cpu_usage{host="web1"}`)
+				require.NoError(t, err)
+				assert.Equal(t, test.expectedGauge, gauge)
+			}
+		})
+	}
+
+	t.Run("Без writer", func(t *testing.T) {
+		h := NewMetricHandler(repository.NewMemStorage(), nil)
+		rr := httptest.NewRecorder()
+		h.WriteInflux(rr, httptest.NewRequest(http.MethodPost, "/api/v2/write", strings.NewReader("cpu value=1")))
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+}
@@ -0,0 +1,148 @@
+package graphite
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// dropName в правиле означает, что подходящие пути не сохраняются.
+const dropName = "-"
+
+var ErrInvalidRule = errors.New("invalid mapping rule")
+
+// Rule сопоставляет путь Graphite с именем метрики. В шаблоне пути сегменты
+// разделены точками, '*' совпадает с одним любым сегментом, а в имени $1, $2, ...
+// подставляют совпавшие сегменты по порядку.
+type Rule struct {
+	Pattern []string
+	Name    string
+}
+
+// Mapper применяет первое подходящее правило; путь без подходящего правила остаётся как есть.
+type Mapper struct {
+	rules []Rule
+}
+
+func NewMapper(rules []Rule) *Mapper {
+	return &Mapper{rules: rules}
+}
+
+// ParseRules читает правила по одному на строку: "шаблон имя". Пустые строки и
+// строки, начинающиеся с '#', пропускаются. Имя "-" отбрасывает метрику.
+func ParseRules(r io.Reader) ([]Rule, error) {
+	var rules []Rule
+
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%w: line %d: expected \"pattern name\"", ErrInvalidRule, line)
+		}
+
+		rule := Rule{Pattern: strings.Split(fields[0], "."), Name: fields[1]}
+		if err := rule.validate(); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		rules = append(rules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+func (r Rule) validate() error {
+	wildcards := 0
+	for _, segment := range r.Pattern {
+		if segment == "" {
+			return fmt.Errorf("%w: empty path segment", ErrInvalidRule)
+		}
+		if segment == "*" {
+			wildcards++
+		}
+	}
+
+	for i := 0; i < len(r.Name); i++ {
+		if r.Name[i] != '$' {
+			continue
+		}
+		j := i + 1
+		for j < len(r.Name) && r.Name[j] >= '0' && r.Name[j] <= '9' {
+			j++
+		}
+		n, err := strconv.Atoi(r.Name[i+1 : j])
+		if err != nil || n < 1 || n > wildcards {
+			return fmt.Errorf("%w: %q refers to a missing wildcard", ErrInvalidRule, r.Name)
+		}
+		i = j - 1
+	}
+
+	return nil
+}
+
+// Map возвращает имя метрики для пути; false - метрику нужно отбросить.
+func (m *Mapper) Map(path string) (string, bool) {
+	segments := strings.Split(path, ".")
+	for _, rule := range m.rules {
+		captures, ok := rule.match(segments)
+		if !ok {
+			continue
+		}
+		if rule.Name == dropName {
+			return "", false
+		}
+		return expand(rule.Name, captures), true
+	}
+
+	return path, true
+}
+
+func (r Rule) match(segments []string) ([]string, bool) {
+	if len(segments) != len(r.Pattern) {
+		return nil, false
+	}
+
+	var captures []string
+	for i, p := range r.Pattern {
+		if p == "*" {
+			captures = append(captures, segments[i])
+			continue
+		}
+		if p != segments[i] {
+			return nil, false
+		}
+	}
+
+	return captures, true
+}
+
+func expand(name string, captures []string) string {
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		if name[i] != '$' {
+			b.WriteByte(name[i])
+			continue
+		}
+		j := i + 1
+		for j < len(name) && name[j] >= '0' && name[j] <= '9' {
+			j++
+		}
+		n, _ := strconv.Atoi(name[i+1 : j])
+		b.WriteString(captures[n-1])
+		i = j - 1
+	}
+
+	return b.String()
+}
@@ -0,0 +1,62 @@
+package graphite
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestParseRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		rules   int
+		wantErr bool
+	}{
+		{name: "Правила с комментариями", input: "# хосты\nservers.*.cpu.* cpu_$2_$1\n\nservers.*.debug.* -\n", rules: 2},
+		{name: "Пустой файл", input: "", rules: 0},
+		{name: "Лишнее поле", input: "servers.*.cpu name extra\n", wantErr: true},
+		{name: "Ссылка на несуществующий сегмент", input: "servers.*.cpu cpu_$2\n", wantErr: true},
+		{name: "Пустой сегмент", input: "servers..cpu cpu\n", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rules, err := ParseRules(strings.NewReader(test.input))
+			if test.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidRule)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Len(t, rules, test.rules)
+		})
+	}
+}
+
+func TestMapper_Map(t *testing.T) {
+	rules, err := ParseRules(strings.NewReader("servers.*.debug.* -\nservers.*.cpu.* cpu_$2_$1\nservers.*.* $2\n"))
+	require.NoError(t, err)
+	mapper := NewMapper(rules)
+
+	tests := []struct {
+		name     string
+		path     string
+		expected string
+		keep     bool
+	}{
+		{name: "Подстановка сегментов", path: "servers.web1.cpu.user", expected: "cpu_user_web1", keep: true},
+		{name: "Первое подходящее правило", path: "servers.web1.debug.cpu", keep: false},
+		{name: "Менее точное правило", path: "servers.web1.load", expected: "load", keep: true},
+		{name: "Без подходящего правила", path: "apps.api.requests", expected: "apps.api.requests", keep: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			name, ok := mapper.Map(test.path)
+			assert.Equal(t, test.keep, ok)
+			assert.Equal(t, test.expected, name)
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package graphite
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Guram-Gurych/metricserver.git/internal/ingest"
+	"github.com/Guram-Gurych/metricserver.git/internal/model"
+	"github.com/Guram-Gurych/metricserver.git/internal/repository"
+	"go.uber.org/zap"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+var ErrInvalidLine = errors.New("invalid graphite line")
+
+// Stats - счётчики приёма с момента запуска.
+type Stats struct {
+	Lines      uint64
+	LineErrors uint64
+	Dropped    uint64
+	OutOfOrder uint64
+}
+
+// Server принимает plaintext-протокол Graphite ("path value timestamp") и пишет gauge.
+// Хранилище держит только последнее значение, поэтому точка старше уже записанной
+// для той же метрики отбрасывается, чтобы запоздавшие данные не затёрли свежие.
+type Server struct {
+	repo     repository.MetricRepository
+	mapper   *Mapper
+	logger   *zap.Logger
+	ordering *ingest.Ordering
+
+	lines      atomic.Uint64
+	lineErrors atomic.Uint64
+	dropped    atomic.Uint64
+	outOfOrder atomic.Uint64
+}
+
+func NewServer(repo repository.MetricRepository, mapper *Mapper, logger *zap.Logger) *Server {
+	if mapper == nil {
+		mapper = NewMapper(nil)
+	}
+
+	return &Server{repo: repo, mapper: mapper, logger: logger, ordering: ingest.NewOrdering()}
+}
+
+// SetOrdering задаёт общий с другими приёмниками порядок точек.
+func (s *Server) SetOrdering(ordering *ingest.Ordering) {
+	s.ordering = ordering
+}
+
+func (s *Server) Stats() Stats {
+	return Stats{
+		Lines:      s.lines.Load(),
+		LineErrors: s.lineErrors.Load(),
+		Dropped:    s.dropped.Load(),
+		OutOfOrder: s.outOfOrder.Load(),
+	}
+}
+
+// ServeTCP принимает соединения, пока l не закроют.
+func (s *Server) ServeTCP(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+
+		go s.serveConn(conn)
+	}
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer conn.Close()
+
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if err := s.HandleLine(context.Background(), line); err != nil {
+			s.logger.Debug("Failed to apply Graphite line", zap.Stringer("from", conn.RemoteAddr()), zap.String("line", line), zap.Error(err))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		s.logger.Warn("Graphite connection closed with error", zap.Stringer("from", conn.RemoteAddr()), zap.Error(err))
+	}
+}
+
+func (s *Server) HandleLine(ctx context.Context, line string) error {
+	s.lines.Add(1)
+
+	path, value, timestamp, err := parseLine(line)
+	if err != nil {
+		s.lineErrors.Add(1)
+		return err
+	}
+
+	name, ok := s.mapper.Map(path)
+	if !ok {
+		s.dropped.Add(1)
+		return nil
+	}
+
+	written, err := s.ordering.Apply(models.Gauge, name, time.Unix(timestamp, 0), func() error {
+		return s.repo.UpdateGauge(ctx, name, value)
+	})
+	if err != nil {
+		s.lineErrors.Add(1)
+		return err
+	}
+	if !written {
+		s.outOfOrder.Add(1)
+	}
+
+	return nil
+}
+
+// parseLine разбирает "path value [timestamp]". Отсутствующий timestamp или -1 означают текущее время.
+func parseLine(line string) (string, float64, int64, error) {
+	fields := strings.Fields(line)
+	if len(fields) != 2 && len(fields) != 3 {
+		return "", 0, 0, fmt.Errorf("%w: expected \"path value timestamp\"", ErrInvalidLine)
+	}
+
+	value, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		return "", 0, 0, fmt.Errorf("%w: bad value %q", ErrInvalidLine, fields[1])
+	}
+
+	timestamp := time.Now().Unix()
+	if len(fields) == 3 && fields[2] != "-1" {
+		ts, err := strconv.ParseFloat(fields[2], 64)
+		if err != nil || ts < 0 {
+			return "", 0, 0, fmt.Errorf("%w: bad timestamp %q", ErrInvalidLine, fields[2])
+		}
+		timestamp = int64(ts)
+	}
+
+	return fields[0], value, timestamp, nil
+}
@@ -0,0 +1,62 @@
+package graphite
+
+import (
+	"context"
+	"github.com/Guram-Gurych/metricserver.git/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestServer_HandleLine(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemStorage()
+	srv := NewServer(repo, NewMapper([]Rule{{Pattern: []string{"servers", "*", "load"}, Name: "load_$1"}, {Pattern: []string{"debug", "*"}, Name: "-"}}), zap.NewNop())
+
+	require.NoError(t, srv.HandleLine(ctx, "servers.web1.load 1.5 1700000000"))
+	require.NoError(t, srv.HandleLine(ctx, "servers.web1.load 0.5 1699999990"))
+	require.NoError(t, srv.HandleLine(ctx, "debug.trace 1 -1"))
+	require.NoError(t, srv.HandleLine(ctx, "apps.requests 42"))
+	assert.ErrorIs(t, srv.HandleLine(ctx, "apps.requests NaN 1700000000"), ErrInvalidLine)
+	assert.ErrorIs(t, srv.HandleLine(ctx, "apps.requests 1 yesterday"), ErrInvalidLine)
+	assert.ErrorIs(t, srv.HandleLine(ctx, "apps.requests"), ErrInvalidLine)
+
+	gauge, err := repo.GetGauge(ctx, "load_web1")
+	require.NoError(t, err)
+	assert.Equal(t, 1.5, gauge, "Запоздавшая точка не должна затирать более свежую")
+
+	gauge, err = repo.GetGauge(ctx, "apps.requests")
+	require.NoError(t, err)
+	assert.Equal(t, float64(42), gauge)
+
+	_, err = repo.GetGauge(ctx, "debug.trace")
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+
+	assert.Equal(t, Stats{Lines: 7, LineErrors: 3, Dropped: 1, OutOfOrder: 1}, srv.Stats())
+}
+
+func TestServer_TCP(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemStorage()
+	srv := NewServer(repo, nil, zap.NewNop())
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	go srv.ServeTCP(l)
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	_, err = conn.Write([]byte("tcp.temperature 21.5 -1\n\ntcp.humidity 40\n"))
+	require.NoError(t, err)
+	conn.Close()
+
+	require.Eventually(t, func() bool {
+		temperature, tErr := repo.GetGauge(ctx, "tcp.temperature")
+		humidity, hErr := repo.GetGauge(ctx, "tcp.humidity")
+		return tErr == nil && hErr == nil && temperature == 21.5 && humidity == 40
+	}, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,192 @@
+package influx
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+type FieldKind int
+
+const (
+	KindFloat FieldKind = iota
+	KindInt
+	KindUint
+	KindBool
+	KindString
+)
+
+var ErrInvalidLine = errors.New("invalid line protocol")
+
+// Field - одно поле точки. Для строковых полей Value не заполняется, для bool это 1 или 0.
+// У целых полей точное значение хранится в Int, а Value - его приближение для gauge:
+// float64 теряет точность на значениях больше 2^53.
+type Field struct {
+	Key   string
+	Kind  FieldKind
+	Value float64
+	Int   int64
+}
+
+// Point - одна строка measurement[,tag=value...] field=value[,...] [timestamp].
+type Point struct {
+	Measurement string
+	Tags        map[string]string
+	Fields      []Field
+	// Timestamp в единицах точности запроса; HasTime false, если его не передали.
+	Timestamp int64
+	HasTime   bool
+}
+
+func ParseLine(line string) (Point, error) {
+	end := scan(line, ' ', false)
+	series, rest := line[:end], strings.TrimLeft(line[end:], " ")
+	if series == "" || rest == "" {
+		return Point{}, fmt.Errorf("%w: expected \"measurement field=value\"", ErrInvalidLine)
+	}
+
+	p := Point{}
+	parts := split(series, ',', false)
+	p.Measurement = unescape(parts[0])
+	if p.Measurement == "" {
+		return Point{}, fmt.Errorf("%w: missing measurement", ErrInvalidLine)
+	}
+	for _, part := range parts[1:] {
+		k, v, err := keyValue(part)
+		if err != nil || v == "" {
+			return Point{}, fmt.Errorf("%w: bad tag %q", ErrInvalidLine, part)
+		}
+		if p.Tags == nil {
+			p.Tags = make(map[string]string)
+		}
+		p.Tags[k] = unescape(v)
+	}
+
+	end = scan(rest, ' ', true)
+	fields, timestamp := rest[:end], strings.TrimSpace(rest[end:])
+	for _, part := range split(fields, ',', true) {
+		k, v, err := keyValue(part)
+		if err != nil {
+			return Point{}, fmt.Errorf("%w: bad field %q", ErrInvalidLine, part)
+		}
+		field, err := parseField(k, v)
+		if err != nil {
+			return Point{}, err
+		}
+		p.Fields = append(p.Fields, field)
+	}
+
+	if timestamp != "" {
+		ts, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			return Point{}, fmt.Errorf("%w: bad timestamp %q", ErrInvalidLine, timestamp)
+		}
+		p.Timestamp, p.HasTime = ts, true
+	}
+
+	return p, nil
+}
+
+func parseField(key, raw string) (Field, error) {
+	f := Field{Key: key}
+
+	switch {
+	case raw == "":
+		return Field{}, fmt.Errorf("%w: empty value of field %q", ErrInvalidLine, key)
+	case raw[0] == '"':
+		if len(raw) < 2 || raw[len(raw)-1] != '"' {
+			return Field{}, fmt.Errorf("%w: unterminated string in field %q", ErrInvalidLine, key)
+		}
+		f.Kind = KindString
+		return f, nil
+	case strings.HasSuffix(raw, "i"):
+		v, err := strconv.ParseInt(raw[:len(raw)-1], 10, 64)
+		if err != nil {
+			return Field{}, fmt.Errorf("%w: bad integer in field %q", ErrInvalidLine, key)
+		}
+		f.Kind, f.Value, f.Int = KindInt, float64(v), v
+		return f, nil
+	case strings.HasSuffix(raw, "u"):
+		// Хранилище держит counter в int64, поэтому больше MaxInt64 значение не уместить.
+		v, err := strconv.ParseUint(raw[:len(raw)-1], 10, 64)
+		if err != nil || v > math.MaxInt64 {
+			return Field{}, fmt.Errorf("%w: bad unsigned integer in field %q", ErrInvalidLine, key)
+		}
+		f.Kind, f.Value, f.Int = KindUint, float64(v), int64(v)
+		return f, nil
+	}
+
+	switch raw {
+	case "t", "T", "true", "True", "TRUE":
+		f.Kind, f.Value = KindBool, 1
+		return f, nil
+	case "f", "F", "false", "False", "FALSE":
+		f.Kind, f.Value = KindBool, 0
+		return f, nil
+	}
+
+	v, err := strconv.ParseFloat(raw, 64)
+	if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+		return Field{}, fmt.Errorf("%w: bad value %q of field %q", ErrInvalidLine, raw, key)
+	}
+	f.Kind, f.Value = KindFloat, v
+
+	return f, nil
+}
+
+func keyValue(s string) (string, string, error) {
+	i := scan(s, '=', false)
+	if i == 0 || i == len(s) {
+		return "", "", ErrInvalidLine
+	}
+
+	return unescape(s[:i]), s[i+1:], nil
+}
+
+// scan возвращает индекс первого неэкранированного символа stop или len(s).
+// Если quotes, символы внутри двойных кавычек пропускаются.
+func scan(s string, stop byte, quotes bool) int {
+	inQuotes := false
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\':
+			i++
+		case quotes && s[i] == '"':
+			inQuotes = !inQuotes
+		case s[i] == stop && !inQuotes:
+			return i
+		}
+	}
+
+	return len(s)
+}
+
+func split(s string, sep byte, quotes bool) []string {
+	var parts []string
+	for {
+		i := scan(s, sep, quotes)
+		parts = append(parts, s[:i])
+		if i == len(s) {
+			return parts
+		}
+		s = s[i+1:]
+	}
+}
+
+func unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && strings.IndexByte(`,= "\`, s[i+1]) >= 0 {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+
+	return b.String()
+}
@@ -0,0 +1,77 @@
+package influx
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestParseLine(t *testing.T) {
+	tests := []struct {
+		name     string
+		line     string
+		expected Point
+		wantErr  bool
+	}{
+		{
+			name: "Теги, поля и время",
+			line: "cpu,host=web1,cpu=cpu0 usage_idle=99.5,count=3i 1700000000000000000",
+			expected: Point{
+				Measurement: "cpu",
+				Tags:        map[string]string{"host": "web1", "cpu": "cpu0"},
+				Fields:      []Field{{Key: "usage_idle", Kind: KindFloat, Value: 99.5}, {Key: "count", Kind: KindInt, Value: 3, Int: 3}},
+				Timestamp:   1700000000000000000,
+				HasTime:     true,
+			},
+		},
+		{
+			name: "Без тегов и времени",
+			line: "mem free=10u,ok=true",
+			expected: Point{
+				Measurement: "mem",
+				Fields:      []Field{{Key: "free", Kind: KindUint, Value: 10, Int: 10}, {Key: "ok", Kind: KindBool, Value: 1}},
+			},
+		},
+		{
+			name: "Экранирование и строковое поле",
+			line: `disk\ io,path=/var\,log msg="a, b=c \"d\"",reads=1 5`,
+			expected: Point{
+				Measurement: "disk io",
+				Tags:        map[string]string{"path": "/var,log"},
+				Fields:      []Field{{Key: "msg", Kind: KindString}, {Key: "reads", Kind: KindFloat, Value: 1}},
+				Timestamp:   5,
+				HasTime:     true,
+			},
+		},
+		{name: "Без полей", line: "cpu,host=web1", wantErr: true},
+		{name: "Пустое значение тега", line: "cpu,host= value=1", wantErr: true},
+		{
+			name: "Большие целые без потери точности",
+			line: "net bytes=9007199254740993i,total=9223372036854775807u",
+			expected: Point{
+				Measurement: "net",
+				Fields: []Field{
+					{Key: "bytes", Kind: KindInt, Value: 9007199254740993, Int: 9007199254740993},
+					{Key: "total", Kind: KindUint, Value: 9223372036854775807, Int: 9223372036854775807},
+				},
+			},
+		},
+		{name: "Неверное целое", line: "cpu value=1.5i", wantErr: true},
+		{name: "Беззнаковое больше MaxInt64", line: "cpu value=9223372036854775808u", wantErr: true},
+		{name: "Незакрытая строка", line: `cpu msg="abc`, wantErr: true},
+		{name: "Неверное время", line: "cpu value=1 now", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			point, err := ParseLine(test.line)
+			if test.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidLine)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, point)
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package influx
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Guram-Gurych/metricserver.git/internal/ingest"
+	"github.com/Guram-Gurych/metricserver.git/internal/model"
+	"github.com/Guram-Gurych/metricserver.git/internal/repository"
+	"io"
+	"math"
+	"path"
+	"strings"
+	"time"
+)
+
+const maxLineSize = 1 << 20
+
+var (
+	ErrInvalidRule      = errors.New("invalid integer field rule")
+	ErrInvalidPrecision = errors.New("invalid precision")
+)
+
+// Rule задаёт тип для целочисленных полей, имя которых (measurement_field) подходит под Pattern (path.Match).
+type Rule struct {
+	Pattern string
+	Type    string
+}
+
+// ParseRules разбирает правила вида "pattern=counter,pattern=gauge".
+func ParseRules(s string) ([]Rule, error) {
+	var rules []Rule
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		pattern, mType, ok := strings.Cut(part, "=")
+		if !ok || pattern == "" || (mType != models.Counter && mType != models.Gauge) {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidRule, part)
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidRule, part)
+		}
+		rules = append(rules, Rule{Pattern: pattern, Type: mType})
+	}
+
+	return rules, nil
+}
+
+// Writer применяет line protocol к хранилищу. Каждое числовое поле становится метрикой
+// measurement_field (поле value - просто measurement), теги становятся метками в имени.
+// Целые поля по правилам пишутся как counter через SetCounter (Telegraf шлёт накопленные
+// значения) или как gauge; дробные и bool - всегда gauge, строковые пропускаются.
+type Writer struct {
+	repo     repository.MetricRepository
+	rules    []Rule
+	ordering *ingest.Ordering
+}
+
+func NewWriter(repo repository.MetricRepository, rules []Rule) *Writer {
+	return &Writer{repo: repo, rules: rules, ordering: ingest.NewOrdering()}
+}
+
+// SetOrdering задаёт общий с другими приёмниками порядок точек.
+func (w *Writer) SetOrdering(ordering *ingest.Ordering) {
+	w.ordering = ordering
+}
+
+// Write читает строки из r. Ошибочные строки пропускаются, остальные применяются;
+// возвращается число записанных полей и первая ошибка разбора. Ошибка хранилища
+// прерывает запись сразу.
+func (w *Writer) Write(ctx context.Context, r io.Reader, precision string) (int, error) {
+	multiplier, err := precisionMultiplier(precision)
+	if err != nil {
+		return 0, err
+	}
+
+	written := 0
+	var parseErr error
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 4096), maxLineSize)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		point, err := ParseLine(text)
+		if err != nil {
+			if parseErr == nil {
+				parseErr = fmt.Errorf("line %d: %w", line, err)
+			}
+			continue
+		}
+
+		timestamp, err := scaleTimestamp(point.Timestamp, multiplier)
+		if err != nil {
+			if parseErr == nil {
+				parseErr = fmt.Errorf("line %d: %w", line, err)
+			}
+			continue
+		}
+
+		n, err := w.apply(ctx, point, timestamp)
+		written += n
+		if err != nil {
+			return written, err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return written, err
+	}
+
+	return written, parseErr
+}
+
+func (w *Writer) apply(ctx context.Context, point Point, timestamp int64) (int, error) {
+	written := 0
+	for _, field := range point.Fields {
+		if field.Kind == KindString {
+			continue
+		}
+
+		base := point.Measurement + "_" + field.Key
+		if field.Key == "value" {
+			base = point.Measurement
+		}
+		name := ingest.Name(base, point.Tags)
+
+		metricType := models.Gauge
+		if (field.Kind == KindInt || field.Kind == KindUint) && w.typeOf(base) == models.Counter {
+			metricType = models.Counter
+		}
+		write := func() error {
+			if metricType == models.Counter {
+				return w.repo.SetCounter(ctx, name, field.Int)
+			}
+			return w.repo.UpdateGauge(ctx, name, field.Value)
+		}
+
+		ok := true
+		var err error
+		if point.HasTime {
+			ok, err = w.ordering.Apply(metricType, name, time.Unix(0, timestamp), write)
+		} else {
+			err = write()
+		}
+		if err != nil {
+			return written, err
+		}
+		if ok {
+			written++
+		}
+	}
+
+	return written, nil
+}
+
+func (w *Writer) typeOf(name string) string {
+	for _, rule := range w.rules {
+		if ok, _ := path.Match(rule.Pattern, name); ok {
+			return rule.Type
+		}
+	}
+
+	return models.Gauge
+}
+
+// precisionMultiplier переводит точность запроса в множитель до наносекунд.
+func precisionMultiplier(precision string) (int64, error) {
+	switch precision {
+	case "", "ns":
+		return 1, nil
+	case "us":
+		return 1e3, nil
+	case "ms":
+		return 1e6, nil
+	case "s":
+		return 1e9, nil
+	}
+
+	return 0, fmt.Errorf("%w: %q", ErrInvalidPrecision, precision)
+}
+
+// scaleTimestamp переводит время точки в наносекунды. Время, которое в наносекундах
+// не помещается в int64, отклоняется вместе со строкой, а не переполняется в случайную дату.
+func scaleTimestamp(timestamp, multiplier int64) (int64, error) {
+	if timestamp > math.MaxInt64/multiplier || timestamp < math.MinInt64/multiplier {
+		return 0, fmt.Errorf("%w: timestamp %d out of range", ErrInvalidLine, timestamp)
+	}
+
+	return timestamp * multiplier, nil
+}
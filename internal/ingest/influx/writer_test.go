@@ -0,0 +1,75 @@
+package influx
+
+import (
+	"context"
+	"github.com/Guram-Gurych/metricserver.git/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestParseRules(t *testing.T) {
+	rules, err := ParseRules("net_bytes_*=counter, *_count=gauge")
+	require.NoError(t, err)
+	assert.Equal(t, []Rule{{Pattern: "net_bytes_*", Type: "counter"}, {Pattern: "*_count", Type: "gauge"}}, rules)
+
+	_, err = ParseRules("net_*=summary")
+	assert.ErrorIs(t, err, ErrInvalidRule)
+
+	_, err = ParseRules("[=counter")
+	assert.ErrorIs(t, err, ErrInvalidRule)
+}
+
+func TestWriter_Write(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemStorage()
+	writer := NewWriter(repo, []Rule{{Pattern: "net_bytes_*", Type: "counter"}})
+
+	body := strings.Join([]string{
+		"# комментарий",
+		"net,host=web1 bytes_recv=100i,errors=2i 1700000000",
+		"net,host=web1 bytes_recv=50i 1699999990",
+		"cpu,host=web1 usage=12.5,state=\"ok\" 1700000000",
+		"temperature value=21 1700000000",
+		"broken",
+		"net,host=web1 bytes_recv=150i 1700000010",
+		"net,host=web1 bytes_sent=9007199254740993u 1700000010",
+	}, "\n")
+
+	written, err := writer.Write(ctx, strings.NewReader(body), "s")
+	assert.ErrorIs(t, err, ErrInvalidLine)
+	assert.Equal(t, 6, written)
+
+	counter, err := repo.GetCounter(ctx, `net_bytes_sent{host="web1"}`)
+	require.NoError(t, err)
+	assert.Equal(t, int64(9007199254740993), counter, "Целый counter не должен терять точность")
+
+	counter, err = repo.GetCounter(ctx, `net_bytes_recv{host="web1"}`)
+	require.NoError(t, err)
+	assert.Equal(t, int64(150), counter, "Накопленное значение должно заменять counter, а запоздавшая точка - отбрасываться")
+
+	gauge, err := repo.GetGauge(ctx, `net_errors{host="web1"}`)
+	require.NoError(t, err)
+	assert.Equal(t, float64(2), gauge, "Целое поле без правила должно быть gauge")
+
+	gauge, err = repo.GetGauge(ctx, `cpu_usage{host="web1"}`)
+	require.NoError(t, err)
+	assert.Equal(t, 12.5, gauge)
+
+	gauge, err = repo.GetGauge(ctx, "temperature")
+	require.NoError(t, err)
+	assert.Equal(t, float64(21), gauge, "Поле value должно называться по measurement")
+
+	_, err = repo.GetGauge(ctx, `cpu_state{host="web1"}`)
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+
+	_, err = writer.Write(ctx, strings.NewReader("cpu value=1"), "h")
+	assert.ErrorIs(t, err, ErrInvalidPrecision)
+
+	written, err = writer.Write(ctx, strings.NewReader("far value=1 9223372037\npast value=1 -9223372037"), "s")
+	assert.ErrorIs(t, err, ErrInvalidLine, "Время, переполняющее наносекунды, должно отклоняться")
+	assert.Zero(t, written)
+	_, err = repo.GetGauge(ctx, "far")
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+}
@@ -0,0 +1,59 @@
+package ingest
+
+import (
+	"sort"
+	"strings"
+)
+
+// Name склеивает базовое имя и метки в одно имя метрики вида base{k1="v1",k2="v2"}.
+// Хранилище не знает о метках, поэтому каждая комбинация меток - отдельная метрика.
+// Метки сортируются по ключу, так что одна и та же серия всегда получает одно имя.
+func Name(base string, labels map[string]string) string {
+	if len(labels) == 0 {
+		return base
+	}
+
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(base)
+	b.WriteByte('{')
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(k)
+		b.WriteString(`="`)
+		b.WriteString(escapeLabelValue(labels[k]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+
+	return b.String()
+}
+
+func escapeLabelValue(v string) string {
+	if !strings.ContainsAny(v, "\\\"\n") {
+		return v
+	}
+
+	var b strings.Builder
+	for _, r := range v {
+		switch r {
+		case '\\':
+			b.WriteString(`\\`)
+		case '"':
+			b.WriteString(`\"`)
+		case '\n':
+			b.WriteString(`\n`)
+		default:
+			b.WriteRune(r)
+		}
+	}
+
+	return b.String()
+}
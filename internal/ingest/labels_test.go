@@ -0,0 +1,25 @@
+package ingest
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestName(t *testing.T) {
+	tests := []struct {
+		name     string
+		base     string
+		labels   map[string]string
+		expected string
+	}{
+		{name: "Без меток", base: "cpu_usage", expected: "cpu_usage"},
+		{name: "Метки сортируются", base: "cpu_usage", labels: map[string]string{"host": "web1", "cpu": "0"}, expected: `cpu_usage{cpu="0",host="web1"}`},
+		{name: "Экранирование значений", base: "log", labels: map[string]string{"msg": "a \"b\"\\\n"}, expected: `log{msg="a \"b\"\\\n"}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, Name(test.base, test.labels))
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package ingest
+
+import (
+	"sync"
+	"time"
+)
+
+// orderingStripes - число блокировок, по которым Apply разносит метрики.
+const orderingStripes = 64
+
+// Ordering отбрасывает запоздавшие точки: хранилище держит только последнее значение,
+// и точка старше уже принятой для той же метрики затёрла бы свежие данные.
+// Время из будущего считается текущим, иначе одна точка с неверными часами или
+// миллисекундами вместо секунд заблокировала бы метрику до того момента.
+// Метрики различаются по типу и имени: gauge и counter с одним именем - разные метрики.
+type Ordering struct {
+	// stripes делают проверку, запись и продвижение одним шагом для метрики:
+	// иначе два соединения могли бы записать точки в обратном порядке.
+	stripes [orderingStripes]sync.Mutex
+
+	mu     sync.Mutex
+	latest map[orderingKey]accepted
+	now    func() time.Time
+}
+
+type orderingKey struct {
+	metricType string
+	name       string
+}
+
+type accepted struct {
+	timestamp int64
+	at        time.Time
+}
+
+func NewOrdering() *Ordering {
+	return &Ordering{latest: make(map[orderingKey]accepted), now: time.Now}
+}
+
+// Apply вызывает write, если точка не старше уже принятой для метрики, и сообщает,
+// была ли точка записана. Время запоминается только после успешной записи, чтобы
+// повтор неудавшейся точки не отбросился как запоздавший.
+func (o *Ordering) Apply(metricType, name string, t time.Time, write func() error) (bool, error) {
+	key := orderingKey{metricType: metricType, name: name}
+	stripe := &o.stripes[stripeIndex(key)]
+	stripe.Lock()
+	defer stripe.Unlock()
+
+	now := o.now()
+	if t.After(now) {
+		t = now
+	}
+	timestamp := t.UnixNano()
+
+	o.mu.Lock()
+	last, ok := o.latest[key]
+	o.mu.Unlock()
+	if ok && timestamp < last.timestamp {
+		return false, nil
+	}
+
+	if err := write(); err != nil {
+		return false, err
+	}
+
+	o.mu.Lock()
+	o.latest[key] = accepted{timestamp: timestamp, at: now}
+	o.mu.Unlock()
+
+	return true, nil
+}
+
+// Forget забывает метрику, например после её удаления.
+func (o *Ordering) Forget(metricType, name string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	delete(o.latest, orderingKey{metricType: metricType, name: name})
+}
+
+// ForgetBefore забывает метрики, точки которых последний раз принимались раньше before.
+func (o *Ordering) ForgetBefore(before time.Time) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for key, last := range o.latest {
+		if last.at.Before(before) {
+			delete(o.latest, key)
+		}
+	}
+}
+
+// Reset забывает все метрики.
+func (o *Ordering) Reset() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.latest = make(map[orderingKey]accepted)
+}
+
+func (o *Ordering) Len() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return len(o.latest)
+}
+
+func stripeIndex(key orderingKey) int {
+	h := uint32(2166136261)
+	for _, s := range []string{key.metricType, key.name} {
+		for i := 0; i < len(s); i++ {
+			h ^= uint32(s[i])
+			h *= 16777619
+		}
+	}
+
+	return int(h % orderingStripes)
+}
@@ -0,0 +1,102 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"github.com/Guram-Gurych/metricserver.git/internal/model"
+	"github.com/Guram-Gurych/metricserver.git/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+	"time"
+)
+
+// advance применяет точку gauge без записи.
+func advance(t *testing.T, o *Ordering, name string, at time.Time) bool {
+	t.Helper()
+	ok, err := o.Apply(models.Gauge, name, at, func() error { return nil })
+	require.NoError(t, err)
+	return ok
+}
+
+func TestOrdering_Apply(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	o := NewOrdering()
+	o.now = func() time.Time { return now }
+
+	assert.True(t, advance(t, o, "cpu", now.Add(-time.Minute)))
+	assert.False(t, advance(t, o, "cpu", now.Add(-2*time.Minute)), "Запоздавшая точка должна отбрасываться")
+	assert.True(t, advance(t, o, "cpu", now.Add(-time.Minute)), "Точка с тем же временем принимается")
+
+	// Миллисекунды, принятые за секунды, дают время далеко в будущем.
+	assert.True(t, advance(t, o, "load", time.Unix(now.UnixMilli(), 0)))
+	now = now.Add(time.Second)
+	assert.True(t, advance(t, o, "load", now), "Время из будущего не должно блокировать метрику")
+
+	failed := errors.New("disk full")
+	ok, err := o.Apply(models.Gauge, "load", now.Add(time.Second), func() error { return failed })
+	assert.ErrorIs(t, err, failed)
+	assert.False(t, ok)
+	now = now.Add(time.Second)
+	assert.True(t, advance(t, o, "load", now.Add(-time.Millisecond)), "Неудавшаяся запись не должна продвигать время")
+
+	now = now.Add(time.Minute)
+	assert.True(t, advance(t, o, "fresh", now))
+	o.ForgetBefore(now)
+	assert.Equal(t, 1, o.Len(), "Должны забываться только давно не обновлявшиеся метрики")
+
+	o.Forget(models.Gauge, "fresh")
+	assert.Equal(t, 0, o.Len())
+}
+
+func TestOrdering_ApplyConcurrent(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemStorage()
+	o := NewOrdering()
+	base := time.Now().Add(-time.Hour)
+
+	var wg sync.WaitGroup
+	for i := 1; i <= 200; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := o.Apply(models.Gauge, "cpu", base.Add(time.Duration(i)*time.Second), func() error {
+				return repo.UpdateGauge(ctx, "cpu", float64(i))
+			})
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	value, err := repo.GetGauge(ctx, "cpu")
+	require.NoError(t, err)
+	assert.Equal(t, float64(200), value, "Последней должна остаться самая свежая точка")
+}
+
+func TestStorage_ForgetsOrdering(t *testing.T) {
+	ctx := context.Background()
+	o := NewOrdering()
+	s := NewStorage(repository.NewMemStorage(), o)
+
+	past := time.Now().Add(-time.Hour)
+	require.NoError(t, s.UpdateGauge(ctx, "cpu", 1))
+	require.True(t, advance(t, o, "cpu", time.Now()))
+	assert.False(t, advance(t, o, "cpu", past))
+
+	require.NoError(t, s.UpdateCounter(ctx, "cpu", 1))
+	require.NoError(t, s.DeleteCounter(ctx, "cpu"))
+	assert.False(t, advance(t, o, "cpu", past), "Удаление counter-а не должно забывать gauge с тем же именем")
+
+	require.NoError(t, s.DeleteGauge(ctx, "cpu"))
+	assert.True(t, advance(t, o, "cpu", past), "После удаления метрики старые точки снова принимаются")
+
+	require.NoError(t, s.UpdateGauge(ctx, "cpu", 1))
+	_, err := s.EvictStale(ctx, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, 0, o.Len(), "Вытесненные метрики должны забываться")
+
+	require.True(t, advance(t, o, "cpu", time.Now()))
+	require.NoError(t, s.Restore(ctx, repository.Snapshot{}, repository.RestoreReplace))
+	assert.Equal(t, 0, o.Len())
+}
@@ -0,0 +1,283 @@
+package otlp
+
+import (
+	"encoding/base64"
+	"fmt"
+	"github.com/Guram-Gurych/metricserver.git/internal/ingest"
+	"google.golang.org/protobuf/encoding/protowire"
+	"math"
+)
+
+// DecodeProto разбирает ExportMetricsServiceRequest в кодировке protobuf.
+func DecodeProto(b []byte) (*ExportRequest, error) {
+	req := &ExportRequest{}
+	err := ingest.WalkProto(b, func(f ingest.ProtoField) error {
+		if f.Num != 1 {
+			return nil
+		}
+		if err := f.Expect(protowire.BytesType); err != nil {
+			return err
+		}
+		rm, err := decodeResourceMetrics(f.Bytes)
+		if err != nil {
+			return err
+		}
+		req.ResourceMetrics = append(req.ResourceMetrics, rm)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidPayload, err)
+	}
+
+	return req, nil
+}
+
+func decodeResourceMetrics(b []byte) (ResourceMetrics, error) {
+	rm := ResourceMetrics{}
+	err := ingest.WalkProto(b, func(f ingest.ProtoField) error {
+		switch f.Num {
+		case 1:
+			if err := f.Expect(protowire.BytesType); err != nil {
+				return err
+			}
+			return ingest.WalkProto(f.Bytes, func(f ingest.ProtoField) error {
+				if f.Num != 1 {
+					return nil
+				}
+				kv, err := decodeKeyValue(f)
+				rm.Resource.Attributes = append(rm.Resource.Attributes, kv)
+				return err
+			})
+		case 2:
+			if err := f.Expect(protowire.BytesType); err != nil {
+				return err
+			}
+			sm, err := decodeScopeMetrics(f.Bytes)
+			rm.ScopeMetrics = append(rm.ScopeMetrics, sm)
+			return err
+		}
+		return nil
+	})
+
+	return rm, err
+}
+
+func decodeScopeMetrics(b []byte) (ScopeMetrics, error) {
+	sm := ScopeMetrics{}
+	err := ingest.WalkProto(b, func(f ingest.ProtoField) error {
+		if f.Num != 2 {
+			return nil
+		}
+		if err := f.Expect(protowire.BytesType); err != nil {
+			return err
+		}
+		m, err := decodeMetric(f.Bytes)
+		sm.Metrics = append(sm.Metrics, m)
+		return err
+	})
+
+	return sm, err
+}
+
+func decodeMetric(b []byte) (Metric, error) {
+	m := Metric{}
+	err := ingest.WalkProto(b, func(f ingest.ProtoField) error {
+		switch f.Num {
+		case 1:
+			if err := f.Expect(protowire.BytesType); err != nil {
+				return err
+			}
+			m.Name = string(f.Bytes)
+		case 5:
+			if err := f.Expect(protowire.BytesType); err != nil {
+				return err
+			}
+			m.Gauge = &Gauge{}
+			return ingest.WalkProto(f.Bytes, func(f ingest.ProtoField) error {
+				if f.Num != 1 {
+					return nil
+				}
+				p, err := decodeNumberDataPoint(f)
+				m.Gauge.DataPoints = append(m.Gauge.DataPoints, p)
+				return err
+			})
+		case 7:
+			if err := f.Expect(protowire.BytesType); err != nil {
+				return err
+			}
+			m.Sum = &Sum{}
+			return ingest.WalkProto(f.Bytes, func(f ingest.ProtoField) error {
+				switch f.Num {
+				case 1:
+					p, err := decodeNumberDataPoint(f)
+					m.Sum.DataPoints = append(m.Sum.DataPoints, p)
+					return err
+				case 2:
+					m.Sum.AggregationTemporality = Temporality(f.Scalar)
+				case 3:
+					m.Sum.IsMonotonic = f.Scalar != 0
+				}
+				return nil
+			})
+		case 9:
+			if err := f.Expect(protowire.BytesType); err != nil {
+				return err
+			}
+			m.Histogram = &Histogram{}
+			return ingest.WalkProto(f.Bytes, func(f ingest.ProtoField) error {
+				switch f.Num {
+				case 1:
+					p, err := decodeHistogramDataPoint(f)
+					m.Histogram.DataPoints = append(m.Histogram.DataPoints, p)
+					return err
+				case 2:
+					m.Histogram.AggregationTemporality = Temporality(f.Scalar)
+				}
+				return nil
+			})
+		case 10, 11:
+			// ExponentialHistogram и Summary: считаем только число точек, чтобы отклонить их.
+			if err := f.Expect(protowire.BytesType); err != nil {
+				return err
+			}
+			return ingest.WalkProto(f.Bytes, func(f ingest.ProtoField) error {
+				if f.Num == 1 {
+					m.Unsupported++
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+
+	return m, err
+}
+
+func decodeNumberDataPoint(f ingest.ProtoField) (NumberDataPoint, error) {
+	p := NumberDataPoint{}
+	if err := f.Expect(protowire.BytesType); err != nil {
+		return p, err
+	}
+
+	err := ingest.WalkProto(f.Bytes, func(f ingest.ProtoField) error {
+		switch f.Num {
+		case 7:
+			kv, err := decodeKeyValue(f)
+			p.Attributes = append(p.Attributes, kv)
+			return err
+		case 2:
+			p.StartTimeUnixNano = Uint64(f.Scalar)
+		case 3:
+			p.TimeUnixNano = Uint64(f.Scalar)
+		case 4:
+			v := math.Float64frombits(f.Scalar)
+			p.AsDouble = &v
+		case 6:
+			v := Int64(f.Scalar)
+			p.AsInt = &v
+		case 8:
+			p.Flags = uint32(f.Scalar)
+		}
+		return nil
+	})
+
+	return p, err
+}
+
+func decodeHistogramDataPoint(f ingest.ProtoField) (HistogramDataPoint, error) {
+	p := HistogramDataPoint{}
+	if err := f.Expect(protowire.BytesType); err != nil {
+		return p, err
+	}
+
+	err := ingest.WalkProto(f.Bytes, func(f ingest.ProtoField) error {
+		switch f.Num {
+		case 9:
+			kv, err := decodeKeyValue(f)
+			p.Attributes = append(p.Attributes, kv)
+			return err
+		case 2:
+			p.StartTimeUnixNano = Uint64(f.Scalar)
+		case 3:
+			p.TimeUnixNano = Uint64(f.Scalar)
+		case 4:
+			p.Count = Uint64(f.Scalar)
+		case 5:
+			v := math.Float64frombits(f.Scalar)
+			p.Sum = &v
+		case 6:
+			return f.Fixed64s(func(v uint64) { p.BucketCounts = append(p.BucketCounts, Uint64(v)) })
+		case 7:
+			return f.Fixed64s(func(v uint64) { p.ExplicitBounds = append(p.ExplicitBounds, math.Float64frombits(v)) })
+		case 10:
+			p.Flags = uint32(f.Scalar)
+		case 11:
+			v := math.Float64frombits(f.Scalar)
+			p.Min = &v
+		case 12:
+			v := math.Float64frombits(f.Scalar)
+			p.Max = &v
+		}
+		return nil
+	})
+
+	return p, err
+}
+
+// decodeKeyValue разбирает атрибут. Массивы и вложенные списки как метки не используются и остаются пустыми.
+func decodeKeyValue(f ingest.ProtoField) (KeyValue, error) {
+	kv := KeyValue{}
+	if err := f.Expect(protowire.BytesType); err != nil {
+		return kv, err
+	}
+
+	err := ingest.WalkProto(f.Bytes, func(f ingest.ProtoField) error {
+		switch f.Num {
+		case 1:
+			kv.Key = string(f.Bytes)
+		case 2:
+			return ingest.WalkProto(f.Bytes, func(f ingest.ProtoField) error {
+				switch f.Num {
+				case 1:
+					v := string(f.Bytes)
+					kv.Value.StringValue = &v
+				case 2:
+					v := f.Scalar != 0
+					kv.Value.BoolValue = &v
+				case 3:
+					v := Int64(f.Scalar)
+					kv.Value.IntValue = &v
+				case 4:
+					v := math.Float64frombits(f.Scalar)
+					kv.Value.DoubleValue = &v
+				case 7:
+					v := base64.StdEncoding.EncodeToString(f.Bytes)
+					kv.Value.BytesValue = &v
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+
+	return kv, err
+}
+
+// EncodeProtoResponse кодирует ExportMetricsServiceResponse; без отклонённых точек это пустое сообщение.
+func EncodeProtoResponse(rejected int64, message string) []byte {
+	if rejected == 0 && message == "" {
+		return nil
+	}
+
+	var partial []byte
+	partial = protowire.AppendTag(partial, 1, protowire.VarintType)
+	partial = protowire.AppendVarint(partial, uint64(rejected))
+	if message != "" {
+		partial = protowire.AppendTag(partial, 2, protowire.BytesType)
+		partial = protowire.AppendString(partial, message)
+	}
+
+	var b []byte
+	b = protowire.AppendTag(b, 1, protowire.BytesType)
+	return protowire.AppendBytes(b, partial)
+}
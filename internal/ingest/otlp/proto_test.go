@@ -0,0 +1,103 @@
+package otlp
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+	"math"
+	"testing"
+)
+
+func message(fields ...[]byte) []byte {
+	var b []byte
+	for _, f := range fields {
+		b = append(b, f...)
+	}
+	return b
+}
+
+func bytesField(num protowire.Number, v []byte) []byte {
+	return protowire.AppendBytes(protowire.AppendTag(nil, num, protowire.BytesType), v)
+}
+
+func varintField(num protowire.Number, v uint64) []byte {
+	return protowire.AppendVarint(protowire.AppendTag(nil, num, protowire.VarintType), v)
+}
+
+func fixed64Field(num protowire.Number, v uint64) []byte {
+	return protowire.AppendFixed64(protowire.AppendTag(nil, num, protowire.Fixed64Type), v)
+}
+
+func stringAttr(key, value string) []byte {
+	return message(bytesField(1, []byte(key)), bytesField(2, bytesField(1, []byte(value))))
+}
+
+func TestDecodeProto(t *testing.T) {
+	sum := message(
+		bytesField(1, message(bytesField(7, stringAttr("method", "GET")), fixed64Field(3, 10), fixed64Field(6, 5))),
+		varintField(2, uint64(TemporalityCumulative)),
+		varintField(3, 1),
+	)
+	var packedCounts, packedBounds []byte
+	for _, c := range []uint64{1, 2, 3} {
+		packedCounts = protowire.AppendFixed64(packedCounts, c)
+	}
+	for _, b := range []float64{10, 100} {
+		packedBounds = protowire.AppendFixed64(packedBounds, math.Float64bits(b))
+	}
+	histogram := message(
+		bytesField(1, message(fixed64Field(4, 6), bytesField(6, packedCounts), bytesField(7, packedBounds), fixed64Field(11, math.Float64bits(1)))),
+		varintField(2, uint64(TemporalityDelta)),
+	)
+	metrics := message(
+		bytesField(2, message(bytesField(1, []byte("requests")), bytesField(7, sum))),
+		bytesField(2, message(bytesField(1, []byte("latency")), bytesField(9, histogram))),
+		bytesField(2, message(bytesField(1, []byte("sizes")), bytesField(10, bytesField(1, nil)))),
+	)
+	payload := bytesField(1, message(
+		bytesField(1, bytesField(1, stringAttr("service.name", "api"))),
+		bytesField(2, metrics),
+	))
+
+	req, err := DecodeProto(payload)
+	require.NoError(t, err)
+	require.Len(t, req.ResourceMetrics, 1)
+
+	rm := req.ResourceMetrics[0]
+	require.Len(t, rm.Resource.Attributes, 1)
+	assert.Equal(t, "service.name", rm.Resource.Attributes[0].Key)
+	assert.Equal(t, "api", rm.Resource.Attributes[0].Value.String())
+
+	require.Len(t, rm.ScopeMetrics, 1)
+	require.Len(t, rm.ScopeMetrics[0].Metrics, 3)
+
+	requests := rm.ScopeMetrics[0].Metrics[0]
+	assert.Equal(t, "requests", requests.Name)
+	require.NotNil(t, requests.Sum)
+	assert.True(t, requests.Sum.IsMonotonic)
+	assert.Equal(t, TemporalityCumulative, requests.Sum.AggregationTemporality)
+	value, ok := requests.Sum.DataPoints[0].Value()
+	assert.True(t, ok)
+	assert.Equal(t, float64(5), value)
+	assert.Equal(t, "GET", requests.Sum.DataPoints[0].Attributes[0].Value.String())
+
+	latency := rm.ScopeMetrics[0].Metrics[1]
+	require.NotNil(t, latency.Histogram)
+	point := latency.Histogram.DataPoints[0]
+	assert.Equal(t, []Uint64{1, 2, 3}, point.BucketCounts)
+	assert.Equal(t, []float64{10, 100}, point.ExplicitBounds)
+	require.NotNil(t, point.Min)
+	assert.Equal(t, float64(1), *point.Min)
+
+	assert.Equal(t, 1, rm.ScopeMetrics[0].Metrics[2].Unsupported, "Точки ExponentialHistogram должны учитываться как неподдерживаемые")
+
+	_, err = DecodeProto([]byte{0x0a, 0x05, 0x01})
+	assert.ErrorIs(t, err, ErrInvalidPayload)
+}
+
+func TestEncodeProtoResponse(t *testing.T) {
+	assert.Empty(t, EncodeProtoResponse(0, ""))
+
+	b := EncodeProtoResponse(3, "rejected")
+	assert.Equal(t, bytesField(1, message(varintField(1, 3), bytesField(2, []byte("rejected")))), b)
+}
@@ -0,0 +1,257 @@
+package otlp
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"github.com/Guram-Gurych/metricserver.git/internal/ingest"
+	"github.com/Guram-Gurych/metricserver.git/internal/repository"
+	"github.com/Guram-Gurych/metricserver.git/internal/sketch"
+	"math"
+	"sync"
+)
+
+// maxHistograms ограничивает число cumulative-гистограмм, для которых помнятся счётчики.
+const maxHistograms = 100000
+
+// Result - итог приёма запроса: Rejected точек не удалось перевести в метрики сервера.
+type Result struct {
+	Accepted int
+	Rejected int
+}
+
+// Receiver переводит метрики OTLP в метрики сервера. Атрибуты ресурса и точки становятся
+// метками в имени (атрибуты точки важнее). Монотонный Sum пишется в counter: delta
+// прибавляется, cumulative заменяет значение. Немонотонный Sum и Gauge пишутся в gauge.
+// Histogram превращается в summary: каждая корзина добавляется в DDSketch своим
+// представителем с весом, равным числу попаданий.
+type Receiver struct {
+	repo repository.MetricRepository
+
+	mu            sync.Mutex
+	histograms    map[string]*list.Element
+	maxHistograms int
+	// recent держит серии от недавно обновлённой к давней, чтобы при переполнении
+	// забывать давно не обновлявшуюся за O(1).
+	recent *list.List
+}
+
+// cumulativeHistogram - последние счётчики cumulative-гистограммы; summary в хранилище
+// только сливаются, поэтому в него добавляется лишь прирост с прошлого запроса.
+type cumulativeHistogram struct {
+	name   string
+	start  uint64
+	bounds []float64
+	counts []uint64
+}
+
+func NewReceiver(repo repository.MetricRepository) *Receiver {
+	return &Receiver{
+		repo:          repo,
+		histograms:    make(map[string]*list.Element),
+		maxHistograms: maxHistograms,
+		recent:        list.New(),
+	}
+}
+
+// Export применяет запрос. Точки без значения и неподдерживаемых типов отклоняются,
+// ошибка хранилища прерывает запрос.
+func (r *Receiver) Export(ctx context.Context, req *ExportRequest) (Result, error) {
+	res := Result{}
+	for _, rm := range req.ResourceMetrics {
+		resource := labels(nil, rm.Resource.Attributes)
+		for _, sm := range rm.ScopeMetrics {
+			for _, m := range sm.Metrics {
+				res.Rejected += m.Unsupported
+				if err := r.exportMetric(ctx, m, resource, &res); err != nil {
+					return res, err
+				}
+			}
+		}
+	}
+
+	return res, nil
+}
+
+func (r *Receiver) exportMetric(ctx context.Context, m Metric, resource map[string]string, res *Result) error {
+	switch {
+	case m.Gauge != nil:
+		for _, p := range m.Gauge.DataPoints {
+			value, ok := p.Value()
+			if !ok || m.Name == "" {
+				res.Rejected++
+				continue
+			}
+			if err := r.repo.UpdateGauge(ctx, ingest.Name(m.Name, labels(resource, p.Attributes)), value); err != nil {
+				return err
+			}
+			res.Accepted++
+		}
+	case m.Sum != nil:
+		for _, p := range m.Sum.DataPoints {
+			value, ok := p.Value()
+			if !ok || m.Name == "" || m.Sum.AggregationTemporality == TemporalityUnspecified {
+				res.Rejected++
+				continue
+			}
+			if err := r.exportSum(ctx, ingest.Name(m.Name, labels(resource, p.Attributes)), m.Sum, value); err != nil {
+				return err
+			}
+			res.Accepted++
+		}
+	case m.Histogram != nil:
+		for _, p := range m.Histogram.DataPoints {
+			if p.Flags&flagNoRecordedValue != 0 || m.Name == "" || m.Histogram.AggregationTemporality == TemporalityUnspecified {
+				res.Rejected++
+				continue
+			}
+			err := r.exportHistogram(ctx, ingest.Name(m.Name, labels(resource, p.Attributes)), m.Histogram.AggregationTemporality, p)
+			if errors.Is(err, ErrInvalidPayload) {
+				res.Rejected++
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			res.Accepted++
+		}
+	}
+
+	return nil
+}
+
+func (r *Receiver) exportSum(ctx context.Context, name string, sum *Sum, value float64) error {
+	switch {
+	case sum.IsMonotonic && sum.AggregationTemporality == TemporalityCumulative:
+		return r.repo.SetCounter(ctx, name, int64(math.Round(value)))
+	case sum.IsMonotonic:
+		return r.repo.UpdateCounter(ctx, name, int64(math.Round(value)))
+	case sum.AggregationTemporality == TemporalityCumulative:
+		return r.repo.UpdateGauge(ctx, name, value)
+	}
+
+	_, err := r.repo.AddGauge(ctx, name, value)
+	return err
+}
+
+func (r *Receiver) exportHistogram(ctx context.Context, name string, temporality Temporality, p HistogramDataPoint) error {
+	if len(p.BucketCounts) != 0 && len(p.BucketCounts) != len(p.ExplicitBounds)+1 {
+		return ErrInvalidPayload
+	}
+
+	counts := make([]uint64, len(p.BucketCounts))
+	for i, c := range p.BucketCounts {
+		counts[i] = uint64(c)
+	}
+	if temporality == TemporalityCumulative {
+		counts = r.histogramDelta(name, p, counts)
+	}
+
+	summary := sketch.NewDDSketch(sketch.DefaultRelativeAccuracy)
+	for i, c := range counts {
+		if err := summary.AddN(bucketValue(p, i), c); err != nil {
+			return ErrInvalidPayload
+		}
+	}
+	if summary.Count == 0 {
+		return nil
+	}
+
+	return r.repo.UpdateSummary(ctx, name, summary)
+}
+
+// histogramDelta возвращает прирост корзин с прошлой точки серии. Если гистограмму
+// перезапустили (сменились начало или границы либо счётчики уменьшились), прирост - вся точка.
+// Новая серия при переполнении вытесняет дольше всех не обновлявшуюся.
+func (r *Receiver) histogramDelta(name string, p HistogramDataPoint, counts []uint64) []uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current := &cumulativeHistogram{name: name, start: uint64(p.StartTimeUnixNano), bounds: p.ExplicitBounds, counts: counts}
+	var prev *cumulativeHistogram
+	if e, ok := r.histograms[name]; ok {
+		prev = e.Value.(*cumulativeHistogram)
+		e.Value = current
+		r.recent.MoveToFront(e)
+	} else {
+		if len(r.histograms) >= r.maxHistograms {
+			r.evictOldestHistogram()
+		}
+		r.histograms[name] = r.recent.PushFront(current)
+	}
+	if prev == nil || prev.start != uint64(p.StartTimeUnixNano) || !equalBounds(prev.bounds, p.ExplicitBounds) {
+		return counts
+	}
+
+	delta := make([]uint64, len(counts))
+	for i, c := range counts {
+		if c < prev.counts[i] {
+			return counts
+		}
+		delta[i] = c - prev.counts[i]
+	}
+
+	return delta
+}
+
+func (r *Receiver) evictOldestHistogram() {
+	oldest := r.recent.Back()
+	if oldest == nil {
+		return
+	}
+	r.recent.Remove(oldest)
+	delete(r.histograms, oldest.Value.(*cumulativeHistogram).name)
+}
+
+// bucketValue выбирает представителя корзины: середину внутренней корзины, а для
+// крайних - min/max точки, если они есть, иначе ближайшую границу.
+func bucketValue(p HistogramDataPoint, i int) float64 {
+	bounds := p.ExplicitBounds
+	switch {
+	case len(bounds) == 0:
+		if p.Sum != nil && p.Count > 0 {
+			return *p.Sum / float64(p.Count)
+		}
+		return 0
+	case i == 0:
+		if p.Min != nil {
+			return *p.Min
+		}
+		return bounds[0]
+	case i == len(bounds):
+		if p.Max != nil {
+			return *p.Max
+		}
+		return bounds[len(bounds)-1]
+	}
+
+	return (bounds[i-1] + bounds[i]) / 2
+}
+
+func equalBounds(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// labels дополняет base атрибутами; атрибуты без значения пропускаются.
+func labels(base map[string]string, attrs []KeyValue) map[string]string {
+	out := make(map[string]string, len(base)+len(attrs))
+	for k, v := range base {
+		out[k] = v
+	}
+	for _, attr := range attrs {
+		if v := attr.Value.String(); attr.Key != "" && v != "" {
+			out[attr.Key] = v
+		}
+	}
+
+	return out
+}
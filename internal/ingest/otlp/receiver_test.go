@@ -0,0 +1,118 @@
+package otlp
+
+import (
+	"context"
+	"github.com/Guram-Gurych/metricserver.git/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+const exportJSON = `{"resourceMetrics":[{
+	"resource":{"attributes":[{"key":"service.name","value":{"stringValue":"api"}}]},
+	"scopeMetrics":[{"metrics":[
+		{"name":"requests","sum":{"aggregationTemporality":2,"isMonotonic":true,"dataPoints":[{"asInt":"10","attributes":[{"key":"code","value":{"intValue":"200"}}]}]}},
+		{"name":"errors","sum":{"aggregationTemporality":"AGGREGATION_TEMPORALITY_DELTA","isMonotonic":true,"dataPoints":[{"asInt":"2"}]}},
+		{"name":"inflight","sum":{"aggregationTemporality":1,"isMonotonic":false,"dataPoints":[{"asDouble":3}]}},
+		{"name":"temperature","gauge":{"dataPoints":[{"asDouble":21.5},{"flags":1}]}},
+		{"name":"latency","histogram":{"aggregationTemporality":2,"dataPoints":[{"startTimeUnixNano":"1","count":"3","bucketCounts":["1","2","0"],"explicitBounds":[10,20]}]}},
+		{"name":"sizes","summary":{"dataPoints":[{}]}}
+	]}]
+}]}`
+
+func TestReceiver_Export(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemStorage()
+	receiver := NewReceiver(repo)
+
+	req, err := DecodeJSON([]byte(exportJSON))
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		res, err := receiver.Export(ctx, req)
+		require.NoError(t, err)
+		assert.Equal(t, Result{Accepted: 5, Rejected: 2}, res)
+	}
+
+	counter, err := repo.GetCounter(ctx, `requests{code="200",service.name="api"}`)
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), counter, "Cumulative sum должен заменять counter")
+
+	counter, err = repo.GetCounter(ctx, `errors{service.name="api"}`)
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), counter, "Delta sum должен прибавляться к counter")
+
+	gauge, err := repo.GetGauge(ctx, `inflight{service.name="api"}`)
+	require.NoError(t, err)
+	assert.Equal(t, float64(6), gauge, "Немонотонный delta sum должен менять gauge")
+
+	gauge, err = repo.GetGauge(ctx, `temperature{service.name="api"}`)
+	require.NoError(t, err)
+	assert.Equal(t, 21.5, gauge)
+
+	summary, err := repo.GetSummary(ctx, `latency{service.name="api"}`)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), summary.Count, "Повтор cumulative-гистограммы не должен удваивать счётчики")
+}
+
+func TestReceiver_HistogramReset(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemStorage()
+	receiver := NewReceiver(repo)
+
+	export := func(start Uint64, counts ...Uint64) {
+		_, err := receiver.Export(ctx, &ExportRequest{ResourceMetrics: []ResourceMetrics{{ScopeMetrics: []ScopeMetrics{{Metrics: []Metric{{
+			Name: "latency",
+			Histogram: &Histogram{AggregationTemporality: TemporalityCumulative, DataPoints: []HistogramDataPoint{
+				{StartTimeUnixNano: start, BucketCounts: counts, ExplicitBounds: []float64{10}},
+			}},
+		}}}}}}})
+		require.NoError(t, err)
+	}
+
+	export(1, 1, 1)
+	export(1, 3, 1)
+	export(2, 1, 0)
+
+	summary, err := repo.GetSummary(ctx, "latency")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(5), summary.Count, "После перезапуска гистограммы её точка должна учитываться целиком")
+}
+
+func TestReceiver_HistogramEviction(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemStorage()
+	receiver := NewReceiver(repo)
+	receiver.maxHistograms = 2
+
+	export := func(name string, counts ...Uint64) {
+		_, err := receiver.Export(ctx, &ExportRequest{ResourceMetrics: []ResourceMetrics{{ScopeMetrics: []ScopeMetrics{{Metrics: []Metric{{
+			Name: name,
+			Histogram: &Histogram{AggregationTemporality: TemporalityCumulative, DataPoints: []HistogramDataPoint{
+				{StartTimeUnixNano: 1, BucketCounts: counts, ExplicitBounds: []float64{10}},
+			}},
+		}}}}}}})
+		require.NoError(t, err)
+	}
+
+	export("a", 1, 0)
+	export("b", 1, 0)
+	export("a", 2, 0)
+	export("c", 1, 0)
+	assert.Len(t, receiver.histograms, 2, "Кеш гистограмм должен быть ограничен")
+	assert.Equal(t, 2, receiver.recent.Len(), "Очередь вытеснения должна совпадать с кешем")
+	assert.NotContains(t, receiver.histograms, "b", "Вытесняться должна давно не обновлявшаяся серия")
+
+	export("a", 3, 0)
+	summary, err := repo.GetSummary(ctx, "a")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), summary.Count, "Оставшаяся в кеше серия должна учитываться приростом")
+}
+
+func TestDecodeJSON_Invalid(t *testing.T) {
+	_, err := DecodeJSON([]byte(`{"resourceMetrics":[{"scopeMetrics":[{"metrics":[{"name":"x","sum":{"dataPoints":[{"asInt":"abc"}]}}]}]}]}`))
+	assert.ErrorIs(t, err, ErrInvalidPayload)
+
+	_, err = DecodeJSON([]byte(`not json`))
+	assert.ErrorIs(t, err, ErrInvalidPayload)
+}
@@ -0,0 +1,239 @@
+package otlp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Temporality - AggregationTemporality из OTLP.
+type Temporality int32
+
+const (
+	TemporalityUnspecified Temporality = 0
+	TemporalityDelta       Temporality = 1
+	TemporalityCumulative  Temporality = 2
+)
+
+// flagNoRecordedValue - DataPointFlags.FLAG_NO_RECORDED_VALUE: у точки нет значения.
+const flagNoRecordedValue = 1
+
+var ErrInvalidPayload = errors.New("invalid OTLP payload")
+
+// Ниже - подмножество ExportMetricsServiceRequest, которое нужно приёмнику. Теги json
+// соответствуют OTLP/JSON; protobuf разбирается в те же структуры в proto.go.
+
+type ExportRequest struct {
+	ResourceMetrics []ResourceMetrics `json:"resourceMetrics"`
+}
+
+type ResourceMetrics struct {
+	Resource     Resource       `json:"resource"`
+	ScopeMetrics []ScopeMetrics `json:"scopeMetrics"`
+}
+
+type Resource struct {
+	Attributes []KeyValue `json:"attributes"`
+}
+
+type ScopeMetrics struct {
+	Metrics []Metric `json:"metrics"`
+}
+
+type Metric struct {
+	Name      string     `json:"name"`
+	Gauge     *Gauge     `json:"gauge,omitempty"`
+	Sum       *Sum       `json:"sum,omitempty"`
+	Histogram *Histogram `json:"histogram,omitempty"`
+	// Unsupported - точки ExponentialHistogram и Summary, которые приёмник отклоняет.
+	Unsupported int `json:"-"`
+}
+
+type Gauge struct {
+	DataPoints []NumberDataPoint `json:"dataPoints"`
+}
+
+type Sum struct {
+	DataPoints             []NumberDataPoint `json:"dataPoints"`
+	AggregationTemporality Temporality       `json:"aggregationTemporality"`
+	IsMonotonic            bool              `json:"isMonotonic"`
+}
+
+type Histogram struct {
+	DataPoints             []HistogramDataPoint `json:"dataPoints"`
+	AggregationTemporality Temporality          `json:"aggregationTemporality"`
+}
+
+type NumberDataPoint struct {
+	Attributes        []KeyValue `json:"attributes"`
+	StartTimeUnixNano Uint64     `json:"startTimeUnixNano"`
+	TimeUnixNano      Uint64     `json:"timeUnixNano"`
+	AsDouble          *float64   `json:"asDouble,omitempty"`
+	AsInt             *Int64     `json:"asInt,omitempty"`
+	Flags             uint32     `json:"flags"`
+}
+
+// Value возвращает значение точки; false - значения нет.
+func (p NumberDataPoint) Value() (float64, bool) {
+	switch {
+	case p.Flags&flagNoRecordedValue != 0:
+		return 0, false
+	case p.AsDouble != nil:
+		return *p.AsDouble, true
+	case p.AsInt != nil:
+		return float64(*p.AsInt), true
+	}
+
+	return 0, false
+}
+
+type HistogramDataPoint struct {
+	Attributes        []KeyValue `json:"attributes"`
+	StartTimeUnixNano Uint64     `json:"startTimeUnixNano"`
+	TimeUnixNano      Uint64     `json:"timeUnixNano"`
+	Count             Uint64     `json:"count"`
+	Sum               *float64   `json:"sum,omitempty"`
+	BucketCounts      []Uint64   `json:"bucketCounts"`
+	ExplicitBounds    []float64  `json:"explicitBounds"`
+	Min               *float64   `json:"min,omitempty"`
+	Max               *float64   `json:"max,omitempty"`
+	Flags             uint32     `json:"flags"`
+}
+
+type KeyValue struct {
+	Key   string   `json:"key"`
+	Value AnyValue `json:"value"`
+}
+
+// AnyValue хранит значение атрибута уже в виде строки: в имени метрики метки всё равно строковые.
+type AnyValue struct {
+	StringValue *string          `json:"stringValue,omitempty"`
+	BoolValue   *bool            `json:"boolValue,omitempty"`
+	IntValue    *Int64           `json:"intValue,omitempty"`
+	DoubleValue *float64         `json:"doubleValue,omitempty"`
+	ArrayValue  *json.RawMessage `json:"arrayValue,omitempty"`
+	KvlistValue *json.RawMessage `json:"kvlistValue,omitempty"`
+	BytesValue  *string          `json:"bytesValue,omitempty"`
+}
+
+func (v AnyValue) String() string {
+	switch {
+	case v.StringValue != nil:
+		return *v.StringValue
+	case v.BoolValue != nil:
+		return strconv.FormatBool(*v.BoolValue)
+	case v.IntValue != nil:
+		return strconv.FormatInt(int64(*v.IntValue), 10)
+	case v.DoubleValue != nil:
+		return strconv.FormatFloat(*v.DoubleValue, 'g', -1, 64)
+	case v.ArrayValue != nil:
+		return string(*v.ArrayValue)
+	case v.KvlistValue != nil:
+		return string(*v.KvlistValue)
+	case v.BytesValue != nil:
+		return *v.BytesValue
+	}
+
+	return ""
+}
+
+// Int64 и Uint64 принимают в JSON и число, и строку: OTLP/JSON кодирует 64-битные целые строками.
+type Int64 int64
+
+func (i *Int64) UnmarshalJSON(data []byte) error {
+	v, err := strconv.ParseInt(string(bytes.Trim(data, `"`)), 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: bad integer %s", ErrInvalidPayload, data)
+	}
+	*i = Int64(v)
+
+	return nil
+}
+
+type Uint64 uint64
+
+func (u *Uint64) UnmarshalJSON(data []byte) error {
+	v, err := strconv.ParseUint(string(bytes.Trim(data, `"`)), 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: bad unsigned integer %s", ErrInvalidPayload, data)
+	}
+	*u = Uint64(v)
+
+	return nil
+}
+
+// UnmarshalJSON принимает temporality и числом, и именем значения enum.
+func (t *Temporality) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		switch strings.Trim(string(data), `"`) {
+		case "AGGREGATION_TEMPORALITY_UNSPECIFIED":
+			*t = TemporalityUnspecified
+		case "AGGREGATION_TEMPORALITY_DELTA":
+			*t = TemporalityDelta
+		case "AGGREGATION_TEMPORALITY_CUMULATIVE":
+			*t = TemporalityCumulative
+		default:
+			return fmt.Errorf("%w: unknown temporality %s", ErrInvalidPayload, data)
+		}
+		return nil
+	}
+
+	v, err := strconv.ParseInt(string(data), 10, 32)
+	if err != nil {
+		return fmt.Errorf("%w: bad temporality %s", ErrInvalidPayload, data)
+	}
+	*t = Temporality(v)
+
+	return nil
+}
+
+// DecodeJSON разбирает ExportMetricsServiceRequest в кодировке OTLP/JSON.
+func DecodeJSON(data []byte) (*ExportRequest, error) {
+	var raw struct {
+		ResourceMetrics []struct {
+			Resource     Resource `json:"resource"`
+			ScopeMetrics []struct {
+				Metrics []struct {
+					Metric
+					ExponentialHistogram *struct {
+						DataPoints []json.RawMessage `json:"dataPoints"`
+					} `json:"exponentialHistogram,omitempty"`
+					Summary *struct {
+						DataPoints []json.RawMessage `json:"dataPoints"`
+					} `json:"summary,omitempty"`
+				} `json:"metrics"`
+			} `json:"scopeMetrics"`
+		} `json:"resourceMetrics"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		if errors.Is(err, ErrInvalidPayload) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPayload, err)
+	}
+
+	req := &ExportRequest{}
+	for _, rm := range raw.ResourceMetrics {
+		resource := ResourceMetrics{Resource: rm.Resource}
+		for _, sm := range rm.ScopeMetrics {
+			scope := ScopeMetrics{}
+			for _, m := range sm.Metrics {
+				metric := m.Metric
+				if m.ExponentialHistogram != nil {
+					metric.Unsupported += len(m.ExponentialHistogram.DataPoints)
+				}
+				if m.Summary != nil {
+					metric.Unsupported += len(m.Summary.DataPoints)
+				}
+				scope.Metrics = append(scope.Metrics, metric)
+			}
+			resource.ScopeMetrics = append(resource.ScopeMetrics, scope)
+		}
+		req.ResourceMetrics = append(req.ResourceMetrics, resource)
+	}
+
+	return req, nil
+}
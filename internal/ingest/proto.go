@@ -0,0 +1,86 @@
+package ingest
+
+import (
+	"errors"
+	"fmt"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+var ErrMalformedProto = errors.New("malformed protobuf")
+
+// ProtoField - одно поле protobuf-сообщения: для BytesType заполнен Bytes, для чисел - Scalar.
+// Приёмники разбирают только нужные им поля сообщений без сгенерированного кода.
+type ProtoField struct {
+	Num    protowire.Number
+	Type   protowire.Type
+	Bytes  []byte
+	Scalar uint64
+}
+
+// WalkProto перебирает поля сообщения; неизвестные поля вызывающий просто пропускает.
+func WalkProto(b []byte, fn func(f ProtoField) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return fmt.Errorf("%w: %v", ErrMalformedProto, protowire.ParseError(n))
+		}
+		b = b[n:]
+
+		f := ProtoField{Num: num, Type: typ}
+		switch typ {
+		case protowire.VarintType:
+			f.Scalar, n = protowire.ConsumeVarint(b)
+		case protowire.Fixed64Type:
+			f.Scalar, n = protowire.ConsumeFixed64(b)
+		case protowire.Fixed32Type:
+			var v uint32
+			v, n = protowire.ConsumeFixed32(b)
+			f.Scalar = uint64(v)
+		case protowire.BytesType:
+			f.Bytes, n = protowire.ConsumeBytes(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return fmt.Errorf("%w: %v", ErrMalformedProto, protowire.ParseError(n))
+		}
+		b = b[n:]
+
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (f ProtoField) Expect(typ protowire.Type) error {
+	if f.Type != typ {
+		return fmt.Errorf("%w: field %d has wire type %d", ErrMalformedProto, f.Num, f.Type)
+	}
+
+	return nil
+}
+
+// Fixed64s перебирает repeated fixed64/double как в упакованном, так и в неупакованном виде.
+func (f ProtoField) Fixed64s(fn func(v uint64)) error {
+	if f.Type == protowire.Fixed64Type {
+		fn(f.Scalar)
+		return nil
+	}
+	if err := f.Expect(protowire.BytesType); err != nil {
+		return err
+	}
+
+	b := f.Bytes
+	for len(b) > 0 {
+		v, n := protowire.ConsumeFixed64(b)
+		if n < 0 {
+			return fmt.Errorf("%w: %v", ErrMalformedProto, protowire.ParseError(n))
+		}
+		fn(v)
+		b = b[n:]
+	}
+
+	return nil
+}
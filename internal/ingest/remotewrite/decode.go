@@ -0,0 +1,159 @@
+package remotewrite
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Guram-Gurych/metricserver.git/internal/ingest"
+	"github.com/golang/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+	"math"
+)
+
+// MetricType - MetricMetadata.MetricType из prometheus/prompb.
+type MetricType int32
+
+const (
+	TypeUnknown        MetricType = 0
+	TypeCounter        MetricType = 1
+	TypeGauge          MetricType = 2
+	TypeHistogram      MetricType = 3
+	TypeGaugeHistogram MetricType = 4
+	TypeSummary        MetricType = 5
+)
+
+var (
+	ErrInvalidRequest = errors.New("invalid remote write request")
+	ErrTooLarge       = errors.New("remote write request is too large")
+)
+
+// WriteRequest - подмножество prompb.WriteRequest (remote write 1.0), которое нужно приёмнику.
+type WriteRequest struct {
+	Timeseries []TimeSeries
+	Metadata   []MetricMetadata
+}
+
+type TimeSeries struct {
+	Labels  []Label
+	Samples []Sample
+	// Histograms - число нативных гистограмм, которые приёмник не поддерживает.
+	Histograms int
+}
+
+type Label struct {
+	Name  string
+	Value string
+}
+
+type Sample struct {
+	Value     float64
+	Timestamp int64
+}
+
+type MetricMetadata struct {
+	Type   MetricType
+	Family string
+}
+
+// Decode распаковывает snappy (блочный формат, как шлёт Prometheus) и разбирает WriteRequest.
+// Размер после распаковки записан в заголовке snappy и проверяется до выделения памяти:
+// иначе запрос в несколько байт мог бы запросить буфер до 4 ГиБ.
+func Decode(compressed []byte, maxSize int) (*WriteRequest, error) {
+	n, err := snappy.DecodedLen(compressed)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+	}
+	if n > maxSize {
+		return nil, fmt.Errorf("%w: %d bytes after decompression, limit %d", ErrTooLarge, n, maxSize)
+	}
+
+	b, err := snappy.Decode(nil, compressed)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+	}
+
+	req := &WriteRequest{}
+	err = ingest.WalkProto(b, func(f ingest.ProtoField) error {
+		switch f.Num {
+		case 1:
+			if err := f.Expect(protowire.BytesType); err != nil {
+				return err
+			}
+			ts, err := decodeTimeSeries(f.Bytes)
+			req.Timeseries = append(req.Timeseries, ts)
+			return err
+		case 3:
+			if err := f.Expect(protowire.BytesType); err != nil {
+				return err
+			}
+			md, err := decodeMetadata(f.Bytes)
+			req.Metadata = append(req.Metadata, md)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidRequest, err)
+	}
+
+	return req, nil
+}
+
+func decodeTimeSeries(b []byte) (TimeSeries, error) {
+	ts := TimeSeries{}
+	err := ingest.WalkProto(b, func(f ingest.ProtoField) error {
+		switch f.Num {
+		case 1:
+			if err := f.Expect(protowire.BytesType); err != nil {
+				return err
+			}
+			label := Label{}
+			err := ingest.WalkProto(f.Bytes, func(f ingest.ProtoField) error {
+				switch f.Num {
+				case 1:
+					label.Name = string(f.Bytes)
+				case 2:
+					label.Value = string(f.Bytes)
+				}
+				return nil
+			})
+			ts.Labels = append(ts.Labels, label)
+			return err
+		case 2:
+			if err := f.Expect(protowire.BytesType); err != nil {
+				return err
+			}
+			sample := Sample{}
+			err := ingest.WalkProto(f.Bytes, func(f ingest.ProtoField) error {
+				switch f.Num {
+				case 1:
+					sample.Value = math.Float64frombits(f.Scalar)
+				case 2:
+					sample.Timestamp = int64(f.Scalar)
+				}
+				return nil
+			})
+			ts.Samples = append(ts.Samples, sample)
+			return err
+		case 4:
+			ts.Histograms++
+		}
+		return nil
+	})
+
+	return ts, err
+}
+
+func decodeMetadata(b []byte) (MetricMetadata, error) {
+	md := MetricMetadata{}
+	err := ingest.WalkProto(b, func(f ingest.ProtoField) error {
+		switch f.Num {
+		case 1:
+			md.Type = MetricType(f.Scalar)
+		case 2:
+			md.Family = string(f.Bytes)
+		}
+		return nil
+	})
+
+	return md, err
+}
@@ -0,0 +1,144 @@
+package remotewrite
+
+import (
+	"context"
+	"github.com/Guram-Gurych/metricserver.git/internal/ingest"
+	"github.com/Guram-Gurych/metricserver.git/internal/model"
+	"github.com/Guram-Gurych/metricserver.git/internal/repository"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+const nameLabel = "__name__"
+
+// Result - итог приёма запроса в сериях. Skipped - серии без имени, без значения
+// (только маркеры устаревания или нативные гистограммы) или запоздавшие.
+type Result struct {
+	Written int
+	Skipped int
+}
+
+// Receiver пишет серии remote write в хранилище. Метка __name__ даёт имя, остальные метки
+// становятся частью имени через ingest.Name. Из сэмплов серии берётся самый свежий:
+// хранилище держит только последнее значение, и точки старше уже принятой отбрасываются.
+// Counter-ы Prometheus накоплены, поэтому пишутся через SetCounter; всё остальное - gauge.
+type Receiver struct {
+	repo     repository.MetricRepository
+	ordering *ingest.Ordering
+
+	mu    sync.Mutex
+	types map[string]MetricType
+}
+
+func NewReceiver(repo repository.MetricRepository) *Receiver {
+	return &Receiver{repo: repo, ordering: ingest.NewOrdering(), types: make(map[string]MetricType)}
+}
+
+// SetOrdering задаёт общий с другими приёмниками порядок точек.
+func (r *Receiver) SetOrdering(ordering *ingest.Ordering) {
+	r.ordering = ordering
+}
+
+// Write применяет запрос. Метаданные Prometheus присылает отдельно от сэмплов,
+// поэтому типы семейств запоминаются между запросами.
+func (r *Receiver) Write(ctx context.Context, req *WriteRequest) (Result, error) {
+	r.mu.Lock()
+	for _, md := range req.Metadata {
+		if md.Family != "" {
+			r.types[md.Family] = md.Type
+		}
+	}
+	r.mu.Unlock()
+
+	res := Result{}
+	for _, ts := range req.Timeseries {
+		base, labels := splitLabels(ts.Labels)
+		sample, ok := latestSample(ts.Samples)
+		if base == "" || !ok {
+			res.Skipped++
+			continue
+		}
+
+		name := ingest.Name(base, labels)
+		counter := r.isCounter(base)
+		metricType := models.Gauge
+		if counter {
+			metricType = models.Counter
+		}
+
+		written, err := r.ordering.Apply(metricType, name, time.UnixMilli(sample.Timestamp), func() error {
+			if counter {
+				return r.repo.SetCounter(ctx, name, int64(math.Round(sample.Value)))
+			}
+			return r.repo.UpdateGauge(ctx, name, sample.Value)
+		})
+		if err != nil {
+			return res, err
+		}
+		if !written {
+			res.Skipped++
+			continue
+		}
+		res.Written++
+	}
+
+	return res, nil
+}
+
+// isCounter решает по метаданным семейства, а без них - по суффиксу _total.
+// У гистограмм и summary накоплены _count и _bucket.
+func (r *Receiver) isCounter(name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if t, ok := r.types[name]; ok && t == TypeCounter {
+		return true
+	}
+	if family, ok := strings.CutSuffix(name, "_total"); ok {
+		if t, known := r.types[family]; !known || t == TypeCounter || t == TypeUnknown {
+			return true
+		}
+	}
+	for _, suffix := range []string{"_count", "_bucket"} {
+		if family, ok := strings.CutSuffix(name, suffix); ok {
+			if t := r.types[family]; t == TypeHistogram || t == TypeSummary {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func splitLabels(labels []Label) (string, map[string]string) {
+	name := ""
+	rest := make(map[string]string, len(labels))
+	for _, l := range labels {
+		switch {
+		case l.Name == nameLabel:
+			name = l.Value
+		case l.Value != "":
+			rest[l.Name] = l.Value
+		}
+	}
+
+	return name, rest
+}
+
+// latestSample возвращает самый свежий сэмпл со значением; NaN - это маркеры устаревания.
+func latestSample(samples []Sample) (Sample, bool) {
+	found := false
+	var latest Sample
+	for _, s := range samples {
+		if math.IsNaN(s.Value) || math.IsInf(s.Value, 0) {
+			continue
+		}
+		if !found || s.Timestamp >= latest.Timestamp {
+			latest, found = s, true
+		}
+	}
+
+	return latest, found
+}
@@ -0,0 +1,110 @@
+package remotewrite
+
+import (
+	"context"
+	"github.com/Guram-Gurych/metricserver.git/internal/repository"
+	"github.com/golang/snappy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+	"math"
+	"testing"
+)
+
+func encodeSeries(labels map[string]string, samples ...Sample) []byte {
+	var ts []byte
+	for name, value := range labels {
+		var label []byte
+		label = protowire.AppendTag(label, 1, protowire.BytesType)
+		label = protowire.AppendString(label, name)
+		label = protowire.AppendTag(label, 2, protowire.BytesType)
+		label = protowire.AppendString(label, value)
+		ts = protowire.AppendTag(ts, 1, protowire.BytesType)
+		ts = protowire.AppendBytes(ts, label)
+	}
+	for _, s := range samples {
+		var sample []byte
+		sample = protowire.AppendTag(sample, 1, protowire.Fixed64Type)
+		sample = protowire.AppendFixed64(sample, math.Float64bits(s.Value))
+		sample = protowire.AppendTag(sample, 2, protowire.VarintType)
+		sample = protowire.AppendVarint(sample, uint64(s.Timestamp))
+		ts = protowire.AppendTag(ts, 2, protowire.BytesType)
+		ts = protowire.AppendBytes(ts, sample)
+	}
+
+	return protowire.AppendBytes(protowire.AppendTag(nil, 1, protowire.BytesType), ts)
+}
+
+func encodeMetadata(family string, t MetricType) []byte {
+	var md []byte
+	md = protowire.AppendTag(md, 1, protowire.VarintType)
+	md = protowire.AppendVarint(md, uint64(t))
+	md = protowire.AppendTag(md, 2, protowire.BytesType)
+	md = protowire.AppendString(md, family)
+
+	return protowire.AppendBytes(protowire.AppendTag(nil, 3, protowire.BytesType), md)
+}
+
+func TestDecode(t *testing.T) {
+	var payload []byte
+	payload = append(payload, encodeSeries(map[string]string{"__name__": "up"}, Sample{Value: 1, Timestamp: 1000})...)
+	payload = append(payload, encodeMetadata("up", TypeGauge)...)
+
+	req, err := Decode(snappy.Encode(nil, payload), 1<<20)
+	require.NoError(t, err)
+	assert.Equal(t, &WriteRequest{
+		Timeseries: []TimeSeries{{Labels: []Label{{Name: "__name__", Value: "up"}}, Samples: []Sample{{Value: 1, Timestamp: 1000}}}},
+		Metadata:   []MetricMetadata{{Type: TypeGauge, Family: "up"}},
+	}, req)
+
+	_, err = Decode(payload, 1<<20)
+	assert.ErrorIs(t, err, ErrInvalidRequest, "Тело без snappy должно отклоняться")
+
+	_, err = Decode(snappy.Encode(nil, []byte{0x0a, 0x05, 0x01}), 1<<20)
+	assert.ErrorIs(t, err, ErrInvalidRequest)
+
+	// Заголовок snappy обещает почти 4 ГиБ, хотя данных нет.
+	_, err = Decode([]byte{0xff, 0xff, 0xff, 0xff, 0x0f}, 1<<20)
+	assert.ErrorIs(t, err, ErrTooLarge, "Размер после распаковки должен проверяться до выделения памяти")
+}
+
+func TestReceiver_Write(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemStorage()
+	receiver := NewReceiver(repo)
+
+	_, err := receiver.Write(ctx, &WriteRequest{Metadata: []MetricMetadata{{Type: TypeHistogram, Family: "latency"}}})
+	require.NoError(t, err)
+
+	staleNaN := math.Float64frombits(0x7ff0000000000002)
+	res, err := receiver.Write(ctx, &WriteRequest{Timeseries: []TimeSeries{
+		{Labels: []Label{{Name: "__name__", Value: "http_requests_total"}, {Name: "job", Value: "api"}}, Samples: []Sample{{Value: 5, Timestamp: 2000}, {Value: 3, Timestamp: 1000}}},
+		{Labels: []Label{{Name: "__name__", Value: "latency_count"}}, Samples: []Sample{{Value: 7, Timestamp: 1000}}},
+		{Labels: []Label{{Name: "__name__", Value: "latency_sum"}}, Samples: []Sample{{Value: 1.5, Timestamp: 1000}}},
+		{Labels: []Label{{Name: "__name__", Value: "temperature"}}, Samples: []Sample{{Value: staleNaN, Timestamp: 1000}}},
+		{Labels: []Label{{Name: "job", Value: "api"}}, Samples: []Sample{{Value: 1, Timestamp: 1000}}},
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, Result{Written: 3, Skipped: 2}, res)
+
+	counter, err := repo.GetCounter(ctx, `http_requests_total{job="api"}`)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), counter, "Должен записываться самый свежий сэмпл серии")
+
+	counter, err = repo.GetCounter(ctx, "latency_count")
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), counter, "_count гистограммы должен быть counter")
+
+	gauge, err := repo.GetGauge(ctx, "latency_sum")
+	require.NoError(t, err)
+	assert.Equal(t, 1.5, gauge)
+
+	_, err = repo.GetGauge(ctx, "temperature")
+	assert.ErrorIs(t, err, repository.ErrNotFound, "Маркер устаревания не должен записываться")
+
+	res, err = receiver.Write(ctx, &WriteRequest{Timeseries: []TimeSeries{
+		{Labels: []Label{{Name: "__name__", Value: "http_requests_total"}, {Name: "job", Value: "api"}}, Samples: []Sample{{Value: 4, Timestamp: 1500}}},
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, Result{Skipped: 1}, res, "Запоздавшая серия должна отбрасываться")
+}
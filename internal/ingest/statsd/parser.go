@@ -0,0 +1,78 @@
+package statsd
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	TypeCounter      = "c"
+	TypeGauge        = "g"
+	TypeTiming       = "ms"
+	TypeHistogram    = "h"
+	TypeDistribution = "d"
+	TypeSet          = "s"
+)
+
+var ErrInvalidLine = errors.New("invalid statsd line")
+
+// Sample - одна разобранная строка name:value|type[|@rate][|#tags].
+type Sample struct {
+	Name  string
+	Type  string
+	Value float64
+	// Raw - исходное значение; для set это элемент множества.
+	Raw string
+	// Rate - частота семплирования из @rate, 1 если не указана.
+	Rate float64
+	// Delta - для gauge значение со знаком +/- меняет текущее значение, а не заменяет его.
+	Delta bool
+}
+
+func ParseLine(line string) (Sample, error) {
+	name, rest, ok := strings.Cut(line, ":")
+	if !ok || name == "" {
+		return Sample{}, fmt.Errorf("%w: missing name", ErrInvalidLine)
+	}
+
+	fields := strings.Split(rest, "|")
+	if len(fields) < 2 || fields[0] == "" {
+		return Sample{}, fmt.Errorf("%w: missing value or type", ErrInvalidLine)
+	}
+
+	s := Sample{Name: name, Raw: fields[0], Type: fields[1], Rate: 1}
+	for _, field := range fields[2:] {
+		switch {
+		case strings.HasPrefix(field, "@"):
+			rate, err := strconv.ParseFloat(field[1:], 64)
+			if err != nil || rate <= 0 || rate > 1 {
+				return Sample{}, fmt.Errorf("%w: bad sample rate %q", ErrInvalidLine, field)
+			}
+			s.Rate = rate
+		case strings.HasPrefix(field, "#"):
+			// Теги DogStatsD пока не поддерживаются и отбрасываются.
+		default:
+			return Sample{}, fmt.Errorf("%w: unknown field %q", ErrInvalidLine, field)
+		}
+	}
+
+	switch s.Type {
+	case TypeSet:
+		return s, nil
+	case TypeCounter, TypeGauge, TypeTiming, TypeHistogram, TypeDistribution:
+	default:
+		return Sample{}, fmt.Errorf("%w: unknown type %q", ErrInvalidLine, s.Type)
+	}
+
+	value, err := strconv.ParseFloat(s.Raw, 64)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		return Sample{}, fmt.Errorf("%w: bad value %q", ErrInvalidLine, s.Raw)
+	}
+	s.Value = value
+	s.Delta = s.Type == TypeGauge && (s.Raw[0] == '+' || s.Raw[0] == '-')
+
+	return s, nil
+}
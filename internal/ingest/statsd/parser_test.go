@@ -0,0 +1,41 @@
+package statsd
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestParseLine(t *testing.T) {
+	tests := []struct {
+		name     string
+		line     string
+		expected Sample
+		wantErr  bool
+	}{
+		{name: "Counter", line: "requests:3|c", expected: Sample{Name: "requests", Type: TypeCounter, Value: 3, Raw: "3", Rate: 1}},
+		{name: "Counter с частотой", line: "requests:1|c|@0.1", expected: Sample{Name: "requests", Type: TypeCounter, Value: 1, Raw: "1", Rate: 0.1}},
+		{name: "Gauge", line: "load:0.5|g", expected: Sample{Name: "load", Type: TypeGauge, Value: 0.5, Raw: "0.5", Rate: 1}},
+		{name: "Приращение gauge", line: "load:-2|g", expected: Sample{Name: "load", Type: TypeGauge, Value: -2, Raw: "-2", Rate: 1, Delta: true}},
+		{name: "Тайминг с тегами", line: "latency:320|ms|#env:prod", expected: Sample{Name: "latency", Type: TypeTiming, Value: 320, Raw: "320", Rate: 1}},
+		{name: "Set", line: "users:alice|s", expected: Sample{Name: "users", Type: TypeSet, Raw: "alice", Rate: 1}},
+		{name: "Без типа", line: "requests:3", wantErr: true},
+		{name: "Без имени", line: ":3|c", wantErr: true},
+		{name: "Неизвестный тип", line: "requests:3|x", wantErr: true},
+		{name: "Нечисловое значение", line: "requests:abc|c", wantErr: true},
+		{name: "Неверная частота", line: "requests:1|c|@2", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sample, err := ParseLine(test.line)
+			if test.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidLine)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, sample)
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package statsd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"github.com/Guram-Gurych/metricserver.git/internal/repository"
+	"github.com/Guram-Gurych/metricserver.git/internal/sketch"
+	"go.uber.org/zap"
+	"math"
+	"net"
+	"strings"
+	"sync/atomic"
+)
+
+const maxPacketSize = 65535
+
+// Stats - счётчики приёма с момента запуска.
+type Stats struct {
+	Packets      uint64
+	PacketErrors uint64
+	Lines        uint64
+	LineErrors   uint64
+}
+
+// Server принимает StatsD по UDP и TCP и пишет метрики в хранилище:
+// c - counter, g - gauge, ms/h/d - summary, s - set.
+type Server struct {
+	repo   repository.MetricRepository
+	logger *zap.Logger
+
+	packets      atomic.Uint64
+	packetErrors atomic.Uint64
+	lines        atomic.Uint64
+	lineErrors   atomic.Uint64
+}
+
+func NewServer(repo repository.MetricRepository, logger *zap.Logger) *Server {
+	return &Server{repo: repo, logger: logger}
+}
+
+func (s *Server) Stats() Stats {
+	return Stats{
+		Packets:      s.packets.Load(),
+		PacketErrors: s.packetErrors.Load(),
+		Lines:        s.lines.Load(),
+		LineErrors:   s.lineErrors.Load(),
+	}
+}
+
+// ServeUDP читает датаграммы, пока conn не закроют. Одна датаграмма - один пакет.
+func (s *Server) ServeUDP(conn net.PacketConn) error {
+	buf := make([]byte, maxPacketSize)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+
+		if errs := s.HandlePacket(context.Background(), buf[:n]); errs > 0 {
+			s.logger.Debug("StatsD packet had invalid lines", zap.Stringer("from", addr), zap.Int("errors", errs))
+		}
+	}
+}
+
+// ServeTCP принимает соединения, пока l не закроют. В TCP каждая строка считается отдельным пакетом.
+func (s *Server) ServeTCP(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+
+		go s.serveConn(conn)
+	}
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer conn.Close()
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 4096), maxPacketSize)
+	for scanner.Scan() {
+		if errs := s.HandlePacket(context.Background(), scanner.Bytes()); errs > 0 {
+			s.logger.Debug("StatsD line is invalid", zap.Stringer("from", conn.RemoteAddr()))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		s.logger.Warn("StatsD connection closed with error", zap.Stringer("from", conn.RemoteAddr()), zap.Error(err))
+	}
+}
+
+// HandlePacket разбирает строки пакета и применяет их к хранилищу.
+// Ошибочные строки пропускаются, остальные применяются; возвращается число ошибок.
+func (s *Server) HandlePacket(ctx context.Context, packet []byte) int {
+	s.packets.Add(1)
+
+	errs := 0
+	for _, line := range strings.Split(string(packet), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		s.lines.Add(1)
+
+		sample, err := ParseLine(line)
+		if err == nil {
+			err = s.apply(ctx, sample)
+		}
+		if err != nil {
+			errs++
+			s.lineErrors.Add(1)
+			s.logger.Debug("Failed to apply StatsD line", zap.String("line", line), zap.Error(err))
+		}
+	}
+
+	if errs > 0 {
+		s.packetErrors.Add(1)
+	}
+
+	return errs
+}
+
+func (s *Server) apply(ctx context.Context, sample Sample) error {
+	switch sample.Type {
+	case TypeCounter:
+		return s.repo.UpdateCounter(ctx, sample.Name, int64(math.Round(sample.Value/sample.Rate)))
+	case TypeGauge:
+		if !sample.Delta {
+			return s.repo.UpdateGauge(ctx, sample.Name, sample.Value)
+		}
+		_, err := s.repo.AddGauge(ctx, sample.Name, sample.Value)
+		return err
+	case TypeTiming, TypeHistogram, TypeDistribution:
+		summary := sketch.NewDDSketch(sketch.DefaultRelativeAccuracy)
+		weight := uint64(math.Max(1, math.Round(1/sample.Rate)))
+		if err := summary.AddN(sample.Value, weight); err != nil {
+			return err
+		}
+		return s.repo.UpdateSummary(ctx, sample.Name, summary)
+	case TypeSet:
+		set := sketch.NewHyperLogLog(sketch.DefaultPrecision)
+		set.Add(sample.Raw)
+		return s.repo.UpdateSet(ctx, sample.Name, set)
+	}
+
+	return ErrInvalidLine
+}
@@ -0,0 +1,97 @@
+package statsd
+
+import (
+	"context"
+	"github.com/Guram-Gurych/metricserver.git/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestServer_HandlePacket(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemStorage()
+	srv := NewServer(repo, zap.NewNop())
+
+	errs := srv.HandlePacket(ctx, []byte("requests:1|c|@0.5\nload:10|g\nload:-3|g\nlatency:100|ms|@0.25\nusers:alice|s\nbroken\n"))
+	assert.Equal(t, 1, errs)
+
+	counter, err := repo.GetCounter(ctx, "requests")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), counter, "Counter должен учитывать частоту семплирования")
+
+	gauge, err := repo.GetGauge(ctx, "load")
+	require.NoError(t, err)
+	assert.Equal(t, float64(7), gauge, "Значение со знаком должно менять gauge, а не заменять")
+
+	summary, err := repo.GetSummary(ctx, "latency")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(4), summary.Count)
+
+	set, err := repo.GetSet(ctx, "users")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), set.Estimate())
+
+	assert.Equal(t, Stats{Packets: 1, PacketErrors: 1, Lines: 6, LineErrors: 1}, srv.Stats())
+}
+
+func TestServer_ConcurrentGaugeDelta(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemStorage()
+	srv := NewServer(repo, zap.NewNop())
+
+	const workers, packets = 8, 200
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < packets; i++ {
+				srv.HandlePacket(ctx, []byte("connections:+1|g"))
+			}
+		}()
+	}
+	wg.Wait()
+
+	gauge, err := repo.GetGauge(ctx, "connections")
+	require.NoError(t, err)
+	assert.Equal(t, float64(workers*packets), gauge, "Параллельные приращения gauge не должны теряться")
+}
+
+func TestServer_UDPAndTCP(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemStorage()
+	srv := NewServer(repo, zap.NewNop())
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+	go srv.ServeUDP(conn)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	go srv.ServeTCP(l)
+
+	udp, err := net.Dial("udp", conn.LocalAddr().String())
+	require.NoError(t, err)
+	defer udp.Close()
+	_, err = udp.Write([]byte("udp.requests:5|c"))
+	require.NoError(t, err)
+
+	tcp, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	_, err = tcp.Write([]byte("tcp.requests:2|c\ntcp.requests:3|c\n"))
+	require.NoError(t, err)
+	tcp.Close()
+
+	require.Eventually(t, func() bool {
+		udpCounter, udpErr := repo.GetCounter(ctx, "udp.requests")
+		tcpCounter, tcpErr := repo.GetCounter(ctx, "tcp.requests")
+		return udpErr == nil && tcpErr == nil && udpCounter == 5 && tcpCounter == 5
+	}, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,132 @@
+package ingest
+
+import (
+	"context"
+	"github.com/Guram-Gurych/metricserver.git/internal/model"
+	"github.com/Guram-Gurych/metricserver.git/internal/repository"
+	"github.com/Guram-Gurych/metricserver.git/internal/sketch"
+	"time"
+)
+
+// Storage передаёт вызовы хранилищу и забывает в Ordering удалённые, вытесненные
+// по TTL и заменённые восстановлением метрики, чтобы Ordering не рос бесконечно
+// и новая метрика с тем же именем не отвергалась из-за старой.
+type Storage struct {
+	repo     repository.MetricRepository
+	ordering *Ordering
+}
+
+func NewStorage(repo repository.MetricRepository, ordering *Ordering) *Storage {
+	return &Storage{repo: repo, ordering: ordering}
+}
+
+func (s *Storage) DeleteGauge(ctx context.Context, name string) error {
+	if err := s.repo.DeleteGauge(ctx, name); err != nil {
+		return err
+	}
+	s.ordering.Forget(models.Gauge, name)
+	return nil
+}
+
+func (s *Storage) DeleteCounter(ctx context.Context, name string) error {
+	if err := s.repo.DeleteCounter(ctx, name); err != nil {
+		return err
+	}
+	s.ordering.Forget(models.Counter, name)
+	return nil
+}
+
+func (s *Storage) EvictStale(ctx context.Context, before time.Time) (int, error) {
+	evicted, err := s.repo.EvictStale(ctx, before)
+	if err != nil {
+		return evicted, err
+	}
+	s.ordering.ForgetBefore(before)
+	return evicted, nil
+}
+
+func (s *Storage) Restore(ctx context.Context, snapshot repository.Snapshot, mode repository.RestoreMode) error {
+	if err := s.repo.Restore(ctx, snapshot, mode); err != nil {
+		return err
+	}
+	if mode == repository.RestoreReplace {
+		s.ordering.Reset()
+	}
+	return nil
+}
+
+func (s *Storage) UpdateGauge(ctx context.Context, name string, value float64) error {
+	return s.repo.UpdateGauge(ctx, name, value)
+}
+
+func (s *Storage) AddGauge(ctx context.Context, name string, delta float64) (float64, error) {
+	return s.repo.AddGauge(ctx, name, delta)
+}
+
+func (s *Storage) UpdateCounter(ctx context.Context, name string, value int64) error {
+	return s.repo.UpdateCounter(ctx, name, value)
+}
+
+func (s *Storage) SetCounter(ctx context.Context, name string, value int64) error {
+	return s.repo.SetCounter(ctx, name, value)
+}
+
+func (s *Storage) UpdateSummary(ctx context.Context, name string, value *sketch.DDSketch) error {
+	return s.repo.UpdateSummary(ctx, name, value)
+}
+
+func (s *Storage) UpdateSet(ctx context.Context, name string, value *sketch.HyperLogLog) error {
+	return s.repo.UpdateSet(ctx, name, value)
+}
+
+func (s *Storage) ResetCounter(ctx context.Context, name string) error {
+	return s.repo.ResetCounter(ctx, name)
+}
+
+func (s *Storage) DeleteSummary(ctx context.Context, name string) error {
+	return s.repo.DeleteSummary(ctx, name)
+}
+
+func (s *Storage) DeleteSet(ctx context.Context, name string) error {
+	return s.repo.DeleteSet(ctx, name)
+}
+
+func (s *Storage) GetGauge(ctx context.Context, name string) (float64, error) {
+	return s.repo.GetGauge(ctx, name)
+}
+
+func (s *Storage) GetCounter(ctx context.Context, name string) (int64, error) {
+	return s.repo.GetCounter(ctx, name)
+}
+
+func (s *Storage) GetAllGauges(ctx context.Context) (map[string]float64, error) {
+	return s.repo.GetAllGauges(ctx)
+}
+
+func (s *Storage) GetAllCounters(ctx context.Context) (map[string]int64, error) {
+	return s.repo.GetAllCounters(ctx)
+}
+
+func (s *Storage) GetSummary(ctx context.Context, name string) (*sketch.DDSketch, error) {
+	return s.repo.GetSummary(ctx, name)
+}
+
+func (s *Storage) GetAllSummaries(ctx context.Context) (map[string]*sketch.DDSketch, error) {
+	return s.repo.GetAllSummaries(ctx)
+}
+
+func (s *Storage) GetSet(ctx context.Context, name string) (*sketch.HyperLogLog, error) {
+	return s.repo.GetSet(ctx, name)
+}
+
+func (s *Storage) GetAllSets(ctx context.Context) (map[string]*sketch.HyperLogLog, error) {
+	return s.repo.GetAllSets(ctx)
+}
+
+func (s *Storage) GetUpdatedAt(ctx context.Context, metricType, name string) (time.Time, error) {
+	return s.repo.GetUpdatedAt(ctx, metricType, name)
+}
+
+func (s *Storage) List(ctx context.Context, opts repository.ListOptions) (*repository.ListPage, error) {
+	return s.repo.List(ctx, opts)
+}
@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWriteAuth(t *testing.T) {
+	tests := []struct {
+		name           string
+		token          string
+		header         string
+		expectedStatus int
+	}{
+		{name: "Токен не задан", token: "", header: "", expectedStatus: http.StatusNoContent},
+		{name: "Bearer", token: "secret", header: "Bearer secret", expectedStatus: http.StatusNoContent},
+		{name: "Token, как у Telegraf", token: "secret", header: "Token secret", expectedStatus: http.StatusNoContent},
+		{name: "Неверный токен", token: "secret", header: "Bearer wrong", expectedStatus: http.StatusUnauthorized},
+		{name: "Без заголовка", token: "secret", header: "", expectedStatus: http.StatusUnauthorized},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/update/", nil)
+			if test.header != "" {
+				req.Header.Set("Authorization", test.header)
+			}
+			rr := httptest.NewRecorder()
+			WriteAuth(test.token)(next).ServeHTTP(rr, req)
+
+			assert.Equal(t, test.expectedStatus, rr.Code)
+		})
+	}
+}
@@ -1,8 +1,10 @@
 package middleware
 
 import (
+	"bufio"
 	"compress/gzip"
 	"io"
+	"net"
 	"net/http"
 	"strings"
 )
@@ -44,6 +46,17 @@ func (c *compressWriter) WriteHeader(statusCode int) {
 	c.w.WriteHeader(statusCode)
 }
 
+func (c *compressWriter) Flush() {
+	if c.shouldCompress {
+		c.zw.Flush()
+	}
+	http.NewResponseController(c.w).Flush()
+}
+
+func (c *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(c.w).Hijack()
+}
+
 func (c *compressWriter) Close() error {
 	if c.shouldCompress {
 		return c.zw.Close()
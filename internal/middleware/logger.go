@@ -1,8 +1,10 @@
 package middleware
 
 import (
+	"bufio"
 	"github.com/Guram-Gurych/metricserver.git/internal/logger"
 	"go.uber.org/zap"
+	"net"
 	"net/http"
 	"time"
 )
@@ -28,6 +30,15 @@ func (r *loggingResponseWrite) WriteHeader(statusCode int) {
 	r.data.status = statusCode
 }
 
+// Flush и Hijack нужны потоковым ответам (SSE, WebSocket) за этой обёрткой.
+func (r *loggingResponseWrite) Flush() {
+	http.NewResponseController(r.ResponseWriter).Flush()
+}
+
+func (r *loggingResponseWrite) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(r.ResponseWriter).Hijack()
+}
+
 func RequestLogger(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
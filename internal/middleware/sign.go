@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+)
+
+// SignatureHeader содержит HMAC-SHA256 несжатого тела запроса в hex.
+const SignatureHeader = "HashSHA256"
+
+// VerifySignature проверяет подпись тела запроса ключом key. С ключом запросы без подписи
+// отклоняются с 401, поэтому middleware ставится только на ручки, клиенты которых умеют
+// подписывать (агент, relay, metricsclient, metricctl); Telegraf и Prometheus защищаются
+// только токеном записи. Тело читается в память целиком, поэтому оно ограничено
+// maxBodySize байтами. Без ключа проверка отключена.
+func VerifySignature(key string, maxBodySize int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			signature := r.Header.Get(SignatureHeader)
+			if signature == "" {
+				http.Error(w, "Missing signature", http.StatusUnauthorized)
+				return
+			}
+
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+			if err != nil {
+				var tooLarge *http.MaxBytesError
+				if errors.As(err, &tooLarge) {
+					http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+					return
+				}
+				http.Error(w, "Failed to read request body", http.StatusBadRequest)
+				return
+			}
+			r.Body.Close()
+
+			provided, err := hex.DecodeString(signature)
+			if err != nil || !hmac.Equal(provided, Sign([]byte(key), body)) {
+				http.Error(w, "Invalid signature", http.StatusBadRequest)
+				return
+			}
+
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Sign возвращает HMAC-SHA256 данных.
+func Sign(key, data []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"encoding/hex"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestVerifySignature(t *testing.T) {
+	body := `[{"id":"Alloc","type":"gauge","value":1}]`
+	valid := hex.EncodeToString(Sign([]byte("secret"), []byte(body)))
+
+	tests := []struct {
+		name           string
+		key            string
+		signature      string
+		maxBodySize    int64
+		expectedStatus int
+	}{
+		{name: "Ключ не задан", key: "", signature: "deadbeef", expectedStatus: http.StatusOK},
+		{name: "Верная подпись", key: "secret", signature: valid, expectedStatus: http.StatusOK},
+		{name: "Без подписи", key: "secret", signature: "", expectedStatus: http.StatusUnauthorized},
+		{name: "Неверная подпись", key: "secret", signature: "deadbeef", expectedStatus: http.StatusBadRequest},
+		{name: "Не hex", key: "secret", signature: "zz", expectedStatus: http.StatusBadRequest},
+		{name: "Тело больше лимита", key: "secret", signature: valid, maxBodySize: 10, expectedStatus: http.StatusRequestEntityTooLarge},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got, _ := io.ReadAll(r.Body)
+				assert.Equal(t, body, string(got), "Тело должно дойти до обработчика целиком")
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/updates/", strings.NewReader(body))
+			if test.signature != "" {
+				req.Header.Set(SignatureHeader, test.signature)
+			}
+			rr := httptest.NewRecorder()
+			maxBodySize := test.maxBodySize
+			if maxBodySize == 0 {
+				maxBodySize = 1 << 20
+			}
+			VerifySignature(test.key, maxBodySize)(next).ServeHTTP(rr, req)
+
+			assert.Equal(t, test.expectedStatus, rr.Code)
+		})
+	}
+}
@@ -1,8 +1,15 @@
 package models
 
+import (
+	"github.com/Guram-Gurych/metricserver.git/internal/sketch"
+	"time"
+)
+
 const (
 	Counter = "counter"
 	Gauge   = "gauge"
+	Summary = "summary"
+	Set     = "set"
 )
 
 // NOTE: Не усложняем пример, вводя иерархическую вложенность структур.
@@ -16,4 +23,18 @@ type Metrics struct {
 	Delta *int64   `json:"delta,omitempty"`
 	Value *float64 `json:"value,omitempty"`
 	Hash  string   `json:"hash,omitempty"`
+	Stale bool     `json:"stale,omitempty"`
+
+	// Для summary: Sketch - скетч, накопленный агентом,
+	// Quantile - запрашиваемый квантиль при чтении.
+	Sketch   *sketch.DDSketch `json:"sketch,omitempty"`
+	Quantile *float64         `json:"quantile,omitempty"`
+
+	// Для set: агент присылает либо сырые элементы Members,
+	// либо уже посчитанный на своей стороне скетч HLL.
+	Members []string            `json:"members,omitempty"`
+	HLL     *sketch.HyperLogLog `json:"hll,omitempty"`
+
+	// UpdatedAt заполняется при листинге хранилища.
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
 }
@@ -0,0 +1,93 @@
+package persistence
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var errCommitterStopped = errors.New("WAL is closed")
+
+// groupCommitter собирает записи от параллельных запросов в одну пачку
+// и сбрасывает её на диск не чаще раза в окно. Каждый вызов commit
+// возвращается только после fsync той пачки, в которую попала его запись.
+type groupCommitter struct {
+	window time.Duration
+	flush  func(batch []byte, records int) error
+
+	mu      sync.Mutex
+	pending []byte
+	waiters []chan error
+	kick    chan struct{}
+	done    chan struct{}
+	stopped bool
+}
+
+func newGroupCommitter(window time.Duration, flush func(batch []byte, records int) error) *groupCommitter {
+	g := &groupCommitter{
+		window: window,
+		flush:  flush,
+		kick:   make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+	go g.run()
+
+	return g
+}
+
+func (g *groupCommitter) commit(line []byte) error {
+	result := make(chan error, 1)
+
+	g.mu.Lock()
+	if g.stopped {
+		g.mu.Unlock()
+		return errCommitterStopped
+	}
+	g.pending = append(g.pending, line...)
+	g.waiters = append(g.waiters, result)
+	select {
+	case g.kick <- struct{}{}:
+	default:
+	}
+	g.mu.Unlock()
+
+	return <-result
+}
+
+func (g *groupCommitter) run() {
+	defer close(g.done)
+
+	for range g.kick {
+		if g.window > 0 {
+			time.Sleep(g.window)
+		}
+
+		g.mu.Lock()
+		batch, waiters := g.pending, g.waiters
+		g.pending, g.waiters = nil, nil
+		g.mu.Unlock()
+
+		if len(waiters) == 0 {
+			continue
+		}
+
+		err := g.flush(batch, len(waiters))
+		for _, w := range waiters {
+			w <- err
+		}
+	}
+}
+
+// stop дожидается сброса уже принятых записей и останавливает фоновую горутину.
+func (g *groupCommitter) stop() {
+	g.mu.Lock()
+	if g.stopped {
+		g.mu.Unlock()
+		return
+	}
+	g.stopped = true
+	close(g.kick)
+	g.mu.Unlock()
+
+	<-g.done
+}
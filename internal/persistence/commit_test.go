@@ -0,0 +1,45 @@
+package persistence
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestGroupCommitter(t *testing.T) {
+	var mu sync.Mutex
+	var flushes, records int
+	var written []byte
+
+	g := newGroupCommitter(10*time.Millisecond, func(batch []byte, n int) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		flushes++
+		records += n
+		written = append(written, batch...)
+		return nil
+	})
+
+	const writers = 100
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, g.commit([]byte("x")))
+		}()
+	}
+	wg.Wait()
+	g.stop()
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, writers, records, "Каждая запись должна быть сброшена")
+	assert.Len(t, written, writers)
+	assert.Less(t, flushes, writers, "Параллельные записи должны объединяться в пачки")
+
+	require.ErrorIs(t, g.commit([]byte("x")), errCommitterStopped)
+}
@@ -0,0 +1,170 @@
+package persistence
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Guram-Gurych/metricserver.git/internal/repository"
+	"github.com/klauspost/compress/zstd"
+	"io"
+	"time"
+)
+
+const (
+	CompressionNone = "none"
+	CompressionGzip = "gzip"
+	CompressionZstd = "zstd"
+
+	// Версия 1 - исходный формат без конверта: сразу объект repository.Snapshot.
+	legacySnapshotVersion  = 1
+	currentSnapshotVersion = 2
+
+	checksumPrefix = "sha256:"
+)
+
+var (
+	ErrUnsupportedVersion = errors.New("unsupported snapshot version")
+	ErrChecksumMismatch   = errors.New("snapshot checksum mismatch")
+	ErrUnknownCompression = errors.New("unknown compression")
+
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+type snapshotEnvelope struct {
+	Version       int             `json:"version"`
+	WrittenAt     time.Time       `json:"written_at"`
+	ServerVersion string          `json:"server_version,omitempty"`
+	Checksum      string          `json:"checksum"`
+	Data          json.RawMessage `json:"data"`
+}
+
+func ValidCompression(compression string) bool {
+	switch compression {
+	case "", CompressionNone, CompressionGzip, CompressionZstd:
+		return true
+	}
+
+	return false
+}
+
+func encodeSnapshot(storage repository.Snapshot, serverVersion, compression string) ([]byte, error) {
+	data, err := json.Marshal(storage)
+	if err != nil {
+		return nil, err
+	}
+
+	envelope := snapshotEnvelope{
+		Version:       currentSnapshotVersion,
+		WrittenAt:     time.Now().UTC(),
+		ServerVersion: serverVersion,
+		Checksum:      checksum(data),
+		Data:          data,
+	}
+	encoded, err := json.Marshal(envelope)
+	if err != nil {
+		return nil, err
+	}
+
+	return compress(encoded, compression)
+}
+
+// decodeSnapshot распознаёт сжатие по сигнатуре и поднимает старые версии формата до текущей.
+func decodeSnapshot(raw []byte) (*repository.Snapshot, error) {
+	data, err := decompress(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	var probe struct {
+		Version int `json:"version"`
+	}
+	if err = json.Unmarshal(data, &probe); err != nil {
+		return nil, err
+	}
+
+	version := probe.Version
+	if version == 0 {
+		version = legacySnapshotVersion
+	}
+
+	var payload json.RawMessage
+	switch version {
+	case legacySnapshotVersion:
+		payload = data
+	case currentSnapshotVersion:
+		var envelope snapshotEnvelope
+		if err = json.Unmarshal(data, &envelope); err != nil {
+			return nil, err
+		}
+		if envelope.Checksum != checksum(envelope.Data) {
+			return nil, ErrChecksumMismatch
+		}
+		payload = envelope.Data
+	default:
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedVersion, version)
+	}
+
+	var storage repository.Snapshot
+	if err = json.Unmarshal(payload, &storage); err != nil {
+		return nil, err
+	}
+
+	return &storage, nil
+}
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return checksumPrefix + hex.EncodeToString(sum[:])
+}
+
+func compress(data []byte, compression string) ([]byte, error) {
+	switch compression {
+	case "", CompressionNone:
+		return data, nil
+	case CompressionGzip:
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(data); err != nil {
+			return nil, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case CompressionZstd:
+		zw, err := zstd.NewWriter(nil)
+		if err != nil {
+			return nil, err
+		}
+		defer zw.Close()
+		return zw.EncodeAll(data, nil), nil
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrUnknownCompression, compression)
+}
+
+func decompress(data []byte) ([]byte, error) {
+	switch {
+	case bytes.HasPrefix(data, gzipMagic):
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		return io.ReadAll(zr)
+	case bytes.HasPrefix(data, zstdMagic):
+		zr, err := zstd.NewReader(nil)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		return zr.DecodeAll(data, nil)
+	}
+
+	return data, nil
+}
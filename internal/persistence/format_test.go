@@ -0,0 +1,65 @@
+package persistence
+
+import (
+	"github.com/Guram-Gurych/metricserver.git/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestSnapshotFormat_RoundTrip(t *testing.T) {
+	storage := repository.Snapshot{
+		Gauges:   map[string]float64{"Alloc": 1.5},
+		Counters: map[string]int64{"PollCount": 3},
+	}
+
+	for _, compression := range []string{CompressionNone, CompressionGzip, CompressionZstd} {
+		t.Run(compression, func(t *testing.T) {
+			data, err := encodeSnapshot(storage, "v1.0.0", compression)
+			require.NoError(t, err)
+
+			decoded, err := decodeSnapshot(data)
+			require.NoError(t, err)
+			assert.Equal(t, storage.Gauges, decoded.Gauges)
+			assert.Equal(t, storage.Counters, decoded.Counters)
+		})
+	}
+}
+
+func TestSnapshotFormat_Decode(t *testing.T) {
+	tests := []struct {
+		name          string
+		data          string
+		expectedErr   error
+		expectedGauge float64
+	}{
+		{
+			name:          "Старый формат без версии",
+			data:          `{"gauges":{"Alloc":2.5},"counters":{}}`,
+			expectedGauge: 2.5,
+		},
+		{
+			name:        "Неверная контрольная сумма",
+			data:        `{"version":2,"checksum":"sha256:00","data":{"gauges":{"Alloc":2.5}}}`,
+			expectedErr: ErrChecksumMismatch,
+		},
+		{
+			name:        "Версия из будущего",
+			data:        `{"version":99,"data":{}}`,
+			expectedErr: ErrUnsupportedVersion,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			decoded, err := decodeSnapshot([]byte(test.data))
+			if test.expectedErr != nil {
+				assert.ErrorIs(t, err, test.expectedErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedGauge, decoded.Gauges["Alloc"])
+		})
+	}
+}
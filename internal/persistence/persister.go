@@ -1,84 +1,186 @@
 package persistence
 
 import (
-	"encoding/json"
+	"context"
 	"errors"
+	"fmt"
 	"github.com/Guram-Gurych/metricserver.git/internal/repository"
 	"go.uber.org/zap"
 	"os"
+	"path/filepath"
+	"sync"
+	"time"
 )
 
-type storageFile struct {
-	Gauges   map[string]float64 `json:"gauges"`
-	Counters map[string]int64   `json:"counters"`
-}
-
 type Persister struct {
-	repo     repository.MetricRepository
-	filePath string
-	logger   *zap.Logger
+	repo          repository.MetricRepository
+	filePath      string
+	logger        *zap.Logger
+	compression   string
+	serverVersion string
+	restoreMode   repository.RestoreMode
+
+	// mu защищает снимок от записей через WAL: Record держит его на чтение,
+	// Save - на запись, чтобы ни одно обновление не попало между снимком и очисткой журнала.
+	mu         sync.RWMutex
+	walMu      sync.Mutex
+	wal        *os.File
+	walRecords int
+	syncWindow time.Duration
+	commitOnce sync.Once
+	commits    *groupCommitter
 }
 
 func NewPersister(repo repository.MetricRepository, filePath string, logger *zap.Logger) *Persister {
 	return &Persister{
-		repo:     repo,
-		filePath: filePath,
-		logger:   logger,
+		repo:        repo,
+		filePath:    filePath,
+		logger:      logger,
+		restoreMode: repository.RestoreReplace,
 	}
 }
 
-func (p *Persister) Save() error {
+// SetFormat задаёт сжатие снимка и версию сервера, записываемую в его заголовок.
+func (p *Persister) SetFormat(compression, serverVersion string) error {
+	if !ValidCompression(compression) {
+		return fmt.Errorf("%w: %s", ErrUnknownCompression, compression)
+	}
+
+	p.compression = compression
+	p.serverVersion = serverVersion
+	return nil
+}
+
+// SetSyncWindow задаёт окно, в течение которого записи журнала копятся перед общим fsync.
+func (p *Persister) SetSyncWindow(window time.Duration) {
+	p.syncWindow = window
+}
+
+// SetRestoreMode задаёт, заменяет ли Load содержимое хранилища или дополняет его.
+func (p *Persister) SetRestoreMode(mode repository.RestoreMode) error {
+	if _, err := repository.ParseRestoreMode(string(mode)); err != nil {
+		return err
+	}
+
+	p.restoreMode = mode
+	return nil
+}
+
+func (p *Persister) Save(ctx context.Context) error {
 	if p.filePath == "" {
 		return nil
 	}
 
-	gauges := p.repo.GetAllGauges()
-	counters := p.repo.GetAllCounters()
+	p.mu.Lock()
+	defer p.mu.Unlock()
 
-	storage := storageFile{Gauges: gauges, Counters: counters}
-	storageJSON, err := json.Marshal(storage)
+	storage, err := repository.TakeSnapshot(ctx, p.repo)
 	if err != nil {
 		return err
 	}
 
-	if err = os.WriteFile(p.filePath, storageJSON, 0644); err != nil {
+	snapshot, err := encodeSnapshot(*storage, p.serverVersion, p.compression)
+	if err != nil {
 		return err
 	}
 
-	return nil
+	if err = WriteFileAtomic(p.filePath, snapshot, 0644); err != nil {
+		return err
+	}
+
+	return p.truncateWAL()
+}
+
+// WriteFileAtomic пишет данные во временный файл рядом с целевым, синхронизирует его
+// и переименовывает, а затем синхронизирует каталог. Падение посреди записи оставляет
+// на диске предыдущий целый файл, а после возврата без ошибки файл переживёт сбой питания.
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName)
+
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Chmod(tmpName, perm); err != nil {
+		return err
+	}
+	if err = os.Rename(tmpName, path); err != nil {
+		return err
+	}
+
+	return syncDir(dir)
 }
 
-func (p *Persister) Load() error {
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	return d.Sync()
+}
+
+// Load собирает снимок и журнал во временном хранилище и отдаёт результат в Restore,
+// поэтому повторная загрузка не удваивает счётчики ни в одном из режимов.
+func (p *Persister) Load(ctx context.Context) error {
 	if p.filePath == "" {
 		return nil
 	}
 
-	data, err := os.ReadFile(p.filePath)
+	staging := repository.NewMemStorage()
+	if err := p.loadSnapshot(ctx, staging); err != nil {
+		return err
+	}
+	walUpdatedAt, err := p.replayWAL(ctx, staging)
 	if err != nil {
-		if errors.Is(err, os.ErrNotExist) {
-			return nil
-		}
 		return err
 	}
 
-	var storage storageFile
-	if err = json.Unmarshal(data, &storage); err != nil {
+	storage, err := repository.TakeSnapshot(ctx, staging)
+	if err != nil {
 		return err
 	}
-
-	for key, value := range storage.Gauges {
-		err = p.repo.UpdateGauge(key, value)
-		if err != nil {
-			return err
+	// Журнал применялся к staging сейчас, поэтому время обновления берётся из записей.
+	for metricType, names := range walUpdatedAt {
+		if storage.UpdatedAt[metricType] == nil {
+			storage.UpdatedAt[metricType] = make(map[string]time.Time)
+		}
+		for name, updatedAt := range names {
+			storage.UpdatedAt[metricType][name] = updatedAt
 		}
 	}
 
-	for key, value := range storage.Counters {
-		err = p.repo.UpdateCounter(key, value)
-		if err != nil {
-			return err
+	return p.repo.Restore(ctx, *storage, p.restoreMode)
+}
+
+func (p *Persister) loadSnapshot(ctx context.Context, repo repository.MetricRepository) error {
+	data, err := os.ReadFile(p.filePath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
 		}
+		return err
 	}
 
-	return nil
+	storage, err := decodeSnapshot(data)
+	if err != nil {
+		return err
+	}
+
+	return repo.Restore(ctx, *storage, repository.RestoreReplace)
 }
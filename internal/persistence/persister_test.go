@@ -0,0 +1,213 @@
+package persistence
+
+import (
+	"context"
+	"github.com/Guram-Gurych/metricserver.git/internal/model"
+	"github.com/Guram-Gurych/metricserver.git/internal/repository"
+	"github.com/Guram-Gurych/metricserver.git/internal/sketch"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPersister_SaveLoad(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "metrics.json")
+
+	storage := repository.NewMemStorage()
+	require.NoError(t, storage.UpdateGauge(ctx, "Alloc", 12.5))
+	require.NoError(t, storage.UpdateCounter(ctx, "PollCount", 7))
+	require.NoError(t, NewPersister(storage, path, zap.NewNop()).Save(ctx))
+	savedAt, err := storage.GetUpdatedAt(ctx, models.Gauge, "Alloc")
+	require.NoError(t, err)
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "Временный файл снимка не должен оставаться на диске")
+
+	restored := repository.NewMemStorage()
+	require.NoError(t, NewPersister(restored, path, zap.NewNop()).Load(ctx))
+
+	gauge, err := restored.GetGauge(ctx, "Alloc")
+	require.NoError(t, err)
+	assert.Equal(t, 12.5, gauge)
+
+	counter, err := restored.GetCounter(ctx, "PollCount")
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), counter)
+
+	updatedAt, err := restored.GetUpdatedAt(ctx, models.Gauge, "Alloc")
+	require.NoError(t, err)
+	assert.True(t, savedAt.Equal(updatedAt), "После загрузки метрика должна сохранить время обновления, а не получить текущее")
+}
+
+func TestPersister_WAL(t *testing.T) {
+	tests := []struct {
+		name            string
+		snapshotBefore  bool
+		tornTail        bool
+		expectedCounter int64
+		expectedGauge   float64
+	}{
+		{name: "Восстановление только из журнала", expectedCounter: 5, expectedGauge: 2},
+		{name: "Снимок и журнал после него", snapshotBefore: true, expectedCounter: 5, expectedGauge: 2},
+		{name: "Оборванная последняя запись", tornTail: true, expectedCounter: 5, expectedGauge: 2},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			path := filepath.Join(t.TempDir(), "metrics.json")
+
+			storage := repository.NewMemStorage()
+			persister := NewPersister(storage, path, zap.NewNop())
+			ps := NewPersistentStorage(storage, persister, true)
+
+			require.NoError(t, ps.UpdateCounter(ctx, "Requests", 2))
+			if test.snapshotBefore {
+				require.NoError(t, persister.Save(ctx))
+			}
+			require.NoError(t, ps.UpdateCounter(ctx, "Requests", 3))
+			require.NoError(t, ps.UpdateGauge(ctx, "Load", 1))
+			_, err := ps.AddGauge(ctx, "Load", 0.5)
+			require.NoError(t, err)
+			_, err = ps.AddGauge(ctx, "Load", 0.5)
+			require.NoError(t, err)
+			require.NoError(t, ps.UpdateGauge(ctx, "Removed", 1))
+			require.NoError(t, ps.DeleteGauge(ctx, "Removed"))
+			require.NoError(t, persister.Close())
+			written := time.Now()
+
+			if test.tornTail {
+				f, err := os.OpenFile(path+".wal", os.O_WRONLY|os.O_APPEND, 0644)
+				require.NoError(t, err)
+				_, err = f.WriteString(`{"op":"update","id":"Requests","type":"coun`)
+				require.NoError(t, err)
+				require.NoError(t, f.Close())
+			}
+
+			restored := repository.NewMemStorage()
+			require.NoError(t, NewPersister(restored, path, zap.NewNop()).Load(ctx))
+
+			counter, err := restored.GetCounter(ctx, "Requests")
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedCounter, counter)
+
+			gauge, err := restored.GetGauge(ctx, "Load")
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedGauge, gauge)
+
+			_, err = restored.GetGauge(ctx, "Removed")
+			assert.ErrorIs(t, err, repository.ErrNotFound)
+
+			updatedAt, err := restored.GetUpdatedAt(ctx, models.Gauge, "Load")
+			require.NoError(t, err)
+			assert.False(t, updatedAt.After(written), "Время обновления должно браться из журнала, а не из момента загрузки")
+		})
+	}
+}
+
+func TestPersister_LoadInvalidSketch(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{name: "summary null", data: `{"gauges":{},"counters":{},"summaries":{"x":null}}`},
+		{name: "set null", data: `{"gauges":{},"counters":{},"sets":{"x":null}}`},
+		{name: "Регистры set не совпадают с точностью", data: `{"gauges":{},"counters":{},"sets":{"x":{"precision":14,"registers":"AAAA"}}}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "metrics.json")
+			require.NoError(t, os.WriteFile(path, []byte(test.data), 0o644))
+
+			err := NewPersister(repository.NewMemStorage(), path, zap.NewNop()).Load(context.Background())
+			assert.ErrorIs(t, err, sketch.ErrInvalidSketch, "Повреждённый скетч должен давать ошибку, а не панику")
+		})
+	}
+}
+
+func TestPersister_SaveTruncatesWAL(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "metrics.json")
+
+	storage := repository.NewMemStorage()
+	persister := NewPersister(storage, path, zap.NewNop())
+	ps := NewPersistentStorage(storage, persister, true)
+
+	require.NoError(t, ps.UpdateCounter(ctx, "Requests", 4))
+	require.NoError(t, persister.Save(ctx))
+	require.NoError(t, persister.Close())
+
+	info, err := os.Stat(path + ".wal")
+	require.NoError(t, err)
+	assert.Zero(t, info.Size(), "После снимка журнал должен быть пуст")
+
+	restored := repository.NewMemStorage()
+	require.NoError(t, NewPersister(restored, path, zap.NewNop()).Load(ctx))
+
+	counter, err := restored.GetCounter(ctx, "Requests")
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), counter, "Счётчик не должен удваиваться из-за журнала")
+}
+
+func TestPersister_LoadIdempotent(t *testing.T) {
+	tests := []struct {
+		name          string
+		mode          repository.RestoreMode
+		expectedLocal bool
+	}{
+		{name: "Замена содержимого", mode: repository.RestoreReplace, expectedLocal: false},
+		{name: "Слияние с содержимым", mode: repository.RestoreMerge, expectedLocal: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			path := filepath.Join(t.TempDir(), "metrics.json")
+
+			storage := repository.NewMemStorage()
+			persister := NewPersister(storage, path, zap.NewNop())
+			ps := NewPersistentStorage(storage, persister, true)
+			require.NoError(t, ps.UpdateCounter(ctx, "Requests", 5))
+			require.NoError(t, persister.Save(ctx))
+			require.NoError(t, ps.UpdateCounter(ctx, "Requests", 2))
+			require.NoError(t, persister.Close())
+
+			target := repository.NewMemStorage()
+			require.NoError(t, target.UpdateCounter(ctx, "Requests", 100))
+			require.NoError(t, target.UpdateGauge(ctx, "Local", 1))
+
+			loader := NewPersister(target, path, zap.NewNop())
+			require.NoError(t, loader.SetRestoreMode(test.mode))
+			require.NoError(t, loader.Load(ctx))
+			require.NoError(t, loader.Load(ctx))
+
+			counter, err := target.GetCounter(ctx, "Requests")
+			require.NoError(t, err)
+			assert.Equal(t, int64(7), counter, "Повторная загрузка не должна удваивать счётчик")
+
+			_, err = target.GetGauge(ctx, "Local")
+			assert.Equal(t, test.expectedLocal, err == nil)
+		})
+	}
+}
+
+func TestPersister_WALAppendError(t *testing.T) {
+	ctx := context.Background()
+	// Каталога нет, поэтому журнал не открыть.
+	path := filepath.Join(t.TempDir(), "missing", "metrics.json")
+
+	storage := repository.NewMemStorage()
+	persister := NewPersister(storage, path, zap.NewNop())
+	defer persister.Close()
+	ps := NewPersistentStorage(storage, persister, true)
+
+	err := ps.UpdateCounter(ctx, "Requests", 1)
+	assert.ErrorIs(t, err, ErrWALAppend, "Запись, не дошедшая до диска, не должна подтверждаться")
+}
@@ -0,0 +1,265 @@
+package persistence
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Guram-Gurych/metricserver.git/internal/model"
+	"github.com/Guram-Gurych/metricserver.git/internal/repository"
+	"go.uber.org/zap"
+	"os"
+	"time"
+)
+
+const (
+	walOpUpdate = "update"
+	walOpSet    = "set"
+	walOpDelete = "delete"
+	walOpReset  = "reset"
+	walOpAdd    = "add"
+
+	// После стольких записей журнал сворачивается в новый снимок.
+	walCompactThreshold = 10000
+	walMaxLineSize      = 4 << 20
+)
+
+// ErrWALAppend - операция применена, но не записана в журнал.
+var ErrWALAppend = errors.New("WAL append failed")
+
+// walRecord - одна операция над хранилищем между двумя снимками. В UpdatedAt Record
+// пишет время операции, чтобы после перезапуска метрика не получила время загрузки.
+type walRecord struct {
+	Op string `json:"op"`
+	models.Metrics
+}
+
+func (p *Persister) walPath() string {
+	return p.filePath + ".wal"
+}
+
+// Record применяет операцию к хранилищу и дописывает её в журнал.
+// Возврат происходит после fsync пачки с этой записью; блокировка на чтение держится
+// до этого момента, иначе Save мог бы очистить журнал раньше, чем запись в него попадёт.
+// Ошибка записи журнала возвращается вызывающему: операция уже применена в памяти,
+// но до диска не дошла, и клиент не должен получить подтверждение сохранности.
+func (p *Persister) Record(ctx context.Context, rec walRecord, apply func() error) error {
+	if p.filePath == "" {
+		return apply()
+	}
+
+	p.mu.RLock()
+	err := apply()
+	if err == nil {
+		now := time.Now()
+		rec.UpdatedAt = &now
+		if walErr := p.appendWAL(rec); walErr != nil {
+			p.logger.Error("WAL append failed", zap.Error(walErr))
+			err = fmt.Errorf("%w: %w", ErrWALAppend, walErr)
+		}
+	}
+	p.mu.RUnlock()
+
+	if err != nil {
+		return err
+	}
+
+	if p.needsCompaction() {
+		if saveErr := p.Save(context.WithoutCancel(ctx)); saveErr != nil {
+			p.logger.Error("WAL compaction failed", zap.Error(saveErr))
+		}
+	}
+
+	return nil
+}
+
+func (p *Persister) appendWAL(rec walRecord) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	p.commitOnce.Do(func() {
+		p.commits = newGroupCommitter(p.syncWindow, p.writeWAL)
+	})
+
+	return p.commits.commit(line)
+}
+
+func (p *Persister) writeWAL(batch []byte, records int) error {
+	p.walMu.Lock()
+	defer p.walMu.Unlock()
+
+	var err error
+	if p.wal == nil {
+		p.wal, err = os.OpenFile(p.walPath(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return err
+		}
+	}
+
+	if _, err = p.wal.Write(batch); err != nil {
+		return err
+	}
+	if err = p.wal.Sync(); err != nil {
+		return err
+	}
+
+	p.walRecords += records
+	return nil
+}
+
+func (p *Persister) needsCompaction() bool {
+	p.walMu.Lock()
+	defer p.walMu.Unlock()
+
+	return p.walRecords >= walCompactThreshold
+}
+
+// truncateWAL вызывается из Save под p.mu: всё, что было в журнале, уже попало в снимок.
+func (p *Persister) truncateWAL() error {
+	p.walMu.Lock()
+	defer p.walMu.Unlock()
+
+	p.walRecords = 0
+
+	if p.wal == nil {
+		err := os.Truncate(p.walPath(), 0)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	if err := p.wal.Truncate(0); err != nil {
+		return err
+	}
+	return p.wal.Sync()
+}
+
+// replayWAL применяет журнал к repo и возвращает время последней операции над каждой
+// оставшейся метрикой; у записей старого формата без времени его нет.
+func (p *Persister) replayWAL(ctx context.Context, repo repository.MetricRepository) (map[string]map[string]time.Time, error) {
+	updatedAt := make(map[string]map[string]time.Time)
+	f, err := os.Open(p.walPath())
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return updatedAt, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), walMaxLineSize)
+
+	replayed := 0
+	for scanner.Scan() {
+		var rec walRecord
+		if err = json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			// Оборванная последняя строка - след падения посреди записи, её можно отбросить.
+			if !scanner.Scan() {
+				p.logger.Warn("Discarding torn WAL tail", zap.Error(err))
+				break
+			}
+			return nil, fmt.Errorf("corrupted WAL record %d: %w", replayed+1, err)
+		}
+
+		if err = applyRecord(ctx, repo, rec); err != nil {
+			return nil, err
+		}
+		if rec.Op == walOpDelete {
+			delete(updatedAt[rec.MType], rec.ID)
+		} else if rec.UpdatedAt != nil {
+			if updatedAt[rec.MType] == nil {
+				updatedAt[rec.MType] = make(map[string]time.Time)
+			}
+			updatedAt[rec.MType][rec.ID] = *rec.UpdatedAt
+		}
+		replayed++
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	p.walMu.Lock()
+	p.walRecords = replayed
+	p.walMu.Unlock()
+
+	if replayed > 0 {
+		p.logger.Info("WAL replayed", zap.Int("records", replayed))
+	}
+	return updatedAt, nil
+}
+
+func applyRecord(ctx context.Context, repo repository.MetricRepository, rec walRecord) error {
+	var err error
+	switch rec.Op {
+	case walOpUpdate:
+		switch rec.MType {
+		case models.Gauge:
+			if rec.Value != nil {
+				err = repo.UpdateGauge(ctx, rec.ID, *rec.Value)
+			}
+		case models.Counter:
+			if rec.Delta != nil {
+				err = repo.UpdateCounter(ctx, rec.ID, *rec.Delta)
+			}
+		case models.Summary:
+			if rec.Sketch != nil {
+				err = repo.UpdateSummary(ctx, rec.ID, rec.Sketch)
+			}
+		case models.Set:
+			if rec.HLL != nil {
+				err = repo.UpdateSet(ctx, rec.ID, rec.HLL)
+			}
+		}
+	case walOpAdd:
+		if rec.MType == models.Gauge && rec.Value != nil {
+			_, err = repo.AddGauge(ctx, rec.ID, *rec.Value)
+		}
+	case walOpSet:
+		if rec.MType == models.Counter && rec.Delta != nil {
+			err = repo.SetCounter(ctx, rec.ID, *rec.Delta)
+		}
+	case walOpDelete:
+		switch rec.MType {
+		case models.Gauge:
+			err = repo.DeleteGauge(ctx, rec.ID)
+		case models.Counter:
+			err = repo.DeleteCounter(ctx, rec.ID)
+		case models.Summary:
+			err = repo.DeleteSummary(ctx, rec.ID)
+		case models.Set:
+			err = repo.DeleteSet(ctx, rec.ID)
+		}
+	case walOpReset:
+		err = repo.ResetCounter(ctx, rec.ID)
+	default:
+		return fmt.Errorf("unknown WAL operation %q", rec.Op)
+	}
+
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+func (p *Persister) Close() error {
+	if p.commits != nil {
+		p.commits.stop()
+	}
+
+	p.walMu.Lock()
+	defer p.walMu.Unlock()
+
+	if p.wal == nil {
+		return nil
+	}
+
+	err := p.wal.Close()
+	p.wal = nil
+	return err
+}
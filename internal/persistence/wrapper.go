@@ -1,8 +1,12 @@
 package persistence
 
 import (
+	"context"
+	"github.com/Guram-Gurych/metricserver.git/internal/model"
 	"github.com/Guram-Gurych/metricserver.git/internal/repository"
+	"github.com/Guram-Gurych/metricserver.git/internal/sketch"
 	"go.uber.org/zap"
+	"time"
 )
 
 type PersistentStorage struct {
@@ -15,48 +19,167 @@ func NewPersistentStorage(repo repository.MetricRepository, persister *Persister
 	return &PersistentStorage{repo: repo, persister: persister, isSync: storeInterval}
 }
 
-func (ps *PersistentStorage) UpdateGauge(name string, value float64) error {
-	err := ps.repo.UpdateGauge(name, value)
-	if err != nil {
-		return err
+func (ps *PersistentStorage) syncSave(ctx context.Context) {
+	if !ps.isSync {
+		return
+	}
+
+	// Обновление уже применено, поэтому отмена запроса клиентом не должна прерывать сохранение.
+	if saveErr := ps.persister.Save(context.WithoutCancel(ctx)); saveErr != nil {
+		ps.persister.logger.Error("Sync save failed", zap.Error(saveErr))
 	}
+}
 
-	if ps.isSync {
-		if saveErr := ps.persister.Save(); saveErr != nil {
-			ps.persister.logger.Error("Sync save failed", zap.Error(saveErr))
-		}
+// record в синхронном режиме пишет операцию в журнал вместо полной перезаписи файла.
+func (ps *PersistentStorage) record(ctx context.Context, rec walRecord, apply func() error) error {
+	if !ps.isSync {
+		return apply()
 	}
 
-	return err
+	return ps.persister.Record(ctx, rec, apply)
 }
 
-func (ps *PersistentStorage) UpdateCounter(name string, value int64) error {
-	err := ps.repo.UpdateCounter(name, value)
-	if err != nil {
+func (ps *PersistentStorage) UpdateGauge(ctx context.Context, name string, value float64) error {
+	rec := walRecord{Op: walOpUpdate, Metrics: models.Metrics{ID: name, MType: models.Gauge, Value: &value}}
+	return ps.record(ctx, rec, func() error {
+		return ps.repo.UpdateGauge(ctx, name, value)
+	})
+}
+
+func (ps *PersistentStorage) AddGauge(ctx context.Context, name string, delta float64) (float64, error) {
+	var value float64
+	rec := walRecord{Op: walOpAdd, Metrics: models.Metrics{ID: name, MType: models.Gauge, Value: &delta}}
+	err := ps.record(ctx, rec, func() error {
+		var err error
+		value, err = ps.repo.AddGauge(ctx, name, delta)
 		return err
+	})
+
+	return value, err
+}
+
+func (ps *PersistentStorage) UpdateCounter(ctx context.Context, name string, value int64) error {
+	rec := walRecord{Op: walOpUpdate, Metrics: models.Metrics{ID: name, MType: models.Counter, Delta: &value}}
+	return ps.record(ctx, rec, func() error {
+		return ps.repo.UpdateCounter(ctx, name, value)
+	})
+}
+
+func (ps *PersistentStorage) SetCounter(ctx context.Context, name string, value int64) error {
+	rec := walRecord{Op: walOpSet, Metrics: models.Metrics{ID: name, MType: models.Counter, Delta: &value}}
+	return ps.record(ctx, rec, func() error {
+		return ps.repo.SetCounter(ctx, name, value)
+	})
+}
+
+func (ps *PersistentStorage) UpdateSummary(ctx context.Context, name string, value *sketch.DDSketch) error {
+	rec := walRecord{Op: walOpUpdate, Metrics: models.Metrics{ID: name, MType: models.Summary, Sketch: value}}
+	return ps.record(ctx, rec, func() error {
+		return ps.repo.UpdateSummary(ctx, name, value)
+	})
+}
+
+func (ps *PersistentStorage) UpdateSet(ctx context.Context, name string, value *sketch.HyperLogLog) error {
+	rec := walRecord{Op: walOpUpdate, Metrics: models.Metrics{ID: name, MType: models.Set, HLL: value}}
+	return ps.record(ctx, rec, func() error {
+		return ps.repo.UpdateSet(ctx, name, value)
+	})
+}
+
+func (ps *PersistentStorage) DeleteGauge(ctx context.Context, name string) error {
+	rec := walRecord{Op: walOpDelete, Metrics: models.Metrics{ID: name, MType: models.Gauge}}
+	return ps.record(ctx, rec, func() error {
+		return ps.repo.DeleteGauge(ctx, name)
+	})
+}
+
+func (ps *PersistentStorage) DeleteCounter(ctx context.Context, name string) error {
+	rec := walRecord{Op: walOpDelete, Metrics: models.Metrics{ID: name, MType: models.Counter}}
+	return ps.record(ctx, rec, func() error {
+		return ps.repo.DeleteCounter(ctx, name)
+	})
+}
+
+func (ps *PersistentStorage) ResetCounter(ctx context.Context, name string) error {
+	rec := walRecord{Op: walOpReset, Metrics: models.Metrics{ID: name, MType: models.Counter}}
+	return ps.record(ctx, rec, func() error {
+		return ps.repo.ResetCounter(ctx, name)
+	})
+}
+
+func (ps *PersistentStorage) DeleteSummary(ctx context.Context, name string) error {
+	rec := walRecord{Op: walOpDelete, Metrics: models.Metrics{ID: name, MType: models.Summary}}
+	return ps.record(ctx, rec, func() error {
+		return ps.repo.DeleteSummary(ctx, name)
+	})
+}
+
+func (ps *PersistentStorage) DeleteSet(ctx context.Context, name string) error {
+	rec := walRecord{Op: walOpDelete, Metrics: models.Metrics{ID: name, MType: models.Set}}
+	return ps.record(ctx, rec, func() error {
+		return ps.repo.DeleteSet(ctx, name)
+	})
+}
+
+// EvictStale удаляет сразу много метрик, поэтому вместо записей в журнал делает снимок.
+func (ps *PersistentStorage) EvictStale(ctx context.Context, before time.Time) (int, error) {
+	evicted, err := ps.repo.EvictStale(ctx, before)
+	if err != nil {
+		return evicted, err
 	}
 
-	if ps.isSync {
-		if saveErr := ps.persister.Save(); saveErr != nil {
-			ps.persister.logger.Error("Sync save failed", zap.Error(saveErr))
-		}
+	if evicted > 0 {
+		ps.syncSave(ctx)
 	}
+	return evicted, nil
+}
+
+func (ps *PersistentStorage) Restore(ctx context.Context, snapshot repository.Snapshot, mode repository.RestoreMode) error {
+	err := ps.repo.Restore(ctx, snapshot, mode)
+	if err != nil {
+		return err
+	}
+
+	ps.syncSave(ctx)
+	return nil
+}
+
+func (ps *PersistentStorage) GetGauge(ctx context.Context, name string) (float64, error) {
+	return ps.repo.GetGauge(ctx, name)
+}
+
+func (ps *PersistentStorage) GetCounter(ctx context.Context, name string) (int64, error) {
+	return ps.repo.GetCounter(ctx, name)
+}
+
+func (ps *PersistentStorage) GetAllGauges(ctx context.Context) (map[string]float64, error) {
+	return ps.repo.GetAllGauges(ctx)
+}
+
+func (ps *PersistentStorage) List(ctx context.Context, opts repository.ListOptions) (*repository.ListPage, error) {
+	return ps.repo.List(ctx, opts)
+}
+
+func (ps *PersistentStorage) GetAllCounters(ctx context.Context) (map[string]int64, error) {
+	return ps.repo.GetAllCounters(ctx)
+}
 
-	return err
+func (ps *PersistentStorage) GetSummary(ctx context.Context, name string) (*sketch.DDSketch, error) {
+	return ps.repo.GetSummary(ctx, name)
 }
 
-func (ps *PersistentStorage) GetGauge(name string) (float64, bool) {
-	return ps.repo.GetGauge(name)
+func (ps *PersistentStorage) GetAllSummaries(ctx context.Context) (map[string]*sketch.DDSketch, error) {
+	return ps.repo.GetAllSummaries(ctx)
 }
 
-func (ps *PersistentStorage) GetCounter(name string) (int64, bool) {
-	return ps.repo.GetCounter(name)
+func (ps *PersistentStorage) GetSet(ctx context.Context, name string) (*sketch.HyperLogLog, error) {
+	return ps.repo.GetSet(ctx, name)
 }
 
-func (ps *PersistentStorage) GetAllGauges() map[string]float64 {
-	return ps.repo.GetAllGauges()
+func (ps *PersistentStorage) GetAllSets(ctx context.Context) (map[string]*sketch.HyperLogLog, error) {
+	return ps.repo.GetAllSets(ctx)
 }
 
-func (ps *PersistentStorage) GetAllCounters() map[string]int64 {
-	return ps.repo.GetAllCounters()
+func (ps *PersistentStorage) GetUpdatedAt(ctx context.Context, metricType, name string) (time.Time, error) {
+	return ps.repo.GetUpdatedAt(ctx, metricType, name)
 }
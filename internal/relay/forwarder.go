@@ -0,0 +1,215 @@
+package relay
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Guram-Gurych/metricserver.git/internal/middleware"
+	"github.com/Guram-Gurych/metricserver.git/internal/model"
+	"go.uber.org/zap"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// BatchIDHeader совпадает с заголовком, по которому upstream отбрасывает повторы.
+	BatchIDHeader = "X-Batch-ID"
+
+	minBackoff  = time.Second
+	maxBackoff  = time.Minute
+	sendTimeout = 30 * time.Second
+)
+
+// errRejected - upstream отверг пакет как некорректный; повтор не поможет.
+var errRejected = errors.New("batch rejected by upstream")
+
+// Forwarder раз в окно переносит накопленное в outbox и отправляет пакеты upstream
+// на /updates/ по порядку. Пока upstream недоступен, пакеты ждут в outbox, а попытки
+// повторяются с экспоненциальной задержкой.
+type Forwarder struct {
+	window *Window
+	outbox *Outbox
+	client *http.Client
+	url    string
+	token  string
+	key    string
+	logger *zap.Logger
+
+	notify chan struct{}
+}
+
+func NewForwarder(upstream string, window *Window, outbox *Outbox, logger *zap.Logger) *Forwarder {
+	if !strings.Contains(upstream, "://") {
+		upstream = "http://" + upstream
+	}
+
+	return &Forwarder{
+		window: window,
+		outbox: outbox,
+		client: &http.Client{Timeout: sendTimeout},
+		url:    strings.TrimSuffix(upstream, "/") + "/updates/",
+		logger: logger,
+		notify: make(chan struct{}, 1),
+	}
+}
+
+// SetToken задаёт токен записи upstream.
+func (f *Forwarder) SetToken(token string) {
+	f.token = token
+}
+
+// SetSigningKey включает подпись пакетов HMAC-SHA256 ключом upstream (-k).
+func (f *Forwarder) SetSigningKey(key string) {
+	f.key = key
+}
+
+// Run закрывает окно каждые interval и отправляет пакеты, пока ctx не отменят.
+// При остановке текущее окно сохраняется в outbox, чтобы не потерять его.
+func (f *Forwarder) Run(ctx context.Context, interval time.Duration) {
+	go f.sendLoop(ctx)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if err := f.Seal(); err != nil {
+				f.logger.Error("Failed to save the relay window", zap.Error(err))
+			}
+			return
+		case <-ticker.C:
+			if err := f.Seal(); err != nil {
+				f.logger.Error("Failed to save the relay window", zap.Error(err))
+			}
+		}
+	}
+}
+
+// Seal переносит текущее окно в outbox.
+func (f *Forwarder) Seal() error {
+	batch := f.window.Drain()
+	if len(batch) == 0 {
+		return nil
+	}
+
+	dropped, err := f.outbox.Push(batch)
+	if dropped > 0 {
+		f.logger.Warn("Relay outbox is full, oldest batches dropped", zap.Int("dropped", dropped))
+	}
+	if err != nil {
+		return err
+	}
+
+	select {
+	case f.notify <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+func (f *Forwarder) sendLoop(ctx context.Context) {
+	backoff := minBackoff
+	for {
+		wait := (<-chan time.Time)(nil)
+		if err := f.Flush(ctx); err != nil {
+			f.logger.Warn("Failed to forward metrics upstream", zap.Int("pending", f.outbox.Len()), zap.Duration("retry_in", backoff), zap.Error(err))
+			wait = time.After(backoff)
+			backoff = min(backoff*2, maxBackoff)
+		} else {
+			backoff = minBackoff
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-f.notify:
+		case <-wait:
+		}
+	}
+}
+
+// Flush отправляет пакеты из outbox, пока они не кончатся или upstream не ответит ошибкой.
+func (f *Forwarder) Flush(ctx context.Context) error {
+	for {
+		id, batch, err := f.outbox.Peek()
+		if errors.Is(err, ErrEmptyOutbox) {
+			return nil
+		}
+		if err != nil && id == "" {
+			return err
+		}
+
+		if err == nil {
+			err = f.send(ctx, f.outbox.BatchID(id), batch)
+		}
+		if errors.Is(err, errRejected) || (err != nil && batch == nil) {
+			// Битый или отвергнутый пакет не должен навсегда остановить очередь.
+			path, rejectErr := f.outbox.Reject(id)
+			if rejectErr != nil {
+				return rejectErr
+			}
+			f.logger.Error("Relay batch rejected, moved aside", zap.String("batch", id), zap.String("path", path), zap.Error(err))
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := f.outbox.Remove(id); err != nil {
+			return err
+		}
+	}
+}
+
+func (f *Forwarder) send(ctx context.Context, batchID string, batch []models.Metrics) error {
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(body); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, f.url, &buf)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Encoding", "gzip")
+	req.Header.Set(BatchIDHeader, batchID)
+	if f.token != "" {
+		req.Header.Set("Authorization", "Bearer "+f.token)
+	}
+	if f.key != "" {
+		// Upstream проверяет подпись несжатого тела.
+		req.Header.Set(middleware.SignatureHeader, hex.EncodeToString(middleware.Sign([]byte(f.key), body)))
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		return nil
+	case resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusRequestEntityTooLarge:
+		return fmt.Errorf("%w: %s", errRejected, resp.Status)
+	}
+
+	return fmt.Errorf("upstream responded %s", resp.Status)
+}
@@ -0,0 +1,162 @@
+package relay
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"github.com/Guram-Gurych/metricserver.git/internal/middleware"
+	"github.com/Guram-Gurych/metricserver.git/internal/model"
+	"github.com/Guram-Gurych/metricserver.git/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeUpstream struct {
+	mu       sync.Mutex
+	status   int
+	batchIDs []string
+	counters map[string]int64
+}
+
+func (u *fakeUpstream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if r.URL.Path != "/updates/" || r.Header.Get("Authorization") != "Bearer secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if u.status != http.StatusOK {
+		w.WriteHeader(u.status)
+		return
+	}
+
+	zr, err := gzip.NewReader(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var batch []models.Metrics
+	if err := json.NewDecoder(zr).Decode(&batch); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	u.batchIDs = append(u.batchIDs, r.Header.Get(BatchIDHeader))
+	for _, m := range batch {
+		if m.MType == models.Counter {
+			u.counters[m.ID] += *m.Delta
+		}
+	}
+}
+
+func TestForwarder_Flush(t *testing.T) {
+	upstream := &fakeUpstream{status: http.StatusServiceUnavailable, counters: make(map[string]int64)}
+	srv := httptest.NewServer(upstream)
+	defer srv.Close()
+
+	outbox, err := OpenOutbox(t.TempDir(), 0)
+	require.NoError(t, err)
+	window := NewWindow()
+	f := NewForwarder(srv.URL, window, outbox, zap.NewNop())
+	f.SetToken("secret")
+
+	repo := NewStorage(repository.NewMemStorage(), window)
+	ctx := t.Context()
+	require.NoError(t, repo.UpdateCounter(ctx, "requests", 2))
+	require.NoError(t, repo.SetCounter(ctx, "requests", 10))
+	require.NoError(t, repo.UpdateGauge(ctx, "load", 0.5))
+	require.NoError(t, f.Seal())
+
+	require.Error(t, f.Flush(ctx), "Ошибка upstream должна возвращаться для повтора")
+	assert.Equal(t, 1, outbox.Len(), "Неотправленный пакет должен остаться в outbox")
+
+	require.NoError(t, repo.UpdateCounter(ctx, "requests", 1))
+	require.NoError(t, f.Seal())
+
+	upstream.mu.Lock()
+	upstream.status = http.StatusOK
+	upstream.mu.Unlock()
+
+	require.NoError(t, f.Flush(ctx))
+	assert.Equal(t, 0, outbox.Len())
+	assert.Equal(t, int64(11), upstream.counters["requests"], "SetCounter должен уходить upstream как прирост")
+	require.Len(t, upstream.batchIDs, 2)
+	assert.NotEqual(t, upstream.batchIDs[0], upstream.batchIDs[1], "У каждого пакета свой X-Batch-ID")
+}
+
+func TestForwarder_FlushKeepsRejected(t *testing.T) {
+	upstream := &fakeUpstream{status: http.StatusBadRequest, counters: make(map[string]int64)}
+	srv := httptest.NewServer(upstream)
+	defer srv.Close()
+
+	dir := t.TempDir()
+	outbox, err := OpenOutbox(dir, 0)
+	require.NoError(t, err)
+	f := NewForwarder(srv.URL, NewWindow(), outbox, zap.NewNop())
+	f.SetToken("secret")
+
+	_, err = outbox.Push(gaugeBatch("load", 1))
+	require.NoError(t, err)
+
+	require.NoError(t, f.Flush(t.Context()))
+	assert.Equal(t, 0, outbox.Len(), "Отвергнутый upstream пакет не должен блокировать очередь")
+
+	rejected, err := os.ReadDir(filepath.Join(dir, rejectedDir))
+	require.NoError(t, err)
+	assert.Len(t, rejected, 1, "Отвергнутый пакет должен остаться на диске")
+}
+
+func TestForwarder_FlushSigned(t *testing.T) {
+	accepted := 0
+	srv := httptest.NewServer(middleware.GzipMiddleware(middleware.VerifySignature("key", 1<<20)(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			accepted++
+		}))))
+	defer srv.Close()
+
+	outbox, err := OpenOutbox(t.TempDir(), 0)
+	require.NoError(t, err)
+	f := NewForwarder(srv.URL, NewWindow(), outbox, zap.NewNop())
+	f.SetSigningKey("key")
+
+	_, err = outbox.Push(gaugeBatch("load", 1))
+	require.NoError(t, err)
+
+	require.NoError(t, f.Flush(t.Context()))
+	assert.Equal(t, 1, accepted, "Upstream с ключом должен принять подписанный пакет")
+	assert.Equal(t, 0, outbox.Len())
+}
+
+func TestForwarder_RunSealsOnStop(t *testing.T) {
+	upstream := &fakeUpstream{status: http.StatusServiceUnavailable, counters: make(map[string]int64)}
+	srv := httptest.NewServer(upstream)
+	defer srv.Close()
+
+	outbox, err := OpenOutbox(t.TempDir(), 0)
+	require.NoError(t, err)
+	window := NewWindow()
+	f := NewForwarder(srv.URL, window, outbox, zap.NewNop())
+
+	repo := NewStorage(repository.NewMemStorage(), window)
+	require.NoError(t, repo.UpdateCounter(t.Context(), "requests", 2))
+
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f.Run(ctx, time.Hour)
+	}()
+	cancel()
+	<-done
+
+	assert.Equal(t, 1, outbox.Len(), "Окно должно сохраняться в outbox при остановке")
+}
@@ -0,0 +1,215 @@
+package relay
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Guram-Gurych/metricserver.git/internal/model"
+	"github.com/Guram-Gurych/metricserver.git/internal/persistence"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	batchExt    = ".json"
+	sourceFile  = "source"
+	rejectedDir = "rejected"
+
+	// DefaultMaxBatches ограничивает outbox при долгой недоступности upstream.
+	DefaultMaxBatches = 10000
+)
+
+var ErrEmptyOutbox = errors.New("outbox is empty")
+
+// Outbox хранит неотправленные пакеты на диске, по файлу на пакет, чтобы они пережили
+// перезапуск relay. Имя файла - время создания в наносекундах; вместе со случайным
+// идентификатором outbox оно даёт X-Batch-ID, так что повтор пакета даже после
+// перезапуска upstream распознает как дубликат. При переполнении удаляются самые старые пакеты.
+type Outbox struct {
+	dir        string
+	maxBatches int
+	source     string
+
+	mu  sync.Mutex
+	ids []string
+	// last не даёт двум пакетам получить одинаковый идентификатор.
+	last int64
+}
+
+func OpenOutbox(dir string, maxBatches int) (*Outbox, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	source, err := loadSource(filepath.Join(dir, sourceFile))
+	if err != nil {
+		return nil, err
+	}
+
+	o := &Outbox{dir: dir, maxBatches: maxBatches, source: source}
+	for _, e := range entries {
+		id, ok := strings.CutSuffix(e.Name(), batchExt)
+		if !ok || e.IsDir() {
+			continue
+		}
+		n, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			continue
+		}
+		o.ids = append(o.ids, id)
+		o.last = max(o.last, n)
+	}
+	sort.Slice(o.ids, func(i, j int) bool { return idLess(o.ids[i], o.ids[j]) })
+
+	return o, nil
+}
+
+// Push сохраняет пакет на диск с fsync файла и каталога и возвращает число пакетов, вытесненных из-за переполнения.
+func (o *Outbox) Push(batch []models.Metrics) (int, error) {
+	data, err := json.Marshal(batch)
+	if err != nil {
+		return 0, err
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.last = max(o.last+1, time.Now().UnixNano())
+	id := strconv.FormatInt(o.last, 10)
+
+	if err := persistence.WriteFileAtomic(o.path(id), data, 0o644); err != nil {
+		return 0, err
+	}
+	o.ids = append(o.ids, id)
+
+	dropped := 0
+	for o.maxBatches > 0 && len(o.ids) > o.maxBatches {
+		if err := os.Remove(o.path(o.ids[0])); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return dropped, err
+		}
+		o.ids = o.ids[1:]
+		dropped++
+	}
+
+	return dropped, nil
+}
+
+// Peek возвращает самый старый пакет, не удаляя его.
+func (o *Outbox) Peek() (string, []models.Metrics, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if len(o.ids) == 0 {
+		return "", nil, ErrEmptyOutbox
+	}
+
+	id := o.ids[0]
+	data, err := os.ReadFile(o.path(id))
+	if err != nil {
+		return "", nil, err
+	}
+
+	var batch []models.Metrics
+	if err := json.Unmarshal(data, &batch); err != nil {
+		return id, nil, fmt.Errorf("outbox batch %s: %w", id, err)
+	}
+
+	return id, batch, nil
+}
+
+// Remove удаляет отправленный пакет.
+func (o *Outbox) Remove(id string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if err := os.Remove(o.path(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	for i, existing := range o.ids {
+		if existing == id {
+			o.ids = append(o.ids[:i], o.ids[i+1:]...)
+			break
+		}
+	}
+
+	return nil
+}
+
+// Reject переносит пакет, который upstream отверг или который не читается, в подкаталог
+// rejected: очередь идёт дальше, а пакет остаётся на диске для разбора. Возвращает новый путь.
+func (o *Outbox) Reject(id string) (string, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	dir := filepath.Join(o.dir, rejectedDir)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	target := filepath.Join(dir, id+batchExt)
+	if err := os.Rename(o.path(id), target); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+	for i, existing := range o.ids {
+		if existing == id {
+			o.ids = append(o.ids[:i], o.ids[i+1:]...)
+			break
+		}
+	}
+
+	return target, nil
+}
+
+// BatchID - идентификатор пакета для upstream, уникальный между разными relay.
+func (o *Outbox) BatchID(id string) string {
+	return o.source + "-" + id
+}
+
+func (o *Outbox) Len() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return len(o.ids)
+}
+
+func (o *Outbox) path(id string) string {
+	return filepath.Join(o.dir, id+batchExt)
+}
+
+// loadSource читает идентификатор outbox или создаёт новый при первом запуске.
+func loadSource(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err == nil && len(bytes.TrimSpace(data)) > 0 {
+		return string(bytes.TrimSpace(data)), nil
+	}
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	source := hex.EncodeToString(buf)
+
+	return source, persistence.WriteFileAtomic(path, []byte(source), 0o644)
+}
+
+func idLess(a, b string) bool {
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+	return a < b
+}
@@ -0,0 +1,73 @@
+package relay
+
+import (
+	"github.com/Guram-Gurych/metricserver.git/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func gaugeBatch(name string, value float64) []models.Metrics {
+	return []models.Metrics{{ID: name, MType: models.Gauge, Value: &value}}
+}
+
+func TestOutbox_Order(t *testing.T) {
+	dir := t.TempDir()
+	o, err := OpenOutbox(dir, 0)
+	require.NoError(t, err)
+
+	_, _, err = o.Peek()
+	assert.ErrorIs(t, err, ErrEmptyOutbox)
+
+	for i, name := range []string{"first", "second"} {
+		_, err := o.Push(gaugeBatch(name, float64(i)))
+		require.NoError(t, err)
+	}
+
+	reopened, err := OpenOutbox(dir, 0)
+	require.NoError(t, err)
+	require.Equal(t, 2, reopened.Len(), "Пакеты должны пережить перезапуск")
+	assert.Equal(t, o.BatchID("1"), reopened.BatchID("1"), "Идентификатор outbox должен сохраняться")
+
+	id, batch, err := reopened.Peek()
+	require.NoError(t, err)
+	assert.Equal(t, "first", batch[0].ID, "Первым отправляется самый старый пакет")
+
+	require.NoError(t, reopened.Remove(id))
+	_, batch, err = reopened.Peek()
+	require.NoError(t, err)
+	assert.Equal(t, "second", batch[0].ID)
+}
+
+func TestOutbox_Overflow(t *testing.T) {
+	o, err := OpenOutbox(t.TempDir(), 2)
+	require.NoError(t, err)
+
+	dropped := 0
+	for i, name := range []string{"a", "b", "c"} {
+		n, err := o.Push(gaugeBatch(name, float64(i)))
+		require.NoError(t, err)
+		dropped += n
+	}
+
+	assert.Equal(t, 1, dropped)
+	assert.Equal(t, 2, o.Len())
+	_, batch, err := o.Peek()
+	require.NoError(t, err)
+	assert.Equal(t, "b", batch[0].ID, "При переполнении вытесняется самый старый пакет")
+}
+
+func TestOutbox_Corrupt(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "1"+batchExt), []byte("{"), 0o644))
+
+	o, err := OpenOutbox(dir, 0)
+	require.NoError(t, err)
+
+	id, batch, err := o.Peek()
+	assert.Error(t, err)
+	assert.Equal(t, "1", id, "Для битого пакета нужен идентификатор, чтобы его можно было удалить")
+	assert.Nil(t, batch)
+}
@@ -0,0 +1,203 @@
+package relay
+
+import (
+	"context"
+	"errors"
+	"github.com/Guram-Gurych/metricserver.git/internal/repository"
+	"github.com/Guram-Gurych/metricserver.git/internal/sketch"
+	"sync"
+	"time"
+)
+
+// Storage пишет в локальное хранилище и копит те же обновления в окне для upstream.
+// Удаления, сброс counter-ов и восстановление из файла остаются локальными.
+type Storage struct {
+	repo   repository.MetricRepository
+	window *Window
+
+	// sketchMu делает проверку окна, запись в хранилище и слияние в окно одним шагом,
+	// чтобы скетч не оказался в хранилище без окна или наоборот.
+	sketchMu sync.Mutex
+
+	// counterLocks делают вычисление прироста SetCounter и запись одним шагом для имени;
+	// разные имена попадают на разные блокировки и не ждут друг друга.
+	counterLocks [counterStripes]sync.Mutex
+	// forwarded - последнее значение SetCounter, от которого считается прирост для upstream.
+	// Оно не зависит от локального хранилища: после сброса, удаления или вытеснения
+	// counter-а upstream не должен получить всё значение повторно.
+	forwardedMu sync.Mutex
+	forwarded   map[string]int64
+}
+
+const counterStripes = 64
+
+func NewStorage(repo repository.MetricRepository, window *Window) *Storage {
+	return &Storage{repo: repo, window: window, forwarded: make(map[string]int64)}
+}
+
+func (s *Storage) UpdateGauge(ctx context.Context, name string, value float64) error {
+	if err := s.repo.UpdateGauge(ctx, name, value); err != nil {
+		return err
+	}
+	s.window.AddGauge(name, value)
+	return nil
+}
+
+func (s *Storage) AddGauge(ctx context.Context, name string, delta float64) (float64, error) {
+	value, err := s.repo.AddGauge(ctx, name, delta)
+	if err != nil {
+		return value, err
+	}
+	s.window.AddGauge(name, value)
+	return value, nil
+}
+
+func (s *Storage) UpdateCounter(ctx context.Context, name string, value int64) error {
+	if err := s.repo.UpdateCounter(ctx, name, value); err != nil {
+		return err
+	}
+	s.window.AddCounter(name, value)
+	return nil
+}
+
+// SetCounter upstream получает как приращение: разницу с прошлым значением, переданным
+// в SetCounter. Для первого значения после запуска база - локальное значение, которое
+// восстановлено из файла. Если значение уменьшилось, источник перезапущен, и прирост -
+// всё новое значение.
+func (s *Storage) SetCounter(ctx context.Context, name string, value int64) error {
+	mu := &s.counterLocks[stripe(name)]
+	mu.Lock()
+	defer mu.Unlock()
+
+	s.forwardedMu.Lock()
+	prev, ok := s.forwarded[name]
+	s.forwardedMu.Unlock()
+	if !ok {
+		local, err := s.repo.GetCounter(ctx, name)
+		if err != nil && !errors.Is(err, repository.ErrNotFound) {
+			return err
+		}
+		prev = local
+	}
+
+	if err := s.repo.SetCounter(ctx, name, value); err != nil {
+		return err
+	}
+
+	s.forwardedMu.Lock()
+	s.forwarded[name] = value
+	s.forwardedMu.Unlock()
+
+	delta := value - prev
+	if value < prev {
+		delta = value
+	}
+	s.window.AddCounter(name, delta)
+	return nil
+}
+
+func stripe(name string) int {
+	h := uint32(2166136261)
+	for i := 0; i < len(name); i++ {
+		h ^= uint32(name[i])
+		h *= 16777619
+	}
+
+	return int(h % counterStripes)
+}
+
+// UpdateSummary проверяет совместимость с окном до записи: скетч, принятый хранилищем,
+// должен уйти и upstream.
+func (s *Storage) UpdateSummary(ctx context.Context, name string, value *sketch.DDSketch) error {
+	s.sketchMu.Lock()
+	defer s.sketchMu.Unlock()
+
+	if err := s.window.checkSummary(name, value); err != nil {
+		return err
+	}
+	if err := s.repo.UpdateSummary(ctx, name, value); err != nil {
+		return err
+	}
+	return s.window.AddSummary(name, value)
+}
+
+func (s *Storage) UpdateSet(ctx context.Context, name string, value *sketch.HyperLogLog) error {
+	s.sketchMu.Lock()
+	defer s.sketchMu.Unlock()
+
+	if err := s.window.checkSet(name, value); err != nil {
+		return err
+	}
+	if err := s.repo.UpdateSet(ctx, name, value); err != nil {
+		return err
+	}
+	return s.window.AddSet(name, value)
+}
+
+func (s *Storage) DeleteGauge(ctx context.Context, name string) error {
+	return s.repo.DeleteGauge(ctx, name)
+}
+
+func (s *Storage) DeleteCounter(ctx context.Context, name string) error {
+	return s.repo.DeleteCounter(ctx, name)
+}
+
+func (s *Storage) ResetCounter(ctx context.Context, name string) error {
+	return s.repo.ResetCounter(ctx, name)
+}
+
+func (s *Storage) DeleteSummary(ctx context.Context, name string) error {
+	return s.repo.DeleteSummary(ctx, name)
+}
+
+func (s *Storage) DeleteSet(ctx context.Context, name string) error {
+	return s.repo.DeleteSet(ctx, name)
+}
+
+func (s *Storage) EvictStale(ctx context.Context, before time.Time) (int, error) {
+	return s.repo.EvictStale(ctx, before)
+}
+
+func (s *Storage) Restore(ctx context.Context, snapshot repository.Snapshot, mode repository.RestoreMode) error {
+	return s.repo.Restore(ctx, snapshot, mode)
+}
+
+func (s *Storage) GetGauge(ctx context.Context, name string) (float64, error) {
+	return s.repo.GetGauge(ctx, name)
+}
+
+func (s *Storage) GetCounter(ctx context.Context, name string) (int64, error) {
+	return s.repo.GetCounter(ctx, name)
+}
+
+func (s *Storage) GetAllGauges(ctx context.Context) (map[string]float64, error) {
+	return s.repo.GetAllGauges(ctx)
+}
+
+func (s *Storage) GetAllCounters(ctx context.Context) (map[string]int64, error) {
+	return s.repo.GetAllCounters(ctx)
+}
+
+func (s *Storage) GetSummary(ctx context.Context, name string) (*sketch.DDSketch, error) {
+	return s.repo.GetSummary(ctx, name)
+}
+
+func (s *Storage) GetAllSummaries(ctx context.Context) (map[string]*sketch.DDSketch, error) {
+	return s.repo.GetAllSummaries(ctx)
+}
+
+func (s *Storage) GetSet(ctx context.Context, name string) (*sketch.HyperLogLog, error) {
+	return s.repo.GetSet(ctx, name)
+}
+
+func (s *Storage) GetAllSets(ctx context.Context) (map[string]*sketch.HyperLogLog, error) {
+	return s.repo.GetAllSets(ctx)
+}
+
+func (s *Storage) GetUpdatedAt(ctx context.Context, metricType, name string) (time.Time, error) {
+	return s.repo.GetUpdatedAt(ctx, metricType, name)
+}
+
+func (s *Storage) List(ctx context.Context, opts repository.ListOptions) (*repository.ListPage, error) {
+	return s.repo.List(ctx, opts)
+}
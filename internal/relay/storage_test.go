@@ -0,0 +1,75 @@
+package relay
+
+import (
+	"github.com/Guram-Gurych/metricserver.git/internal/repository"
+	"github.com/Guram-Gurych/metricserver.git/internal/sketch"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+)
+
+func TestStorage_IncompatibleWithWindow(t *testing.T) {
+	ctx := t.Context()
+	repo := repository.NewMemStorage()
+	window := NewWindow()
+	s := NewStorage(repo, window)
+
+	summary := func(accuracy float64) *sketch.DDSketch {
+		d := sketch.NewDDSketch(accuracy)
+		require.NoError(t, d.Add(1))
+		return d
+	}
+
+	require.NoError(t, s.UpdateSummary(ctx, "latency", summary(0.01)))
+	require.NoError(t, s.UpdateSet(ctx, "users", sketch.NewHyperLogLog(10)))
+	require.NoError(t, s.DeleteSummary(ctx, "latency"))
+	require.NoError(t, s.DeleteSet(ctx, "users"))
+
+	// Локально метрик уже нет, но окно ещё хранит скетчи с другой точностью.
+	assert.ErrorIs(t, s.UpdateSummary(ctx, "latency", summary(0.05)), sketch.ErrIncompatible)
+	_, err := repo.GetSummary(ctx, "latency")
+	assert.ErrorIs(t, err, repository.ErrNotFound, "Отвергнутый окном скетч не должен записываться локально")
+
+	assert.ErrorIs(t, s.UpdateSet(ctx, "users", sketch.NewHyperLogLog(12)), sketch.ErrIncompatible)
+	_, err = repo.GetSet(ctx, "users")
+	assert.ErrorIs(t, err, repository.ErrNotFound, "Отвергнутый окном скетч не должен записываться локально")
+}
+
+func TestStorage_SetCounterDeltas(t *testing.T) {
+	ctx := t.Context()
+	repo := repository.NewMemStorage()
+	window := NewWindow()
+	s := NewStorage(repo, window)
+
+	forwarded := func() int64 {
+		for _, m := range window.Drain() {
+			if m.ID == "requests" {
+				return *m.Delta
+			}
+		}
+		return 0
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, s.SetCounter(ctx, "requests", 10))
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int64(10), forwarded(), "Параллельные SetCounter одного значения должны дать один прирост")
+
+	require.NoError(t, s.ResetCounter(ctx, "requests"))
+	require.NoError(t, s.SetCounter(ctx, "requests", 15))
+	assert.Equal(t, int64(5), forwarded(), "Локальный сброс не должен отправлять всё значение повторно")
+
+	require.NoError(t, s.DeleteCounter(ctx, "requests"))
+	require.NoError(t, s.SetCounter(ctx, "requests", 20))
+	assert.Equal(t, int64(5), forwarded(), "Удаление не должно отправлять всё значение повторно")
+
+	require.NoError(t, s.SetCounter(ctx, "requests", 3))
+	assert.Equal(t, int64(3), forwarded(), "Уменьшение значения - перезапуск источника")
+}
@@ -0,0 +1,135 @@
+package relay
+
+import (
+	"github.com/Guram-Gurych/metricserver.git/internal/model"
+	"github.com/Guram-Gurych/metricserver.git/internal/sketch"
+	"sort"
+	"sync"
+)
+
+// Window копит обновления между отправками: для gauge остаётся последнее значение,
+// counter-ы суммируются, summary и set сливаются в один скетч на метрику.
+type Window struct {
+	mu        sync.Mutex
+	gauges    map[string]float64
+	counters  map[string]int64
+	summaries map[string]*sketch.DDSketch
+	sets      map[string]*sketch.HyperLogLog
+}
+
+func NewWindow() *Window {
+	w := &Window{}
+	w.reset()
+	return w
+}
+
+func (w *Window) reset() {
+	w.gauges = make(map[string]float64)
+	w.counters = make(map[string]int64)
+	w.summaries = make(map[string]*sketch.DDSketch)
+	w.sets = make(map[string]*sketch.HyperLogLog)
+}
+
+func (w *Window) AddGauge(name string, value float64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.gauges[name] = value
+}
+
+func (w *Window) AddCounter(name string, delta int64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.counters[name] += delta
+}
+
+func (w *Window) AddSummary(name string, value *sketch.DDSketch) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	current, ok := w.summaries[name]
+	if !ok {
+		w.summaries[name] = value.Copy()
+		return nil
+	}
+	return current.Merge(value)
+}
+
+func (w *Window) AddSet(name string, value *sketch.HyperLogLog) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	current, ok := w.sets[name]
+	if !ok {
+		w.sets[name] = value.Copy()
+		return nil
+	}
+	return current.Merge(value)
+}
+
+// checkSummary проверяет, что скетч сольётся с накопленным в окне, ничего не меняя.
+func (w *Window) checkSummary(name string, value *sketch.DDSketch) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	current, ok := w.summaries[name]
+	if !ok || value == nil || value.Count == 0 || current.Count == 0 {
+		return nil
+	}
+	if current.RelativeAccuracy != value.RelativeAccuracy {
+		return sketch.ErrIncompatible
+	}
+	return nil
+}
+
+// checkSet проверяет, что скетч сольётся с накопленным в окне, ничего не меняя.
+func (w *Window) checkSet(name string, value *sketch.HyperLogLog) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	current, ok := w.sets[name]
+	if !ok || value == nil {
+		return nil
+	}
+	if current.Precision != value.Precision || len(current.Registers) != len(value.Registers) {
+		return sketch.ErrIncompatible
+	}
+	return nil
+}
+
+// Drain забирает накопленное окно как пакет для /updates/ и начинает новое.
+func (w *Window) Drain() []models.Metrics {
+	w.mu.Lock()
+	gauges, counters, summaries, sets := w.gauges, w.counters, w.summaries, w.sets
+	w.reset()
+	w.mu.Unlock()
+
+	batch := make([]models.Metrics, 0, len(gauges)+len(counters)+len(summaries)+len(sets))
+	for name, value := range gauges {
+		value := value
+		batch = append(batch, models.Metrics{ID: name, MType: models.Gauge, Value: &value})
+	}
+	for name, delta := range counters {
+		if delta == 0 {
+			continue
+		}
+		delta := delta
+		batch = append(batch, models.Metrics{ID: name, MType: models.Counter, Delta: &delta})
+	}
+	for name, s := range summaries {
+		batch = append(batch, models.Metrics{ID: name, MType: models.Summary, Sketch: s})
+	}
+	for name, s := range sets {
+		batch = append(batch, models.Metrics{ID: name, MType: models.Set, HLL: s})
+	}
+
+	sort.Slice(batch, func(i, j int) bool {
+		if batch[i].MType != batch[j].MType {
+			return batch[i].MType < batch[j].MType
+		}
+		return batch[i].ID < batch[j].ID
+	})
+
+	return batch
+}
@@ -0,0 +1,37 @@
+package relay
+
+import (
+	"github.com/Guram-Gurych/metricserver.git/internal/model"
+	"github.com/Guram-Gurych/metricserver.git/internal/sketch"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestWindow_Drain(t *testing.T) {
+	w := NewWindow()
+	w.AddGauge("Alloc", 1)
+	w.AddGauge("Alloc", 2)
+	w.AddCounter("PollCount", 3)
+	w.AddCounter("PollCount", 4)
+	w.AddCounter("Idle", 0)
+
+	for _, v := range []float64{10, 20} {
+		s := sketch.NewDDSketch(sketch.DefaultRelativeAccuracy)
+		require.NoError(t, s.Add(v))
+		require.NoError(t, w.AddSummary("latency", s))
+	}
+
+	batch := w.Drain()
+	require.Len(t, batch, 3, "Нулевой counter не должен попадать в пакет")
+
+	byID := make(map[string]models.Metrics)
+	for _, m := range batch {
+		byID[m.ID] = m
+	}
+	assert.Equal(t, 2.0, *byID["Alloc"].Value, "Для gauge остаётся последнее значение")
+	assert.Equal(t, int64(7), *byID["PollCount"].Delta, "Counter-ы окна суммируются")
+	assert.Equal(t, uint64(2), byID["latency"].Sketch.Count, "Summary окна сливаются")
+
+	assert.Empty(t, w.Drain(), "После Drain окно должно начинаться заново")
+}
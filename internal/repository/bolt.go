@@ -0,0 +1,555 @@
+package repository
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"github.com/Guram-Gurych/metricserver.git/internal/model"
+	"github.com/Guram-Gurych/metricserver.git/internal/sketch"
+	"go.etcd.io/bbolt"
+	"math"
+	"strings"
+	"time"
+)
+
+var (
+	bucketUpdated = []byte("updated")
+
+	metricBuckets = map[string][]byte{
+		models.Gauge:   []byte(models.Gauge),
+		models.Counter: []byte(models.Counter),
+		models.Summary: []byte(models.Summary),
+		models.Set:     []byte(models.Set),
+	}
+)
+
+// BoltStorage хранит метрики во встроенной базе bbolt. Каждое изменение - отдельная
+// транзакция с fsync, поэтому файл не перезаписывается целиком и переживает падение процесса.
+type BoltStorage struct {
+	db *bbolt.DB
+}
+
+func NewBoltStorage(path string) (*BoltStorage, error) {
+	db, err := bbolt.Open(path, 0o600, &bbolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		return createBuckets(tx)
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &BoltStorage{db: db}, nil
+}
+
+func (bs *BoltStorage) Close() error {
+	return bs.db.Close()
+}
+
+func (bs *BoltStorage) UpdateGauge(ctx context.Context, name string, value float64) error {
+	return bs.update(ctx, func(tx *bbolt.Tx) error {
+		return putMetric(tx, models.Gauge, name, encodeFloat(value))
+	})
+}
+
+func (bs *BoltStorage) AddGauge(ctx context.Context, name string, delta float64) (float64, error) {
+	var value float64
+	err := bs.update(ctx, func(tx *bbolt.Tx) error {
+		value = delta
+		if raw := tx.Bucket(metricBuckets[models.Gauge]).Get([]byte(name)); raw != nil {
+			value += decodeFloat(raw)
+		}
+		return putMetric(tx, models.Gauge, name, encodeFloat(value))
+	})
+
+	return value, err
+}
+
+func (bs *BoltStorage) UpdateCounter(ctx context.Context, name string, value int64) error {
+	return bs.update(ctx, func(tx *bbolt.Tx) error {
+		current := int64(0)
+		if raw := tx.Bucket(metricBuckets[models.Counter]).Get([]byte(name)); raw != nil {
+			current = decodeInt(raw)
+		}
+		return putMetric(tx, models.Counter, name, encodeInt(current+value))
+	})
+}
+
+func (bs *BoltStorage) SetCounter(ctx context.Context, name string, value int64) error {
+	return bs.update(ctx, func(tx *bbolt.Tx) error {
+		return putMetric(tx, models.Counter, name, encodeInt(value))
+	})
+}
+
+func (bs *BoltStorage) GetGauge(ctx context.Context, name string) (float64, error) {
+	var value float64
+	err := bs.view(ctx, func(tx *bbolt.Tx) error {
+		raw := tx.Bucket(metricBuckets[models.Gauge]).Get([]byte(name))
+		if raw == nil {
+			return ErrNotFound
+		}
+		value = decodeFloat(raw)
+		return nil
+	})
+
+	return value, err
+}
+
+func (bs *BoltStorage) GetCounter(ctx context.Context, name string) (int64, error) {
+	var value int64
+	err := bs.view(ctx, func(tx *bbolt.Tx) error {
+		raw := tx.Bucket(metricBuckets[models.Counter]).Get([]byte(name))
+		if raw == nil {
+			return ErrNotFound
+		}
+		value = decodeInt(raw)
+		return nil
+	})
+
+	return value, err
+}
+
+func (bs *BoltStorage) GetAllGauges(ctx context.Context) (map[string]float64, error) {
+	result := make(map[string]float64)
+	err := bs.view(ctx, func(tx *bbolt.Tx) error {
+		return tx.Bucket(metricBuckets[models.Gauge]).ForEach(func(k, v []byte) error {
+			result[string(k)] = decodeFloat(v)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (bs *BoltStorage) GetAllCounters(ctx context.Context) (map[string]int64, error) {
+	result := make(map[string]int64)
+	err := bs.view(ctx, func(tx *bbolt.Tx) error {
+		return tx.Bucket(metricBuckets[models.Counter]).ForEach(func(k, v []byte) error {
+			result[string(k)] = decodeInt(v)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (bs *BoltStorage) UpdateSummary(ctx context.Context, name string, value *sketch.DDSketch) error {
+	return bs.update(ctx, func(tx *bbolt.Tx) error {
+		merged := value.Copy()
+		if raw := tx.Bucket(metricBuckets[models.Summary]).Get([]byte(name)); raw != nil {
+			var current sketch.DDSketch
+			if err := json.Unmarshal(raw, &current); err != nil {
+				return err
+			}
+			if err := current.Merge(value); err != nil {
+				return err
+			}
+			merged = &current
+		}
+
+		data, err := json.Marshal(merged)
+		if err != nil {
+			return err
+		}
+		return putMetric(tx, models.Summary, name, data)
+	})
+}
+
+func (bs *BoltStorage) GetSummary(ctx context.Context, name string) (*sketch.DDSketch, error) {
+	var value sketch.DDSketch
+	err := bs.view(ctx, func(tx *bbolt.Tx) error {
+		raw := tx.Bucket(metricBuckets[models.Summary]).Get([]byte(name))
+		if raw == nil {
+			return ErrNotFound
+		}
+		return json.Unmarshal(raw, &value)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &value, nil
+}
+
+func (bs *BoltStorage) GetAllSummaries(ctx context.Context) (map[string]*sketch.DDSketch, error) {
+	result := make(map[string]*sketch.DDSketch)
+	err := bs.view(ctx, func(tx *bbolt.Tx) error {
+		return tx.Bucket(metricBuckets[models.Summary]).ForEach(func(k, v []byte) error {
+			var value sketch.DDSketch
+			if err := json.Unmarshal(v, &value); err != nil {
+				return err
+			}
+			result[string(k)] = &value
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (bs *BoltStorage) UpdateSet(ctx context.Context, name string, value *sketch.HyperLogLog) error {
+	return bs.update(ctx, func(tx *bbolt.Tx) error {
+		current := sketch.NewHyperLogLog(value.Precision)
+		if raw := tx.Bucket(metricBuckets[models.Set]).Get([]byte(name)); raw != nil {
+			if err := json.Unmarshal(raw, current); err != nil {
+				return err
+			}
+		}
+		if err := current.Merge(value); err != nil {
+			return err
+		}
+
+		data, err := json.Marshal(current)
+		if err != nil {
+			return err
+		}
+		return putMetric(tx, models.Set, name, data)
+	})
+}
+
+func (bs *BoltStorage) GetSet(ctx context.Context, name string) (*sketch.HyperLogLog, error) {
+	var value sketch.HyperLogLog
+	err := bs.view(ctx, func(tx *bbolt.Tx) error {
+		raw := tx.Bucket(metricBuckets[models.Set]).Get([]byte(name))
+		if raw == nil {
+			return ErrNotFound
+		}
+		return json.Unmarshal(raw, &value)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &value, nil
+}
+
+func (bs *BoltStorage) GetAllSets(ctx context.Context) (map[string]*sketch.HyperLogLog, error) {
+	result := make(map[string]*sketch.HyperLogLog)
+	err := bs.view(ctx, func(tx *bbolt.Tx) error {
+		return tx.Bucket(metricBuckets[models.Set]).ForEach(func(k, v []byte) error {
+			var value sketch.HyperLogLog
+			if err := json.Unmarshal(v, &value); err != nil {
+				return err
+			}
+			result[string(k)] = &value
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (bs *BoltStorage) DeleteGauge(ctx context.Context, name string) error {
+	return bs.delete(ctx, models.Gauge, name)
+}
+
+func (bs *BoltStorage) DeleteCounter(ctx context.Context, name string) error {
+	return bs.delete(ctx, models.Counter, name)
+}
+
+func (bs *BoltStorage) ResetCounter(ctx context.Context, name string) error {
+	return bs.update(ctx, func(tx *bbolt.Tx) error {
+		if tx.Bucket(metricBuckets[models.Counter]).Get([]byte(name)) == nil {
+			return ErrNotFound
+		}
+		return putMetric(tx, models.Counter, name, encodeInt(0))
+	})
+}
+
+func (bs *BoltStorage) DeleteSummary(ctx context.Context, name string) error {
+	return bs.delete(ctx, models.Summary, name)
+}
+
+func (bs *BoltStorage) DeleteSet(ctx context.Context, name string) error {
+	return bs.delete(ctx, models.Set, name)
+}
+
+func (bs *BoltStorage) GetUpdatedAt(ctx context.Context, metricType, name string) (time.Time, error) {
+	var value time.Time
+	err := bs.view(ctx, func(tx *bbolt.Tx) error {
+		raw := tx.Bucket(bucketUpdated).Get(updatedKey(metricType, name))
+		if raw == nil {
+			return ErrNotFound
+		}
+		value = time.Unix(0, decodeInt(raw))
+		return nil
+	})
+
+	return value, err
+}
+
+// EvictStale удаляет все метрики, которые не обновлялись с момента before.
+func (bs *BoltStorage) EvictStale(ctx context.Context, before time.Time) (int, error) {
+	evicted := 0
+	err := bs.update(ctx, func(tx *bbolt.Tx) error {
+		updated := tx.Bucket(bucketUpdated)
+
+		var stale [][]byte
+		err := updated.ForEach(func(k, v []byte) error {
+			if time.Unix(0, decodeInt(v)).Before(before) {
+				stale = append(stale, append([]byte(nil), k...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, key := range stale {
+			metricType, name := splitUpdatedKey(key)
+			if bucket, ok := metricBuckets[metricType]; ok {
+				if err := tx.Bucket(bucket).Delete([]byte(name)); err != nil {
+					return err
+				}
+			}
+			if err := updated.Delete(key); err != nil {
+				return err
+			}
+		}
+		evicted = len(stale)
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return evicted, nil
+}
+
+func (bs *BoltStorage) Restore(ctx context.Context, snapshot Snapshot, mode RestoreMode) error {
+	if _, err := ParseRestoreMode(string(mode)); err != nil {
+		return err
+	}
+	if err := snapshot.validate(); err != nil {
+		return err
+	}
+
+	return bs.update(ctx, func(tx *bbolt.Tx) error {
+		if mode == RestoreReplace {
+			for _, bucket := range metricBuckets {
+				if err := tx.DeleteBucket(bucket); err != nil {
+					return err
+				}
+			}
+			if err := tx.DeleteBucket(bucketUpdated); err != nil {
+				return err
+			}
+			if err := createBuckets(tx); err != nil {
+				return err
+			}
+		}
+
+		now := time.Now()
+		for name, value := range snapshot.Gauges {
+			if err := putMetricAt(tx, models.Gauge, name, encodeFloat(value), snapshot.updatedAt(models.Gauge, name, now)); err != nil {
+				return err
+			}
+		}
+		for name, value := range snapshot.Counters {
+			if err := putMetricAt(tx, models.Counter, name, encodeInt(value), snapshot.updatedAt(models.Counter, name, now)); err != nil {
+				return err
+			}
+		}
+		for name, value := range snapshot.Summaries {
+			data, err := json.Marshal(value)
+			if err != nil {
+				return err
+			}
+			if err := putMetricAt(tx, models.Summary, name, data, snapshot.updatedAt(models.Summary, name, now)); err != nil {
+				return err
+			}
+		}
+		for name, value := range snapshot.Sets {
+			data, err := json.Marshal(value)
+			if err != nil {
+				return err
+			}
+			if err := putMetricAt(tx, models.Set, name, data, snapshot.updatedAt(models.Set, name, now)); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (bs *BoltStorage) List(ctx context.Context, opts ListOptions) (*ListPage, error) {
+	q, err := parseListOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &ListPage{}
+	err = bs.view(ctx, func(tx *bbolt.Tx) error {
+		for _, idx := range q.types() {
+			metricType := MetricTypes[idx]
+			c := tx.Bucket(metricBuckets[metricType]).Cursor()
+
+			start := q.Prefix
+			if q.hasAfter && q.after.typeIndex == idx && q.after.name > start {
+				start = q.after.name
+			}
+
+			for k, v := c.Seek([]byte(start)); k != nil; k, v = c.Next() {
+				name := string(k)
+				if !strings.HasPrefix(name, q.Prefix) {
+					break
+				}
+				if !q.matches(listPosition{typeIndex: idx, name: name}) {
+					continue
+				}
+				if q.full(page) {
+					return nil
+				}
+
+				m, err := decodeMetric(metricType, name, v)
+				if err != nil {
+					return err
+				}
+				if raw := tx.Bucket(bucketUpdated).Get(updatedKey(metricType, name)); raw != nil {
+					updatedAt := time.Unix(0, decodeInt(raw))
+					m.UpdatedAt = &updatedAt
+				}
+				page.Metrics = append(page.Metrics, m)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return page, nil
+}
+
+func (bs *BoltStorage) update(ctx context.Context, fn func(tx *bbolt.Tx) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return bs.db.Update(fn)
+}
+
+func (bs *BoltStorage) view(ctx context.Context, fn func(tx *bbolt.Tx) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return bs.db.View(fn)
+}
+
+func (bs *BoltStorage) delete(ctx context.Context, metricType, name string) error {
+	return bs.update(ctx, func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(metricBuckets[metricType])
+		if bucket.Get([]byte(name)) == nil {
+			return ErrNotFound
+		}
+		if err := bucket.Delete([]byte(name)); err != nil {
+			return err
+		}
+		return tx.Bucket(bucketUpdated).Delete(updatedKey(metricType, name))
+	})
+}
+
+func decodeMetric(metricType, name string, raw []byte) (models.Metrics, error) {
+	m := models.Metrics{ID: name, MType: metricType}
+	switch metricType {
+	case models.Gauge:
+		value := decodeFloat(raw)
+		m.Value = &value
+	case models.Counter:
+		delta := decodeInt(raw)
+		m.Delta = &delta
+	case models.Summary:
+		m.Sketch = &sketch.DDSketch{}
+		if err := json.Unmarshal(raw, m.Sketch); err != nil {
+			return m, err
+		}
+	case models.Set:
+		m.HLL = &sketch.HyperLogLog{}
+		if err := json.Unmarshal(raw, m.HLL); err != nil {
+			return m, err
+		}
+	}
+
+	return m, nil
+}
+
+func createBuckets(tx *bbolt.Tx) error {
+	for _, bucket := range metricBuckets {
+		if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+			return err
+		}
+	}
+
+	_, err := tx.CreateBucketIfNotExists(bucketUpdated)
+	return err
+}
+
+// putMetric записывает значение и время обновления метрики в одной транзакции.
+func putMetric(tx *bbolt.Tx, metricType, name string, value []byte) error {
+	return putMetricAt(tx, metricType, name, value, time.Now())
+}
+
+func putMetricAt(tx *bbolt.Tx, metricType, name string, value []byte, updatedAt time.Time) error {
+	if name == "" {
+		return ErrEmptyName
+	}
+	if err := tx.Bucket(metricBuckets[metricType]).Put([]byte(name), value); err != nil {
+		return err
+	}
+
+	return tx.Bucket(bucketUpdated).Put(updatedKey(metricType, name), encodeInt(updatedAt.UnixNano()))
+}
+
+// updatedKey разделяет тип и имя нулевым байтом: в имени метрики может встретиться любой печатный символ.
+func updatedKey(metricType, name string) []byte {
+	key := make([]byte, 0, len(metricType)+1+len(name))
+	key = append(key, metricType...)
+	key = append(key, 0)
+	return append(key, name...)
+}
+
+func splitUpdatedKey(key []byte) (string, string) {
+	for i, b := range key {
+		if b == 0 {
+			return string(key[:i]), string(key[i+1:])
+		}
+	}
+
+	return "", string(key)
+}
+
+func encodeFloat(value float64) []byte {
+	return binary.BigEndian.AppendUint64(nil, math.Float64bits(value))
+}
+
+func decodeFloat(raw []byte) float64 {
+	return math.Float64frombits(binary.BigEndian.Uint64(raw))
+}
+
+func encodeInt(value int64) []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(value))
+}
+
+func decodeInt(raw []byte) int64 {
+	return int64(binary.BigEndian.Uint64(raw))
+}
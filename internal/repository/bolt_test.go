@@ -0,0 +1,152 @@
+package repository
+
+import (
+	"context"
+	"github.com/Guram-Gurych/metricserver.git/internal/model"
+	"github.com/Guram-Gurych/metricserver.git/internal/sketch"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBoltStorage_Durability(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "metrics.db")
+
+	storage, err := NewBoltStorage(path)
+	require.NoError(t, err)
+
+	require.NoError(t, storage.UpdateGauge(ctx, "Alloc", 1.5))
+	require.NoError(t, storage.UpdateCounter(ctx, "PollCount", 3))
+	require.NoError(t, storage.UpdateCounter(ctx, "PollCount", 4))
+	_, err = storage.AddGauge(ctx, "Inflight", 3)
+	require.NoError(t, err)
+	added, err := storage.AddGauge(ctx, "Inflight", -1)
+	require.NoError(t, err)
+	assert.Equal(t, float64(2), added, "AddGauge должен возвращать новое значение")
+
+	summary := sketch.NewDDSketch(sketch.DefaultRelativeAccuracy)
+	require.NoError(t, summary.Add(10))
+	require.NoError(t, storage.UpdateSummary(ctx, "Latency", summary))
+	require.NoError(t, storage.UpdateSummary(ctx, "Latency", summary))
+
+	set := sketch.NewHyperLogLog(sketch.DefaultPrecision)
+	set.Add("alice")
+	require.NoError(t, storage.UpdateSet(ctx, "Users", set))
+	require.NoError(t, storage.Close())
+
+	storage, err = NewBoltStorage(path)
+	require.NoError(t, err)
+	defer storage.Close()
+
+	gauge, err := storage.GetGauge(ctx, "Alloc")
+	require.NoError(t, err)
+	assert.Equal(t, 1.5, gauge)
+
+	gauge, err = storage.GetGauge(ctx, "Inflight")
+	require.NoError(t, err)
+	assert.Equal(t, float64(2), gauge)
+
+	counter, err := storage.GetCounter(ctx, "PollCount")
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), counter, "Counter должен накапливаться между обновлениями")
+
+	gotSummary, err := storage.GetSummary(ctx, "Latency")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), gotSummary.Count)
+
+	gotSet, err := storage.GetSet(ctx, "Users")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), gotSet.Estimate())
+
+	_, err = storage.GetGauge(ctx, "Unknown")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestBoltStorage_DeleteEvictRestore(t *testing.T) {
+	ctx := context.Background()
+	storage, err := NewBoltStorage(filepath.Join(t.TempDir(), "metrics.db"))
+	require.NoError(t, err)
+	defer storage.Close()
+
+	require.NoError(t, storage.UpdateGauge(ctx, "Alloc", 1))
+	require.NoError(t, storage.UpdateCounter(ctx, "PollCount", 5))
+
+	updatedAt, err := storage.GetUpdatedAt(ctx, models.Gauge, "Alloc")
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), updatedAt, time.Second)
+
+	require.NoError(t, storage.ResetCounter(ctx, "PollCount"))
+	counter, err := storage.GetCounter(ctx, "PollCount")
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), counter)
+
+	require.NoError(t, storage.DeleteGauge(ctx, "Alloc"))
+	assert.ErrorIs(t, storage.DeleteGauge(ctx, "Alloc"), ErrNotFound)
+	_, err = storage.GetUpdatedAt(ctx, models.Gauge, "Alloc")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	evicted, err := storage.EvictStale(ctx, time.Now().Add(time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, 1, evicted)
+
+	require.NoError(t, storage.UpdateGauge(ctx, "Old", 1))
+	hourAgo := time.Now().Add(-time.Hour).Truncate(time.Second)
+	snapshot := Snapshot{
+		Gauges:    map[string]float64{"New": 2},
+		Counters:  map[string]int64{"PollCount": 9},
+		UpdatedAt: map[string]map[string]time.Time{models.Gauge: {"New": hourAgo}},
+	}
+	require.NoError(t, storage.Restore(ctx, snapshot, RestoreReplace))
+	require.NoError(t, storage.Restore(ctx, snapshot, RestoreReplace))
+
+	got, err := TakeSnapshot(ctx, storage)
+	require.NoError(t, err)
+	assert.Equal(t, snapshot.Gauges, got.Gauges, "Replace должен удалить метрики, которых нет в снимке")
+	assert.Equal(t, snapshot.Counters, got.Counters, "Повторный Restore не должен удваивать counter")
+
+	updatedAt, err = storage.GetUpdatedAt(ctx, models.Gauge, "New")
+	require.NoError(t, err)
+	assert.True(t, hourAgo.Equal(updatedAt), "Restore должен сохранять время обновления из снимка")
+	updatedAt, err = storage.GetUpdatedAt(ctx, models.Counter, "PollCount")
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), updatedAt, time.Second, "Без времени в снимке берётся время восстановления")
+}
+
+func TestStorage_EmptyName(t *testing.T) {
+	bolt, err := NewBoltStorage(filepath.Join(t.TempDir(), "metrics.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { bolt.Close() })
+
+	storages := []struct {
+		name string
+		repo MetricRepository
+	}{
+		{name: "MemStorage", repo: NewMemStorage()},
+		{name: "ShardedStorage", repo: NewShardedStorage(DefaultShards)},
+		{name: "BoltStorage", repo: bolt},
+	}
+
+	for _, storage := range storages {
+		t.Run(storage.name, func(t *testing.T) {
+			ctx := context.Background()
+			require.NoError(t, storage.repo.UpdateGauge(ctx, "Alloc", 1))
+
+			assert.ErrorIs(t, storage.repo.UpdateGauge(ctx, "", 1), ErrEmptyName)
+			assert.ErrorIs(t, storage.repo.UpdateCounter(ctx, "", 1), ErrEmptyName)
+			_, err := storage.repo.AddGauge(ctx, "", 1)
+			assert.ErrorIs(t, err, ErrEmptyName)
+
+			snapshot := Snapshot{Gauges: map[string]float64{"": 1, "New": 2}}
+			assert.ErrorIs(t, storage.repo.Restore(ctx, snapshot, RestoreReplace), ErrEmptyName)
+			broken := Snapshot{Summaries: map[string]*sketch.DDSketch{"Latency": nil}}
+			assert.ErrorIs(t, storage.repo.Restore(ctx, broken, RestoreReplace), sketch.ErrInvalidSketch)
+
+			got, err := TakeSnapshot(ctx, storage.repo)
+			require.NoError(t, err)
+			assert.Equal(t, map[string]float64{"Alloc": 1}, got.Gauges, "Отклонённый Restore не должен ничего менять")
+		})
+	}
+}
@@ -1,11 +1,41 @@
 package repository
 
+import (
+	"context"
+	"errors"
+	"github.com/Guram-Gurych/metricserver.git/internal/sketch"
+	"time"
+)
+
+var ErrNotFound = errors.New("metric not found")
+
+// ErrEmptyName возвращают все хранилища при записи метрики без имени.
+var ErrEmptyName = errors.New("metric name is empty")
+
 //go:generate mockgen -source=interface.go -destination=mocks/mock_repository.go -package=mocks
 type MetricRepository interface {
-	UpdateGauge(name string, value float64) error
-	UpdateCounter(name string, value int64) error
-	GetGauge(name string) (float64, bool)
-	GetCounter(name string) (int64, bool)
-	GetAllGauges() map[string]float64
-	GetAllCounters() map[string]int64
+	UpdateGauge(ctx context.Context, name string, value float64) error
+	// AddGauge атомарно прибавляет delta к gauge (отсутствующий считается нулём) и возвращает новое значение.
+	AddGauge(ctx context.Context, name string, delta float64) (float64, error)
+	UpdateCounter(ctx context.Context, name string, value int64) error
+	SetCounter(ctx context.Context, name string, value int64) error
+	GetGauge(ctx context.Context, name string) (float64, error)
+	GetCounter(ctx context.Context, name string) (int64, error)
+	GetAllGauges(ctx context.Context) (map[string]float64, error)
+	GetAllCounters(ctx context.Context) (map[string]int64, error)
+	UpdateSummary(ctx context.Context, name string, value *sketch.DDSketch) error
+	GetSummary(ctx context.Context, name string) (*sketch.DDSketch, error)
+	GetAllSummaries(ctx context.Context) (map[string]*sketch.DDSketch, error)
+	UpdateSet(ctx context.Context, name string, value *sketch.HyperLogLog) error
+	GetSet(ctx context.Context, name string) (*sketch.HyperLogLog, error)
+	GetAllSets(ctx context.Context) (map[string]*sketch.HyperLogLog, error)
+	DeleteGauge(ctx context.Context, name string) error
+	DeleteCounter(ctx context.Context, name string) error
+	ResetCounter(ctx context.Context, name string) error
+	DeleteSummary(ctx context.Context, name string) error
+	DeleteSet(ctx context.Context, name string) error
+	GetUpdatedAt(ctx context.Context, metricType, name string) (time.Time, error)
+	EvictStale(ctx context.Context, before time.Time) (int, error)
+	Restore(ctx context.Context, snapshot Snapshot, mode RestoreMode) error
+	List(ctx context.Context, opts ListOptions) (*ListPage, error)
 }
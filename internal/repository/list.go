@@ -0,0 +1,191 @@
+package repository
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"github.com/Guram-Gurych/metricserver.git/internal/model"
+	"path"
+	"sort"
+	"strings"
+)
+
+// eachPageSize - размер страницы, которой Each обходит хранилище, если лимит не задан.
+const eachPageSize = 1000
+
+var (
+	ErrInvalidCursor     = errors.New("invalid cursor")
+	ErrInvalidMetricType = errors.New("invalid metric type")
+	ErrInvalidPattern    = errors.New("invalid name pattern")
+)
+
+// MetricTypes задаёт порядок типов при обходе хранилища через List.
+var MetricTypes = []string{models.Gauge, models.Counter, models.Summary, models.Set}
+
+// ListOptions описывает выборку для List. Метрики отдаются отсортированными по типу
+// (в порядке MetricTypes), а внутри типа - по имени.
+type ListOptions struct {
+	// Type ограничивает выборку одним типом, пустая строка - все типы.
+	Type string
+	// Prefix оставляет только метрики, имя которых начинается с него.
+	Prefix string
+	// Match - шаблон имени в синтаксисе path.Match, пустая строка - без фильтра.
+	Match string
+	// Cursor - значение NextCursor предыдущей страницы, пустая строка - с начала.
+	Cursor string
+	// Limit - максимальный размер страницы, 0 - без ограничения.
+	Limit int
+}
+
+type ListPage struct {
+	Metrics []models.Metrics
+	// NextCursor пуст, если это последняя страница.
+	NextCursor string
+}
+
+// listPosition - позиция метрики в порядке обхода List.
+type listPosition struct {
+	typeIndex int
+	name      string
+}
+
+func (p listPosition) less(other listPosition) bool {
+	if p.typeIndex != other.typeIndex {
+		return p.typeIndex < other.typeIndex
+	}
+	return p.name < other.name
+}
+
+// listQuery - разобранные ListOptions, общие для всех реализаций List.
+type listQuery struct {
+	ListOptions
+	after    listPosition
+	hasAfter bool
+}
+
+func parseListOptions(opts ListOptions) (listQuery, error) {
+	q := listQuery{ListOptions: opts}
+	if opts.Type != "" && typeIndex(opts.Type) < 0 {
+		return q, ErrInvalidMetricType
+	}
+	if opts.Limit < 0 {
+		q.Limit = 0
+	}
+	if opts.Match != "" {
+		if _, err := path.Match(opts.Match, ""); err != nil {
+			return q, ErrInvalidPattern
+		}
+	}
+
+	if opts.Cursor != "" {
+		raw, err := base64.RawURLEncoding.DecodeString(opts.Cursor)
+		if err != nil {
+			return q, ErrInvalidCursor
+		}
+		metricType, name, ok := strings.Cut(string(raw), "\x00")
+		if !ok || typeIndex(metricType) < 0 {
+			return q, ErrInvalidCursor
+		}
+		q.after = listPosition{typeIndex: typeIndex(metricType), name: name}
+		q.hasAfter = true
+	}
+
+	return q, nil
+}
+
+// types возвращает типы, которые нужно обойти, с их позициями в MetricTypes.
+func (q listQuery) types() []int {
+	var result []int
+	for i, metricType := range MetricTypes {
+		if q.Type != "" && q.Type != metricType {
+			continue
+		}
+		if q.hasAfter && i < q.after.typeIndex {
+			continue
+		}
+		result = append(result, i)
+	}
+
+	return result
+}
+
+// matches проверяет префикс, шаблон и то, что метрика идёт строго после курсора.
+func (q listQuery) matches(pos listPosition) bool {
+	if !strings.HasPrefix(pos.name, q.Prefix) {
+		return false
+	}
+	if q.hasAfter && !q.after.less(pos) {
+		return false
+	}
+	if q.Match != "" {
+		matched, _ := path.Match(q.Match, pos.name)
+		return matched
+	}
+
+	return true
+}
+
+// full вызывается перед добавлением очередной подходящей метрики. Если страница
+// уже заполнена, выставляет NextCursor и сообщает, что обход пора заканчивать.
+func (q listQuery) full(page *ListPage) bool {
+	if q.Limit == 0 || len(page.Metrics) < q.Limit {
+		return false
+	}
+
+	page.NextCursor = encodeCursor(page.Metrics[len(page.Metrics)-1])
+	return true
+}
+
+func encodeCursor(m models.Metrics) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(m.MType + "\x00" + m.ID))
+}
+
+func typeIndex(metricType string) int {
+	for i, t := range MetricTypes {
+		if t == metricType {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func positionOf(m models.Metrics) listPosition {
+	return listPosition{typeIndex: typeIndex(m.MType), name: m.ID}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Each обходит метрики постранично и вызывает fn для каждой.
+// В отличие от GetAll* хранилище не копируется целиком, а блокируется только на время страницы.
+func Each(ctx context.Context, repo MetricRepository, opts ListOptions, fn func(models.Metrics) error) error {
+	if opts.Limit <= 0 {
+		opts.Limit = eachPageSize
+	}
+
+	for {
+		page, err := repo.List(ctx, opts)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range page.Metrics {
+			if err := fn(m); err != nil {
+				return err
+			}
+		}
+
+		if page.NextCursor == "" {
+			return nil
+		}
+		opts.Cursor = page.NextCursor
+	}
+}
@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"github.com/Guram-Gurych/metricserver.git/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+)
+
+func TestList_Pagination(t *testing.T) {
+	bolt, err := NewBoltStorage(filepath.Join(t.TempDir(), "metrics.db"))
+	require.NoError(t, err)
+	defer bolt.Close()
+
+	storages := []struct {
+		name string
+		repo MetricRepository
+	}{
+		{name: "MemStorage", repo: NewMemStorage()},
+		{name: "ShardedStorage", repo: NewShardedStorage(4)},
+		{name: "BoltStorage", repo: bolt},
+	}
+
+	for _, storage := range storages {
+		t.Run(storage.name, func(t *testing.T) {
+			ctx := context.Background()
+			for i := 0; i < 5; i++ {
+				require.NoError(t, storage.repo.UpdateGauge(ctx, fmt.Sprintf("heap%d", i), float64(i)))
+				require.NoError(t, storage.repo.UpdateCounter(ctx, fmt.Sprintf("req%d", i), int64(i)))
+			}
+			require.NoError(t, storage.repo.UpdateGauge(ctx, "alloc", 1))
+
+			var got []string
+			opts := ListOptions{Limit: 3}
+			pages := 0
+			for {
+				page, err := storage.repo.List(ctx, opts)
+				require.NoError(t, err)
+				assert.LessOrEqual(t, len(page.Metrics), 3, "Страница больше лимита")
+				for _, m := range page.Metrics {
+					got = append(got, m.MType+"/"+m.ID)
+				}
+				pages++
+				if page.NextCursor == "" {
+					break
+				}
+				opts.Cursor = page.NextCursor
+			}
+
+			expected := []string{"gauge/alloc", "gauge/heap0", "gauge/heap1", "gauge/heap2", "gauge/heap3", "gauge/heap4",
+				"counter/req0", "counter/req1", "counter/req2", "counter/req3", "counter/req4"}
+			assert.Equal(t, expected, got, "Метрики должны идти по типам и по именам без пропусков и повторов")
+			assert.Equal(t, 4, pages)
+
+			page, err := storage.repo.List(ctx, ListOptions{Type: models.Gauge, Prefix: "heap", Limit: 2})
+			require.NoError(t, err)
+			require.Len(t, page.Metrics, 2)
+			assert.Equal(t, "heap0", page.Metrics[0].ID)
+			assert.Equal(t, float64(1), *page.Metrics[1].Value)
+			assert.NotEmpty(t, page.NextCursor)
+
+			page, err = storage.repo.List(ctx, ListOptions{Type: models.Counter, Prefix: "req4"})
+			require.NoError(t, err)
+			require.Len(t, page.Metrics, 1)
+			assert.Equal(t, int64(4), *page.Metrics[0].Delta)
+			assert.Empty(t, page.NextCursor)
+
+			page, err = storage.repo.List(ctx, ListOptions{Match: "*[13]"})
+			require.NoError(t, err)
+			require.Len(t, page.Metrics, 4)
+			assert.Equal(t, "heap1", page.Metrics[0].ID)
+			assert.NotNil(t, page.Metrics[0].UpdatedAt, "List должен отдавать время обновления")
+
+			// Индекс имён должен видеть добавленные и удалённые после прошлого List метрики.
+			require.NoError(t, storage.repo.UpdateGauge(ctx, "heap10", 10))
+			require.NoError(t, storage.repo.DeleteGauge(ctx, "heap0"))
+			page, err = storage.repo.List(ctx, ListOptions{Type: models.Gauge, Prefix: "heap", Limit: 2})
+			require.NoError(t, err)
+			require.Len(t, page.Metrics, 2)
+			assert.Equal(t, "heap1", page.Metrics[0].ID)
+			assert.Equal(t, "heap10", page.Metrics[1].ID)
+
+			_, err = storage.repo.List(ctx, ListOptions{Match: "["})
+			assert.ErrorIs(t, err, ErrInvalidPattern)
+			_, err = storage.repo.List(ctx, ListOptions{Cursor: "!!!"})
+			assert.ErrorIs(t, err, ErrInvalidCursor)
+			_, err = storage.repo.List(ctx, ListOptions{Type: "histogram"})
+			assert.ErrorIs(t, err, ErrInvalidMetricType)
+		})
+	}
+}
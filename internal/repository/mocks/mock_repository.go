@@ -5,8 +5,12 @@
 package mocks
 
 import (
+	context "context"
 	reflect "reflect"
+	time "time"
 
+	repository "github.com/Guram-Gurych/metricserver.git/internal/repository"
+	sketch "github.com/Guram-Gurych/metricserver.git/internal/sketch"
 	gomock "github.com/golang/mock/gomock"
 )
 
@@ -33,88 +37,336 @@ func (m *MockMetricRepository) EXPECT() *MockMetricRepositoryMockRecorder {
 	return m.recorder
 }
 
+// AddGauge mocks base method.
+func (m *MockMetricRepository) AddGauge(ctx context.Context, name string, delta float64) (float64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddGauge", ctx, name, delta)
+	ret0, _ := ret[0].(float64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddGauge indicates an expected call of AddGauge.
+func (mr *MockMetricRepositoryMockRecorder) AddGauge(ctx, name, delta interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddGauge", reflect.TypeOf((*MockMetricRepository)(nil).AddGauge), ctx, name, delta)
+}
+
+// DeleteCounter mocks base method.
+func (m *MockMetricRepository) DeleteCounter(ctx context.Context, name string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteCounter", ctx, name)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteCounter indicates an expected call of DeleteCounter.
+func (mr *MockMetricRepositoryMockRecorder) DeleteCounter(ctx, name interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteCounter", reflect.TypeOf((*MockMetricRepository)(nil).DeleteCounter), ctx, name)
+}
+
+// DeleteGauge mocks base method.
+func (m *MockMetricRepository) DeleteGauge(ctx context.Context, name string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteGauge", ctx, name)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteGauge indicates an expected call of DeleteGauge.
+func (mr *MockMetricRepositoryMockRecorder) DeleteGauge(ctx, name interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteGauge", reflect.TypeOf((*MockMetricRepository)(nil).DeleteGauge), ctx, name)
+}
+
+// DeleteSet mocks base method.
+func (m *MockMetricRepository) DeleteSet(ctx context.Context, name string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteSet", ctx, name)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteSet indicates an expected call of DeleteSet.
+func (mr *MockMetricRepositoryMockRecorder) DeleteSet(ctx, name interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteSet", reflect.TypeOf((*MockMetricRepository)(nil).DeleteSet), ctx, name)
+}
+
+// DeleteSummary mocks base method.
+func (m *MockMetricRepository) DeleteSummary(ctx context.Context, name string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteSummary", ctx, name)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteSummary indicates an expected call of DeleteSummary.
+func (mr *MockMetricRepositoryMockRecorder) DeleteSummary(ctx, name interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteSummary", reflect.TypeOf((*MockMetricRepository)(nil).DeleteSummary), ctx, name)
+}
+
+// EvictStale mocks base method.
+func (m *MockMetricRepository) EvictStale(ctx context.Context, before time.Time) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EvictStale", ctx, before)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EvictStale indicates an expected call of EvictStale.
+func (mr *MockMetricRepositoryMockRecorder) EvictStale(ctx, before interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EvictStale", reflect.TypeOf((*MockMetricRepository)(nil).EvictStale), ctx, before)
+}
+
 // GetAllCounters mocks base method.
-func (m *MockMetricRepository) GetAllCounters() map[string]int64 {
+func (m *MockMetricRepository) GetAllCounters(ctx context.Context) (map[string]int64, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetAllCounters")
+	ret := m.ctrl.Call(m, "GetAllCounters", ctx)
 	ret0, _ := ret[0].(map[string]int64)
-	return ret0
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // GetAllCounters indicates an expected call of GetAllCounters.
-func (mr *MockMetricRepositoryMockRecorder) GetAllCounters() *gomock.Call {
+func (mr *MockMetricRepositoryMockRecorder) GetAllCounters(ctx interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllCounters", reflect.TypeOf((*MockMetricRepository)(nil).GetAllCounters))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllCounters", reflect.TypeOf((*MockMetricRepository)(nil).GetAllCounters), ctx)
 }
 
 // GetAllGauges mocks base method.
-func (m *MockMetricRepository) GetAllGauges() map[string]float64 {
+func (m *MockMetricRepository) GetAllGauges(ctx context.Context) (map[string]float64, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetAllGauges")
+	ret := m.ctrl.Call(m, "GetAllGauges", ctx)
 	ret0, _ := ret[0].(map[string]float64)
-	return ret0
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // GetAllGauges indicates an expected call of GetAllGauges.
-func (mr *MockMetricRepositoryMockRecorder) GetAllGauges() *gomock.Call {
+func (mr *MockMetricRepositoryMockRecorder) GetAllGauges(ctx interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllGauges", reflect.TypeOf((*MockMetricRepository)(nil).GetAllGauges))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllGauges", reflect.TypeOf((*MockMetricRepository)(nil).GetAllGauges), ctx)
+}
+
+// GetAllSets mocks base method.
+func (m *MockMetricRepository) GetAllSets(ctx context.Context) (map[string]*sketch.HyperLogLog, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAllSets", ctx)
+	ret0, _ := ret[0].(map[string]*sketch.HyperLogLog)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAllSets indicates an expected call of GetAllSets.
+func (mr *MockMetricRepositoryMockRecorder) GetAllSets(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllSets", reflect.TypeOf((*MockMetricRepository)(nil).GetAllSets), ctx)
+}
+
+// GetAllSummaries mocks base method.
+func (m *MockMetricRepository) GetAllSummaries(ctx context.Context) (map[string]*sketch.DDSketch, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAllSummaries", ctx)
+	ret0, _ := ret[0].(map[string]*sketch.DDSketch)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAllSummaries indicates an expected call of GetAllSummaries.
+func (mr *MockMetricRepositoryMockRecorder) GetAllSummaries(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllSummaries", reflect.TypeOf((*MockMetricRepository)(nil).GetAllSummaries), ctx)
 }
 
 // GetCounter mocks base method.
-func (m *MockMetricRepository) GetCounter(name string) (int64, bool) {
+func (m *MockMetricRepository) GetCounter(ctx context.Context, name string) (int64, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetCounter", name)
+	ret := m.ctrl.Call(m, "GetCounter", ctx, name)
 	ret0, _ := ret[0].(int64)
-	ret1, _ := ret[1].(bool)
+	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetCounter indicates an expected call of GetCounter.
-func (mr *MockMetricRepositoryMockRecorder) GetCounter(name interface{}) *gomock.Call {
+func (mr *MockMetricRepositoryMockRecorder) GetCounter(ctx, name interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCounter", reflect.TypeOf((*MockMetricRepository)(nil).GetCounter), name)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCounter", reflect.TypeOf((*MockMetricRepository)(nil).GetCounter), ctx, name)
 }
 
 // GetGauge mocks base method.
-func (m *MockMetricRepository) GetGauge(name string) (float64, bool) {
+func (m *MockMetricRepository) GetGauge(ctx context.Context, name string) (float64, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetGauge", name)
+	ret := m.ctrl.Call(m, "GetGauge", ctx, name)
 	ret0, _ := ret[0].(float64)
-	ret1, _ := ret[1].(bool)
+	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetGauge indicates an expected call of GetGauge.
-func (mr *MockMetricRepositoryMockRecorder) GetGauge(name interface{}) *gomock.Call {
+func (mr *MockMetricRepositoryMockRecorder) GetGauge(ctx, name interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetGauge", reflect.TypeOf((*MockMetricRepository)(nil).GetGauge), name)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetGauge", reflect.TypeOf((*MockMetricRepository)(nil).GetGauge), ctx, name)
+}
+
+// GetSet mocks base method.
+func (m *MockMetricRepository) GetSet(ctx context.Context, name string) (*sketch.HyperLogLog, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSet", ctx, name)
+	ret0, _ := ret[0].(*sketch.HyperLogLog)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSet indicates an expected call of GetSet.
+func (mr *MockMetricRepositoryMockRecorder) GetSet(ctx, name interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSet", reflect.TypeOf((*MockMetricRepository)(nil).GetSet), ctx, name)
+}
+
+// GetSummary mocks base method.
+func (m *MockMetricRepository) GetSummary(ctx context.Context, name string) (*sketch.DDSketch, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSummary", ctx, name)
+	ret0, _ := ret[0].(*sketch.DDSketch)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSummary indicates an expected call of GetSummary.
+func (mr *MockMetricRepositoryMockRecorder) GetSummary(ctx, name interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSummary", reflect.TypeOf((*MockMetricRepository)(nil).GetSummary), ctx, name)
+}
+
+// GetUpdatedAt mocks base method.
+func (m *MockMetricRepository) GetUpdatedAt(ctx context.Context, metricType, name string) (time.Time, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUpdatedAt", ctx, metricType, name)
+	ret0, _ := ret[0].(time.Time)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUpdatedAt indicates an expected call of GetUpdatedAt.
+func (mr *MockMetricRepositoryMockRecorder) GetUpdatedAt(ctx, metricType, name interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUpdatedAt", reflect.TypeOf((*MockMetricRepository)(nil).GetUpdatedAt), ctx, metricType, name)
+}
+
+// List mocks base method.
+func (m *MockMetricRepository) List(ctx context.Context, opts repository.ListOptions) (*repository.ListPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List", ctx, opts)
+	ret0, _ := ret[0].(*repository.ListPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// List indicates an expected call of List.
+func (mr *MockMetricRepositoryMockRecorder) List(ctx, opts interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockMetricRepository)(nil).List), ctx, opts)
+}
+
+// ResetCounter mocks base method.
+func (m *MockMetricRepository) ResetCounter(ctx context.Context, name string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResetCounter", ctx, name)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ResetCounter indicates an expected call of ResetCounter.
+func (mr *MockMetricRepositoryMockRecorder) ResetCounter(ctx, name interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResetCounter", reflect.TypeOf((*MockMetricRepository)(nil).ResetCounter), ctx, name)
+}
+
+// Restore mocks base method.
+func (m *MockMetricRepository) Restore(ctx context.Context, snapshot repository.Snapshot, mode repository.RestoreMode) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Restore", ctx, snapshot, mode)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Restore indicates an expected call of Restore.
+func (mr *MockMetricRepositoryMockRecorder) Restore(ctx, snapshot, mode interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Restore", reflect.TypeOf((*MockMetricRepository)(nil).Restore), ctx, snapshot, mode)
+}
+
+// SetCounter mocks base method.
+func (m *MockMetricRepository) SetCounter(ctx context.Context, name string, value int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetCounter", ctx, name, value)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetCounter indicates an expected call of SetCounter.
+func (mr *MockMetricRepositoryMockRecorder) SetCounter(ctx, name, value interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetCounter", reflect.TypeOf((*MockMetricRepository)(nil).SetCounter), ctx, name, value)
 }
 
 // UpdateCounter mocks base method.
-func (m *MockMetricRepository) UpdateCounter(name string, value int64) error {
+func (m *MockMetricRepository) UpdateCounter(ctx context.Context, name string, value int64) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdateCounter", name, value)
+	ret := m.ctrl.Call(m, "UpdateCounter", ctx, name, value)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // UpdateCounter indicates an expected call of UpdateCounter.
-func (mr *MockMetricRepositoryMockRecorder) UpdateCounter(name, value interface{}) *gomock.Call {
+func (mr *MockMetricRepositoryMockRecorder) UpdateCounter(ctx, name, value interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateCounter", reflect.TypeOf((*MockMetricRepository)(nil).UpdateCounter), name, value)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateCounter", reflect.TypeOf((*MockMetricRepository)(nil).UpdateCounter), ctx, name, value)
 }
 
 // UpdateGauge mocks base method.
-func (m *MockMetricRepository) UpdateGauge(name string, value float64) error {
+func (m *MockMetricRepository) UpdateGauge(ctx context.Context, name string, value float64) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdateGauge", name, value)
+	ret := m.ctrl.Call(m, "UpdateGauge", ctx, name, value)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // UpdateGauge indicates an expected call of UpdateGauge.
-func (mr *MockMetricRepositoryMockRecorder) UpdateGauge(name, value interface{}) *gomock.Call {
+func (mr *MockMetricRepositoryMockRecorder) UpdateGauge(ctx, name, value interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateGauge", reflect.TypeOf((*MockMetricRepository)(nil).UpdateGauge), ctx, name, value)
+}
+
+// UpdateSet mocks base method.
+func (m *MockMetricRepository) UpdateSet(ctx context.Context, name string, value *sketch.HyperLogLog) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateSet", ctx, name, value)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateSet indicates an expected call of UpdateSet.
+func (mr *MockMetricRepositoryMockRecorder) UpdateSet(ctx, name, value interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateSet", reflect.TypeOf((*MockMetricRepository)(nil).UpdateSet), ctx, name, value)
+}
+
+// UpdateSummary mocks base method.
+func (m *MockMetricRepository) UpdateSummary(ctx context.Context, name string, value *sketch.DDSketch) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateSummary", ctx, name, value)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateSummary indicates an expected call of UpdateSummary.
+func (mr *MockMetricRepositoryMockRecorder) UpdateSummary(ctx, name, value interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateGauge", reflect.TypeOf((*MockMetricRepository)(nil).UpdateGauge), name, value)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateSummary", reflect.TypeOf((*MockMetricRepository)(nil).UpdateSummary), ctx, name, value)
 }
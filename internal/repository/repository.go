@@ -1,53 +1,120 @@
 package repository
 
-import "sync"
+import (
+	"context"
+	"github.com/Guram-Gurych/metricserver.git/internal/model"
+	"github.com/Guram-Gurych/metricserver.git/internal/sketch"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type metricKey struct {
+	metricType string
+	name       string
+}
 
 type MemStorage struct {
-	gauges   map[string]float64
-	counters map[string]int64
-	mu       sync.RWMutex
+	gauges    map[string]float64
+	counters  map[string]int64
+	summaries map[string]*sketch.DDSketch
+	sets      map[string]*sketch.HyperLogLog
+	updated   map[metricKey]time.Time
+	mu        sync.RWMutex
+
+	// index - отсортированные имена по типам для List. Запись о типе удаляется, когда
+	// имя появляется или пропадает, и строится заново при следующем List.
+	index   map[string][]string
+	indexMu sync.Mutex
 }
 
 func NewMemStorage() *MemStorage {
 	return &MemStorage{
-		gauges:   make(map[string]float64),
-		counters: make(map[string]int64),
+		gauges:    make(map[string]float64),
+		counters:  make(map[string]int64),
+		summaries: make(map[string]*sketch.DDSketch),
+		sets:      make(map[string]*sketch.HyperLogLog),
+		updated:   make(map[metricKey]time.Time),
+		index:     make(map[string][]string),
 	}
 }
 
-func (ms *MemStorage) UpdateGauge(name string, value float64) error {
+func (ms *MemStorage) UpdateGauge(_ context.Context, name string, value float64) error {
+	if name == "" {
+		return ErrEmptyName
+	}
+
 	ms.mu.Lock()
 	defer ms.mu.Unlock()
 
 	ms.gauges[name] = value
+	ms.touch(models.Gauge, name)
 	return nil
 }
 
-func (ms *MemStorage) UpdateCounter(name string, value int64) error {
+func (ms *MemStorage) AddGauge(_ context.Context, name string, delta float64) (float64, error) {
+	if name == "" {
+		return 0, ErrEmptyName
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.gauges[name] += delta
+	ms.touch(models.Gauge, name)
+	return ms.gauges[name], nil
+}
+
+func (ms *MemStorage) UpdateCounter(_ context.Context, name string, value int64) error {
+	if name == "" {
+		return ErrEmptyName
+	}
+
 	ms.mu.Lock()
 	defer ms.mu.Unlock()
 
 	ms.counters[name] += value
+	ms.touch(models.Counter, name)
 	return nil
 }
 
-func (ms *MemStorage) GetGauge(name string) (float64, bool) {
+func (ms *MemStorage) SetCounter(_ context.Context, name string, value int64) error {
+	if name == "" {
+		return ErrEmptyName
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.counters[name] = value
+	ms.touch(models.Counter, name)
+	return nil
+}
+
+func (ms *MemStorage) GetGauge(_ context.Context, name string) (float64, error) {
 	ms.mu.RLock()
 	defer ms.mu.RUnlock()
 
 	val, ok := ms.gauges[name]
-	return val, ok
+	if !ok {
+		return 0, ErrNotFound
+	}
+	return val, nil
 }
 
-func (ms *MemStorage) GetCounter(name string) (int64, bool) {
+func (ms *MemStorage) GetCounter(_ context.Context, name string) (int64, error) {
 	ms.mu.RLock()
 	defer ms.mu.RUnlock()
 
 	val, ok := ms.counters[name]
-	return val, ok
+	if !ok {
+		return 0, ErrNotFound
+	}
+	return val, nil
 }
 
-func (ms *MemStorage) GetAllGauges() map[string]float64 {
+func (ms *MemStorage) GetAllGauges(_ context.Context) (map[string]float64, error) {
 	ms.mu.RLock()
 	defer ms.mu.RUnlock()
 
@@ -56,10 +123,10 @@ func (ms *MemStorage) GetAllGauges() map[string]float64 {
 		result[k] = v
 	}
 
-	return result
+	return result, nil
 }
 
-func (ms *MemStorage) GetAllCounters() map[string]int64 {
+func (ms *MemStorage) GetAllCounters(_ context.Context) (map[string]int64, error) {
 	ms.mu.RLock()
 	defer ms.mu.RUnlock()
 
@@ -68,5 +135,359 @@ func (ms *MemStorage) GetAllCounters() map[string]int64 {
 		result[k] = v
 	}
 
-	return result
+	return result, nil
+}
+
+func (ms *MemStorage) UpdateSummary(_ context.Context, name string, value *sketch.DDSketch) error {
+	if name == "" {
+		return ErrEmptyName
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	current, ok := ms.summaries[name]
+	if !ok {
+		ms.summaries[name] = value.Copy()
+		ms.touch(models.Summary, name)
+		return nil
+	}
+
+	merged := current.Copy()
+	if err := merged.Merge(value); err != nil {
+		return err
+	}
+	ms.summaries[name] = merged
+	ms.touch(models.Summary, name)
+
+	return nil
+}
+
+func (ms *MemStorage) GetSummary(_ context.Context, name string) (*sketch.DDSketch, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	val, ok := ms.summaries[name]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return val.Copy(), nil
+}
+
+func (ms *MemStorage) GetAllSummaries(_ context.Context) (map[string]*sketch.DDSketch, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	result := make(map[string]*sketch.DDSketch, len(ms.summaries))
+	for k, v := range ms.summaries {
+		result[k] = v.Copy()
+	}
+
+	return result, nil
+}
+
+func (ms *MemStorage) UpdateSet(_ context.Context, name string, value *sketch.HyperLogLog) error {
+	if name == "" {
+		return ErrEmptyName
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	current, ok := ms.sets[name]
+	if !ok {
+		current = sketch.NewHyperLogLog(value.Precision)
+	}
+
+	merged := current.Copy()
+	if err := merged.Merge(value); err != nil {
+		return err
+	}
+	ms.sets[name] = merged
+	ms.touch(models.Set, name)
+
+	return nil
+}
+
+func (ms *MemStorage) GetSet(_ context.Context, name string) (*sketch.HyperLogLog, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	val, ok := ms.sets[name]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return val.Copy(), nil
+}
+
+func (ms *MemStorage) GetAllSets(_ context.Context) (map[string]*sketch.HyperLogLog, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	result := make(map[string]*sketch.HyperLogLog, len(ms.sets))
+	for k, v := range ms.sets {
+		result[k] = v.Copy()
+	}
+
+	return result, nil
+}
+
+func (ms *MemStorage) DeleteGauge(_ context.Context, name string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if _, ok := ms.gauges[name]; !ok {
+		return ErrNotFound
+	}
+	delete(ms.gauges, name)
+	ms.forget(models.Gauge, name)
+	return nil
+}
+
+func (ms *MemStorage) DeleteCounter(_ context.Context, name string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if _, ok := ms.counters[name]; !ok {
+		return ErrNotFound
+	}
+	delete(ms.counters, name)
+	ms.forget(models.Counter, name)
+	return nil
+}
+
+func (ms *MemStorage) ResetCounter(_ context.Context, name string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if _, ok := ms.counters[name]; !ok {
+		return ErrNotFound
+	}
+	ms.counters[name] = 0
+	ms.touch(models.Counter, name)
+	return nil
+}
+
+func (ms *MemStorage) DeleteSummary(_ context.Context, name string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if _, ok := ms.summaries[name]; !ok {
+		return ErrNotFound
+	}
+	delete(ms.summaries, name)
+	ms.forget(models.Summary, name)
+	return nil
+}
+
+func (ms *MemStorage) DeleteSet(_ context.Context, name string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if _, ok := ms.sets[name]; !ok {
+		return ErrNotFound
+	}
+	delete(ms.sets, name)
+	ms.forget(models.Set, name)
+	return nil
+}
+
+func (ms *MemStorage) GetUpdatedAt(_ context.Context, metricType, name string) (time.Time, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	val, ok := ms.updated[metricKey{metricType, name}]
+	if !ok {
+		return time.Time{}, ErrNotFound
+	}
+	return val, nil
+}
+
+// EvictStale удаляет все метрики, которые не обновлялись с момента before.
+func (ms *MemStorage) EvictStale(_ context.Context, before time.Time) (int, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	evicted := 0
+	for key, updatedAt := range ms.updated {
+		if !updatedAt.Before(before) {
+			continue
+		}
+
+		switch key.metricType {
+		case models.Gauge:
+			delete(ms.gauges, key.name)
+		case models.Counter:
+			delete(ms.counters, key.name)
+		case models.Summary:
+			delete(ms.summaries, key.name)
+		case models.Set:
+			delete(ms.sets, key.name)
+		}
+		ms.forget(key.metricType, key.name)
+		evicted++
+	}
+
+	return evicted, nil
+}
+
+func (ms *MemStorage) Restore(_ context.Context, snapshot Snapshot, mode RestoreMode) error {
+	if _, err := ParseRestoreMode(string(mode)); err != nil {
+		return err
+	}
+	if err := snapshot.validate(); err != nil {
+		return err
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	clear(ms.index)
+	if mode == RestoreReplace {
+		ms.gauges = make(map[string]float64, len(snapshot.Gauges))
+		ms.counters = make(map[string]int64, len(snapshot.Counters))
+		ms.summaries = make(map[string]*sketch.DDSketch, len(snapshot.Summaries))
+		ms.sets = make(map[string]*sketch.HyperLogLog, len(snapshot.Sets))
+		ms.updated = make(map[metricKey]time.Time)
+	}
+
+	now := time.Now()
+	for name, value := range snapshot.Gauges {
+		ms.gauges[name] = value
+		ms.updated[metricKey{models.Gauge, name}] = snapshot.updatedAt(models.Gauge, name, now)
+	}
+	for name, value := range snapshot.Counters {
+		ms.counters[name] = value
+		ms.updated[metricKey{models.Counter, name}] = snapshot.updatedAt(models.Counter, name, now)
+	}
+	for name, value := range snapshot.Summaries {
+		ms.summaries[name] = value.Copy()
+		ms.updated[metricKey{models.Summary, name}] = snapshot.updatedAt(models.Summary, name, now)
+	}
+	for name, value := range snapshot.Sets {
+		ms.sets[name] = value.Copy()
+		ms.updated[metricKey{models.Set, name}] = snapshot.updatedAt(models.Set, name, now)
+	}
+
+	return nil
+}
+
+func (ms *MemStorage) List(_ context.Context, opts ListOptions) (*ListPage, error) {
+	q, err := parseListOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	page := &ListPage{}
+	for _, idx := range q.types() {
+		for _, name := range ms.namesFrom(q, idx) {
+			if !strings.HasPrefix(name, q.Prefix) {
+				break
+			}
+			if !q.matches(listPosition{typeIndex: idx, name: name}) {
+				continue
+			}
+			if q.full(page) {
+				return page, nil
+			}
+			page.Metrics = append(page.Metrics, ms.metricLocked(MetricTypes[idx], name))
+		}
+	}
+
+	return page, nil
+}
+
+// namesFrom возвращает отсортированные имена типа, начиная с первого, которое может
+// попасть в выборку. Срез общий с индексом и не должен изменяться.
+// Вызывается под блокировкой на чтение.
+func (ms *MemStorage) namesFrom(q listQuery, idx int) []string {
+	names := ms.sortedNames(MetricTypes[idx])
+
+	start := q.Prefix
+	if q.hasAfter && q.after.typeIndex == idx && q.after.name > start {
+		start = q.after.name
+	}
+
+	return names[sort.SearchStrings(names, start):]
+}
+
+// metric возвращает метрику по позиции, если она ещё есть в хранилище.
+func (ms *MemStorage) metric(pos listPosition) (models.Metrics, bool) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	metricType := MetricTypes[pos.typeIndex]
+	if _, ok := ms.updated[metricKey{metricType, pos.name}]; !ok {
+		return models.Metrics{}, false
+	}
+	return ms.metricLocked(metricType, pos.name), true
+}
+
+// metricLocked вызывается под блокировкой на чтение.
+func (ms *MemStorage) metricLocked(metricType, name string) models.Metrics {
+	m := models.Metrics{ID: name, MType: metricType}
+	if updatedAt, ok := ms.updated[metricKey{metricType, name}]; ok {
+		m.UpdatedAt = &updatedAt
+	}
+	switch metricType {
+	case models.Gauge:
+		value := ms.gauges[name]
+		m.Value = &value
+	case models.Counter:
+		delta := ms.counters[name]
+		m.Delta = &delta
+	case models.Summary:
+		m.Sketch = ms.summaries[name].Copy()
+	case models.Set:
+		m.HLL = ms.sets[name].Copy()
+	}
+
+	return m
+}
+
+// sortedNames вызывается под блокировкой на чтение. Индекс перестраивается, только
+// если с прошлого List имена менялись, поэтому обход через Each не сортирует
+// хранилище заново на каждой странице.
+func (ms *MemStorage) sortedNames(metricType string) []string {
+	ms.indexMu.Lock()
+	defer ms.indexMu.Unlock()
+
+	if names, ok := ms.index[metricType]; ok {
+		return names
+	}
+
+	var names []string
+	switch metricType {
+	case models.Gauge:
+		names = sortedKeys(ms.gauges)
+	case models.Counter:
+		names = sortedKeys(ms.counters)
+	case models.Summary:
+		names = sortedKeys(ms.summaries)
+	case models.Set:
+		names = sortedKeys(ms.sets)
+	}
+	ms.index[metricType] = names
+
+	return names
+}
+
+// touch вызывается под блокировкой на запись.
+func (ms *MemStorage) touch(metricType, name string) {
+	key := metricKey{metricType, name}
+	if _, ok := ms.updated[key]; !ok {
+		delete(ms.index, metricType)
+	}
+	ms.updated[key] = time.Now()
+}
+
+// forget вызывается под блокировкой на запись после удаления метрики.
+func (ms *MemStorage) forget(metricType, name string) {
+	delete(ms.updated, metricKey{metricType, name})
+	delete(ms.index, metricType)
 }
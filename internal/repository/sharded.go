@@ -0,0 +1,280 @@
+package repository
+
+import (
+	"context"
+	"github.com/Guram-Gurych/metricserver.git/internal/sketch"
+	"strings"
+	"time"
+)
+
+const DefaultShards = 32
+
+// ShardedStorage раскладывает метрики по нескольким MemStorage по хешу имени.
+// У каждого шарда своя блокировка, поэтому обновления разных метрик не ждут друг друга.
+// Операции над всем хранилищем (GetAll*, EvictStale, Restore) проходят шарды по очереди
+// и не атомарны относительно параллельных записей.
+type ShardedStorage struct {
+	shards []*MemStorage
+}
+
+func NewShardedStorage(shards int) *ShardedStorage {
+	if shards <= 0 {
+		shards = DefaultShards
+	}
+
+	s := &ShardedStorage{shards: make([]*MemStorage, shards)}
+	for i := range s.shards {
+		s.shards[i] = NewMemStorage()
+	}
+
+	return s
+}
+
+func (s *ShardedStorage) shard(name string) *MemStorage {
+	return s.shards[s.shardIndex(name)]
+}
+
+func (s *ShardedStorage) UpdateGauge(ctx context.Context, name string, value float64) error {
+	return s.shard(name).UpdateGauge(ctx, name, value)
+}
+
+func (s *ShardedStorage) AddGauge(ctx context.Context, name string, delta float64) (float64, error) {
+	return s.shard(name).AddGauge(ctx, name, delta)
+}
+
+func (s *ShardedStorage) UpdateCounter(ctx context.Context, name string, value int64) error {
+	return s.shard(name).UpdateCounter(ctx, name, value)
+}
+
+func (s *ShardedStorage) SetCounter(ctx context.Context, name string, value int64) error {
+	return s.shard(name).SetCounter(ctx, name, value)
+}
+
+func (s *ShardedStorage) GetGauge(ctx context.Context, name string) (float64, error) {
+	return s.shard(name).GetGauge(ctx, name)
+}
+
+func (s *ShardedStorage) GetCounter(ctx context.Context, name string) (int64, error) {
+	return s.shard(name).GetCounter(ctx, name)
+}
+
+func (s *ShardedStorage) GetAllGauges(ctx context.Context) (map[string]float64, error) {
+	result := make(map[string]float64)
+	for _, shard := range s.shards {
+		gauges, err := shard.GetAllGauges(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range gauges {
+			result[k] = v
+		}
+	}
+
+	return result, nil
+}
+
+func (s *ShardedStorage) GetAllCounters(ctx context.Context) (map[string]int64, error) {
+	result := make(map[string]int64)
+	for _, shard := range s.shards {
+		counters, err := shard.GetAllCounters(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range counters {
+			result[k] = v
+		}
+	}
+
+	return result, nil
+}
+
+func (s *ShardedStorage) UpdateSummary(ctx context.Context, name string, value *sketch.DDSketch) error {
+	return s.shard(name).UpdateSummary(ctx, name, value)
+}
+
+func (s *ShardedStorage) GetSummary(ctx context.Context, name string) (*sketch.DDSketch, error) {
+	return s.shard(name).GetSummary(ctx, name)
+}
+
+func (s *ShardedStorage) GetAllSummaries(ctx context.Context) (map[string]*sketch.DDSketch, error) {
+	result := make(map[string]*sketch.DDSketch)
+	for _, shard := range s.shards {
+		summaries, err := shard.GetAllSummaries(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range summaries {
+			result[k] = v
+		}
+	}
+
+	return result, nil
+}
+
+func (s *ShardedStorage) UpdateSet(ctx context.Context, name string, value *sketch.HyperLogLog) error {
+	return s.shard(name).UpdateSet(ctx, name, value)
+}
+
+func (s *ShardedStorage) GetSet(ctx context.Context, name string) (*sketch.HyperLogLog, error) {
+	return s.shard(name).GetSet(ctx, name)
+}
+
+func (s *ShardedStorage) GetAllSets(ctx context.Context) (map[string]*sketch.HyperLogLog, error) {
+	result := make(map[string]*sketch.HyperLogLog)
+	for _, shard := range s.shards {
+		sets, err := shard.GetAllSets(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range sets {
+			result[k] = v
+		}
+	}
+
+	return result, nil
+}
+
+func (s *ShardedStorage) DeleteGauge(ctx context.Context, name string) error {
+	return s.shard(name).DeleteGauge(ctx, name)
+}
+
+func (s *ShardedStorage) DeleteCounter(ctx context.Context, name string) error {
+	return s.shard(name).DeleteCounter(ctx, name)
+}
+
+func (s *ShardedStorage) ResetCounter(ctx context.Context, name string) error {
+	return s.shard(name).ResetCounter(ctx, name)
+}
+
+func (s *ShardedStorage) DeleteSummary(ctx context.Context, name string) error {
+	return s.shard(name).DeleteSummary(ctx, name)
+}
+
+func (s *ShardedStorage) DeleteSet(ctx context.Context, name string) error {
+	return s.shard(name).DeleteSet(ctx, name)
+}
+
+func (s *ShardedStorage) GetUpdatedAt(ctx context.Context, metricType, name string) (time.Time, error) {
+	return s.shard(name).GetUpdatedAt(ctx, metricType, name)
+}
+
+func (s *ShardedStorage) EvictStale(ctx context.Context, before time.Time) (int, error) {
+	evicted := 0
+	for _, shard := range s.shards {
+		n, err := shard.EvictStale(ctx, before)
+		if err != nil {
+			return evicted, err
+		}
+		evicted += n
+	}
+
+	return evicted, nil
+}
+
+// List собирает страницу с каждого шарда и сливает их в общем порядке обхода.
+func (s *ShardedStorage) List(ctx context.Context, opts ListOptions) (*ListPage, error) {
+	q, err := parseListOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Шарды сливаются по отсортированным индексам имён: значения и копии скетчей
+	// берутся только для метрик, которые попадут на страницу.
+	page := &ListPage{}
+	heads := make([][]string, len(s.shards))
+	for _, idx := range q.types() {
+		for i, shard := range s.shards {
+			shard.mu.RLock()
+			heads[i] = shard.namesFrom(q, idx)
+			shard.mu.RUnlock()
+		}
+
+		for {
+			best := -1
+			for i, head := range heads {
+				if len(head) == 0 {
+					continue
+				}
+				if !strings.HasPrefix(head[0], q.Prefix) {
+					heads[i] = nil
+					continue
+				}
+				if best < 0 || head[0] < heads[best][0] {
+					best = i
+				}
+			}
+			if best < 0 {
+				break
+			}
+
+			pos := listPosition{typeIndex: idx, name: heads[best][0]}
+			heads[best] = heads[best][1:]
+			if !q.matches(pos) {
+				continue
+			}
+			if q.full(page) {
+				return page, nil
+			}
+			// Метрику могли удалить после того, как был взят индекс.
+			if m, ok := s.shards[best].metric(pos); ok {
+				page.Metrics = append(page.Metrics, m)
+			}
+		}
+	}
+
+	return page, nil
+}
+
+// Restore делит снимок по шардам и восстанавливает каждый шард своей частью.
+func (s *ShardedStorage) Restore(ctx context.Context, snapshot Snapshot, mode RestoreMode) error {
+	if _, err := ParseRestoreMode(string(mode)); err != nil {
+		return err
+	}
+	// Проверка до первого шарда: иначе replace успел бы очистить часть шардов.
+	if err := snapshot.validate(); err != nil {
+		return err
+	}
+
+	parts := make([]Snapshot, len(s.shards))
+	for i := range parts {
+		parts[i] = Snapshot{
+			Gauges:    make(map[string]float64),
+			Counters:  make(map[string]int64),
+			Summaries: make(map[string]*sketch.DDSketch),
+			Sets:      make(map[string]*sketch.HyperLogLog),
+			UpdatedAt: snapshot.UpdatedAt,
+		}
+	}
+
+	for name, value := range snapshot.Gauges {
+		parts[s.shardIndex(name)].Gauges[name] = value
+	}
+	for name, value := range snapshot.Counters {
+		parts[s.shardIndex(name)].Counters[name] = value
+	}
+	for name, value := range snapshot.Summaries {
+		parts[s.shardIndex(name)].Summaries[name] = value
+	}
+	for name, value := range snapshot.Sets {
+		parts[s.shardIndex(name)].Sets[name] = value
+	}
+
+	for i, shard := range s.shards {
+		if err := shard.Restore(ctx, parts[i], mode); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// shardIndex считает FNV-1a вручную: hash/fnv аллоцирует на каждый вызов, а это горячий путь.
+func (s *ShardedStorage) shardIndex(name string) int {
+	h := uint32(2166136261)
+	for i := 0; i < len(name); i++ {
+		h ^= uint32(name[i])
+		h *= 16777619
+	}
+
+	return int(h % uint32(len(s.shards)))
+}
@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"github.com/Guram-Gurych/metricserver.git/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestShardedStorage_ConcurrentUpdates(t *testing.T) {
+	ctx := context.Background()
+	storage := NewShardedStorage(8)
+
+	const workers, updates = 16, 1000
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < updates; i++ {
+				assert.NoError(t, storage.UpdateCounter(ctx, fmt.Sprintf("counter%d", i%10), 1))
+				_, err := storage.AddGauge(ctx, fmt.Sprintf("gauge%d", i%10), 1)
+				assert.NoError(t, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	counters, err := storage.GetAllCounters(ctx)
+	require.NoError(t, err)
+	require.Len(t, counters, 10)
+	for name, value := range counters {
+		assert.Equal(t, int64(workers*updates/10), value, "Потеряны обновления counter %s", name)
+	}
+
+	gauges, err := storage.GetAllGauges(ctx)
+	require.NoError(t, err)
+	require.Len(t, gauges, 10)
+	for name, value := range gauges {
+		assert.Equal(t, float64(workers*updates/10), value, "Потеряны прибавления к gauge %s", name)
+	}
+}
+
+func TestShardedStorage_RestoreAndEvict(t *testing.T) {
+	ctx := context.Background()
+	storage := NewShardedStorage(4)
+	require.NoError(t, storage.UpdateGauge(ctx, "Old", 1))
+
+	hourAgo := time.Now().Add(-time.Hour)
+	snapshot := Snapshot{
+		Gauges:    map[string]float64{"Alloc": 1, "HeapSys": 2, "Sys": 3},
+		Counters:  map[string]int64{"PollCount": 5},
+		UpdatedAt: map[string]map[string]time.Time{models.Gauge: {"Alloc": hourAgo}},
+	}
+	require.NoError(t, storage.Restore(ctx, snapshot, RestoreReplace))
+
+	got, err := TakeSnapshot(ctx, storage)
+	require.NoError(t, err)
+	assert.Equal(t, snapshot.Gauges, got.Gauges)
+	assert.Equal(t, snapshot.Counters, got.Counters)
+
+	evicted, err := storage.EvictStale(ctx, time.Now().Add(-time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, 1, evicted, "Время обновления из снимка должно сохраняться в шардах")
+
+	evicted, err = storage.EvictStale(ctx, time.Now().Add(time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, 3, evicted)
+}
+
+// Запуск: go test -run=^$ -bench=UpdateCounter -cpu=1,8,32 ./internal/repository
+// BenchmarkUpdateCounter сравнивает пропускную способность хранилищ при параллельной записи:
+// число писателей растёт, а записи разнесены по многим именам, как у агентов разных хостов.
+func BenchmarkUpdateCounter(b *testing.B) {
+	storages := []struct {
+		name string
+		repo func() MetricRepository
+	}{
+		{name: "MemStorage", repo: func() MetricRepository { return NewMemStorage() }},
+		{name: "ShardedStorage", repo: func() MetricRepository { return NewShardedStorage(DefaultShards) }},
+	}
+
+	names := make([]string, 10000)
+	for i := range names {
+		names[i] = fmt.Sprintf("counter%d", i)
+	}
+
+	for _, storage := range storages {
+		for _, parallelism := range []int{1, 4, 16} {
+			writers := parallelism * runtime.GOMAXPROCS(0)
+			b.Run(fmt.Sprintf("%s/writers=%d", storage.name, writers), func(b *testing.B) {
+				ctx := context.Background()
+				repo := storage.repo()
+				var next atomic.Uint64
+
+				b.SetParallelism(parallelism)
+				b.ResetTimer()
+				b.RunParallel(func(pb *testing.PB) {
+					// Каждый писатель начинает со своего места, чтобы писатели не шли по именам строем.
+					i := next.Add(1) * 7919
+					for pb.Next() {
+						if err := repo.UpdateCounter(ctx, names[i%uint64(len(names))], 1); err != nil {
+							b.Error(err)
+							return
+						}
+						i++
+					}
+				})
+				b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "writes/s")
+			})
+		}
+	}
+}
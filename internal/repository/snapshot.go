@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Guram-Gurych/metricserver.git/internal/model"
+	"github.com/Guram-Gurych/metricserver.git/internal/sketch"
+	"time"
+)
+
+var ErrInvalidRestoreMode = errors.New("invalid restore mode")
+
+type RestoreMode string
+
+const (
+	// RestoreReplace очищает хранилище и загружает в него снимок целиком.
+	RestoreReplace RestoreMode = "replace"
+	// RestoreMerge перезаписывает метрики из снимка и оставляет остальные нетронутыми.
+	RestoreMerge RestoreMode = "merge"
+)
+
+func ParseRestoreMode(mode string) (RestoreMode, error) {
+	switch RestoreMode(mode) {
+	case RestoreReplace, RestoreMerge:
+		return RestoreMode(mode), nil
+	}
+
+	return "", ErrInvalidRestoreMode
+}
+
+// Snapshot - полное состояние хранилища. Повторная загрузка одного и того же снимка
+// через Restore не меняет результат, в отличие от UpdateCounter.
+type Snapshot struct {
+	Gauges    map[string]float64             `json:"gauges"`
+	Counters  map[string]int64               `json:"counters"`
+	Summaries map[string]*sketch.DDSketch    `json:"summaries,omitempty"`
+	Sets      map[string]*sketch.HyperLogLog `json:"sets,omitempty"`
+	// UpdatedAt - время последнего обновления по типу и имени метрики, чтобы TTL
+	// отсчитывался и после перезапуска. Метрики без времени получают время восстановления.
+	UpdatedAt map[string]map[string]time.Time `json:"updated_at,omitempty"`
+}
+
+// validate проверяет снимок до восстановления: у всех метрик есть имя, а скетчи
+// не пусты и целы. Снимок из файла мог быть повреждён, и пустой скетч иначе
+// привёл бы к панике при копировании.
+func (s Snapshot) validate() error {
+	_, gauge := s.Gauges[""]
+	_, counter := s.Counters[""]
+	_, summary := s.Summaries[""]
+	_, set := s.Sets[""]
+	if gauge || counter || summary || set {
+		return ErrEmptyName
+	}
+
+	for name, value := range s.Summaries {
+		if err := value.Validate(); err != nil {
+			return fmt.Errorf("summary %q: %w", name, err)
+		}
+	}
+	for name, value := range s.Sets {
+		if err := value.Validate(); err != nil {
+			return fmt.Errorf("set %q: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// updatedAt возвращает сохранённое в снимке время обновления метрики или now.
+func (s Snapshot) updatedAt(metricType, name string, now time.Time) time.Time {
+	if t, ok := s.UpdatedAt[metricType][name]; ok && !t.IsZero() {
+		return t
+	}
+	return now
+}
+
+// TakeSnapshot собирает полное состояние любого хранилища через его интерфейс.
+// Хранилище обходится постранично через List, а не копируется целиком под одной блокировкой.
+func TakeSnapshot(ctx context.Context, repo MetricRepository) (*Snapshot, error) {
+	snapshot := &Snapshot{
+		Gauges:    make(map[string]float64),
+		Counters:  make(map[string]int64),
+		Summaries: make(map[string]*sketch.DDSketch),
+		Sets:      make(map[string]*sketch.HyperLogLog),
+		UpdatedAt: make(map[string]map[string]time.Time),
+	}
+
+	err := Each(ctx, repo, ListOptions{}, func(m models.Metrics) error {
+		if m.UpdatedAt != nil {
+			if snapshot.UpdatedAt[m.MType] == nil {
+				snapshot.UpdatedAt[m.MType] = make(map[string]time.Time)
+			}
+			snapshot.UpdatedAt[m.MType][m.ID] = *m.UpdatedAt
+		}
+
+		switch m.MType {
+		case models.Gauge:
+			snapshot.Gauges[m.ID] = *m.Value
+		case models.Counter:
+			snapshot.Counters[m.ID] = *m.Delta
+		case models.Summary:
+			snapshot.Summaries[m.ID] = m.Sketch
+		case models.Set:
+			snapshot.Sets[m.ID] = m.HLL
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return snapshot, nil
+}
@@ -0,0 +1,240 @@
+package scrape
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Guram-Gurych/metricserver.git/internal/agent"
+	"github.com/Guram-Gurych/metricserver.git/internal/ingest"
+	"github.com/Guram-Gurych/metricserver.git/internal/repository"
+	"go.uber.org/zap"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// UpMetric - gauge со значением 1 или 0 для каждой цели, как up в Prometheus.
+const UpMetric = "scrape_up"
+
+var (
+	ErrInvalidTarget   = errors.New("invalid scrape target")
+	ErrInvalidInterval = errors.New("scrape interval must be positive")
+)
+
+// TargetHealth - состояние цели после последнего опроса.
+type TargetHealth struct {
+	URL                 string        `json:"url"`
+	Up                  bool          `json:"up"`
+	LastScrape          *time.Time    `json:"last_scrape,omitempty"`
+	LastDuration        time.Duration `json:"last_duration_ns"`
+	LastError           string        `json:"last_error,omitempty"`
+	ConsecutiveFailures int           `json:"consecutive_failures"`
+}
+
+type target struct {
+	url    string
+	health TargetHealth
+	// counters - последние значения counter-ов агента; в хранилище пишется прирост.
+	counters map[string]int64
+}
+
+// Scraper опрашивает агентов в pull-режиме и пишет их метрики в хранилище так же,
+// как если бы агенты отправили их сами: gauge заменяется, counter увеличивается на
+// прирост с прошлого опроса. Если counter агента уменьшился, агент перезапущен,
+// и прирост - всё текущее значение. Первый опрос цели только запоминает значения:
+// после перезапуска сервера counter-ы уже восстановлены из файла, и полное значение
+// агента учлось бы повторно.
+type Scraper struct {
+	repo     repository.MetricRepository
+	client   *http.Client
+	interval time.Duration
+	token    string
+	logger   *zap.Logger
+
+	mu      sync.Mutex
+	targets []*target
+}
+
+// NewScraper разбирает цели: host:port или полный URL; без пути опрашивается /metrics.
+func NewScraper(repo repository.MetricRepository, targets []string, interval time.Duration, logger *zap.Logger) (*Scraper, error) {
+	if interval <= 0 {
+		return nil, ErrInvalidInterval
+	}
+
+	s := &Scraper{repo: repo, client: &http.Client{Timeout: interval}, interval: interval, logger: logger}
+	for _, raw := range targets {
+		u, err := targetURL(raw)
+		if err != nil {
+			return nil, err
+		}
+		s.targets = append(s.targets, &target{url: u, health: TargetHealth{URL: u}, counters: make(map[string]int64)})
+	}
+
+	return s, nil
+}
+
+// SetToken задаёт токен, который агенты требуют при чтении метрик.
+func (s *Scraper) SetToken(token string) {
+	s.token = token
+}
+
+// Run опрашивает цели каждые interval, пока ctx не отменят.
+func (s *Scraper) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		s.ScrapeOnce(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ScrapeOnce опрашивает все цели параллельно и ждёт завершения.
+func (s *Scraper) ScrapeOnce(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, t := range s.targets {
+		wg.Add(1)
+		go func(t *target) {
+			defer wg.Done()
+			s.scrape(ctx, t)
+		}(t)
+	}
+	wg.Wait()
+}
+
+// Health возвращает состояние целей, отсортированное по URL.
+func (s *Scraper) Health() []TargetHealth {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	health := make([]TargetHealth, 0, len(s.targets))
+	for _, t := range s.targets {
+		health = append(health, t.health)
+	}
+	sort.Slice(health, func(i, j int) bool { return health[i].URL < health[j].URL })
+
+	return health
+}
+
+func (s *Scraper) scrape(ctx context.Context, t *target) {
+	start := time.Now()
+	err := s.scrapeTarget(ctx, t)
+
+	s.mu.Lock()
+	t.health.LastScrape = &start
+	t.health.LastDuration = time.Since(start)
+	t.health.Up = err == nil
+	if err != nil {
+		t.health.LastError = err.Error()
+		t.health.ConsecutiveFailures++
+	} else {
+		t.health.LastError = ""
+		t.health.ConsecutiveFailures = 0
+	}
+	failures := t.health.ConsecutiveFailures
+	s.mu.Unlock()
+
+	up := 0.0
+	if err == nil {
+		up = 1
+	} else if failures == 1 {
+		s.logger.Warn("Scrape target is down", zap.String("target", t.url), zap.Error(err))
+	}
+	if err := s.repo.UpdateGauge(ctx, ingest.Name(UpMetric, map[string]string{"target": t.url}), up); err != nil {
+		s.logger.Error("Failed to record scrape health", zap.String("target", t.url), zap.Error(err))
+	}
+}
+
+func (s *Scraper) scrapeTarget(ctx context.Context, t *target) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, t.url, nil)
+	if err != nil {
+		return err
+	}
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	var snapshot agent.AgentMetric
+	if err := json.NewDecoder(resp.Body).Decode(&snapshot); err != nil {
+		return fmt.Errorf("decode snapshot: %w", err)
+	}
+
+	for name, value := range snapshot.Gauges {
+		if err := s.repo.UpdateGauge(ctx, name, value); err != nil {
+			return err
+		}
+	}
+
+	for name, value := range snapshot.Counters {
+		delta := s.counterDelta(t, name, value)
+		if delta == 0 {
+			continue
+		}
+		if err := s.repo.UpdateCounter(ctx, name, delta); err != nil {
+			return err
+		}
+		s.commitCounter(t, name, value)
+	}
+
+	return nil
+}
+
+func (s *Scraper) counterDelta(t *target, name string, value int64) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prev, ok := t.counters[name]
+	if !ok {
+		t.counters[name] = value
+		return 0
+	}
+	if value < prev {
+		return value
+	}
+
+	return value - prev
+}
+
+// commitCounter запоминает значение только после успешной записи, чтобы прирост не потерялся.
+func (s *Scraper) commitCounter(t *target, name string, value int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t.counters[name] = value
+}
+
+func targetURL(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if !strings.Contains(raw, "://") {
+		raw = "http://" + raw
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return "", fmt.Errorf("%w: %q", ErrInvalidTarget, raw)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = "/metrics"
+	}
+
+	return u.String(), nil
+}
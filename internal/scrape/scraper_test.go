@@ -0,0 +1,136 @@
+package scrape
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/Guram-Gurych/metricserver.git/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTargetURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		raw      string
+		expected string
+		wantErr  bool
+	}{
+		{name: "host:port", raw: "agent1:9101", expected: "http://agent1:9101/metrics"},
+		{name: "URL без пути", raw: "https://agent1:9101/", expected: "https://agent1:9101/metrics"},
+		{name: "URL с путём", raw: "http://agent1/custom", expected: "http://agent1/custom"},
+		{name: "Неизвестная схема", raw: "ftp://agent1", wantErr: true},
+		{name: "Без хоста", raw: "http://", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			u, err := targetURL(test.raw)
+			if test.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidTarget)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, u)
+		})
+	}
+}
+
+func TestScraper_ScrapeOnce(t *testing.T) {
+	var mu sync.Mutex
+	pollCount := int64(5)
+	agentUp := true
+
+	agent := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if !agentUp {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"gauges":   map[string]float64{"Alloc": 42},
+			"counters": map[string]int64{"PollCount": pollCount},
+		})
+	}))
+	defer agent.Close()
+
+	ctx := context.Background()
+	repo := repository.NewMemStorage()
+	scraper, err := NewScraper(repo, []string{agent.URL}, time.Second, zap.NewNop())
+	require.NoError(t, err)
+	scraper.SetToken("secret")
+
+	upName := UpMetric + `{target="` + agent.URL + `/metrics"}`
+	scrapeAndCheck := func(expectedCounter int64, expectedUp float64) {
+		t.Helper()
+		scraper.ScrapeOnce(ctx)
+
+		counter, err := repo.GetCounter(ctx, "PollCount")
+		require.NoError(t, err)
+		assert.Equal(t, expectedCounter, counter)
+
+		up, err := repo.GetGauge(ctx, upName)
+		require.NoError(t, err)
+		assert.Equal(t, expectedUp, up)
+	}
+
+	scraper.ScrapeOnce(ctx)
+	_, err = repo.GetCounter(ctx, "PollCount")
+	assert.ErrorIs(t, err, repository.ErrNotFound, "Первый опрос только запоминает значение counter-а")
+
+	gauge, err := repo.GetGauge(ctx, "Alloc")
+	require.NoError(t, err)
+	assert.Equal(t, float64(42), gauge)
+
+	mu.Lock()
+	pollCount = 8
+	mu.Unlock()
+	scrapeAndCheck(3, 1)
+
+	mu.Lock()
+	agentUp = false
+	mu.Unlock()
+	scrapeAndCheck(3, 0)
+
+	health := scraper.Health()
+	require.Len(t, health, 1)
+	assert.False(t, health[0].Up)
+	assert.Equal(t, 1, health[0].ConsecutiveFailures)
+	assert.True(t, strings.Contains(health[0].LastError, "503"))
+
+	mu.Lock()
+	agentUp, pollCount = true, 2
+	mu.Unlock()
+	scrapeAndCheck(5, 1)
+	assert.Equal(t, 0, scraper.Health()[0].ConsecutiveFailures, "После успешного опроса счётчик ошибок должен сбрасываться")
+
+	// Перезапуск сервера: counter восстановлен, новый Scraper не должен добавить значение агента ещё раз.
+	mu.Lock()
+	pollCount = 4
+	mu.Unlock()
+	scraper, err = NewScraper(repo, []string{agent.URL}, time.Second, zap.NewNop())
+	require.NoError(t, err)
+	scraper.SetToken("secret")
+	scrapeAndCheck(5, 1)
+}
+
+func TestNewScraper_Invalid(t *testing.T) {
+	_, err := NewScraper(repository.NewMemStorage(), []string{"ftp://agent"}, time.Second, zap.NewNop())
+	assert.ErrorIs(t, err, ErrInvalidTarget)
+
+	_, err = NewScraper(repository.NewMemStorage(), nil, 0, zap.NewNop())
+	assert.ErrorIs(t, err, ErrInvalidInterval)
+}
@@ -0,0 +1,236 @@
+package sketch
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+	"sort"
+)
+
+const (
+	DefaultRelativeAccuracy = 0.01
+	minIndexableValue       = 1e-9
+)
+
+var (
+	ErrIncompatible    = errors.New("sketches have different relative accuracy")
+	ErrInvalidValue    = errors.New("value must be a finite number")
+	ErrInvalidQuantile = errors.New("quantile must be in range [0, 1]")
+	ErrEmpty           = errors.New("sketch is empty")
+	ErrInvalidSketch   = errors.New("invalid sketch")
+)
+
+// DDSketch - объединяемый скетч с гарантированной относительной точностью квантилей.
+// Значения раскладываются по логарифмическим бакетам, поэтому скетчи с разных агентов
+// можно складывать без потери точности.
+type DDSketch struct {
+	RelativeAccuracy float64        `json:"relative_accuracy"`
+	Positive         map[int]uint64 `json:"positive,omitempty"`
+	Negative         map[int]uint64 `json:"negative,omitempty"`
+	Zero             uint64         `json:"zero,omitempty"`
+	Count            uint64         `json:"count"`
+	Sum              float64        `json:"sum"`
+	Min              float64        `json:"min"`
+	Max              float64        `json:"max"`
+}
+
+func NewDDSketch(relativeAccuracy float64) *DDSketch {
+	if relativeAccuracy <= 0 || relativeAccuracy >= 1 {
+		relativeAccuracy = DefaultRelativeAccuracy
+	}
+
+	return &DDSketch{
+		RelativeAccuracy: relativeAccuracy,
+		Positive:         make(map[int]uint64),
+		Negative:         make(map[int]uint64),
+	}
+}
+
+func (s *DDSketch) gamma() float64 {
+	return (1 + s.RelativeAccuracy) / (1 - s.RelativeAccuracy)
+}
+
+func (s *DDSketch) index(value float64) int {
+	return int(math.Ceil(math.Log(value) / math.Log(s.gamma())))
+}
+
+func (s *DDSketch) bucketValue(index int) float64 {
+	gamma := s.gamma()
+	return 2 * math.Pow(gamma, float64(index)) / (gamma + 1)
+}
+
+func (s *DDSketch) Add(value float64) error {
+	return s.AddN(value, 1)
+}
+
+func (s *DDSketch) AddN(value float64, n uint64) error {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return ErrInvalidValue
+	}
+	if n == 0 {
+		return nil
+	}
+
+	if s.Positive == nil {
+		s.Positive = make(map[int]uint64)
+	}
+	if s.Negative == nil {
+		s.Negative = make(map[int]uint64)
+	}
+
+	switch {
+	case value > minIndexableValue:
+		s.Positive[s.index(value)] += n
+	case value < -minIndexableValue:
+		s.Negative[s.index(-value)] += n
+	default:
+		s.Zero += n
+	}
+
+	if s.Count == 0 || value < s.Min {
+		s.Min = value
+	}
+	if s.Count == 0 || value > s.Max {
+		s.Max = value
+	}
+	s.Count += n
+	s.Sum += value * float64(n)
+
+	return nil
+}
+
+func (s *DDSketch) Merge(other *DDSketch) error {
+	if other == nil || other.Count == 0 {
+		return nil
+	}
+	if s.Count == 0 && len(s.Positive) == 0 && len(s.Negative) == 0 {
+		s.RelativeAccuracy = other.RelativeAccuracy
+	}
+	if s.RelativeAccuracy != other.RelativeAccuracy {
+		return ErrIncompatible
+	}
+
+	if s.Positive == nil {
+		s.Positive = make(map[int]uint64, len(other.Positive))
+	}
+	if s.Negative == nil {
+		s.Negative = make(map[int]uint64, len(other.Negative))
+	}
+
+	for k, v := range other.Positive {
+		s.Positive[k] += v
+	}
+	for k, v := range other.Negative {
+		s.Negative[k] += v
+	}
+	s.Zero += other.Zero
+
+	if s.Count == 0 || other.Min < s.Min {
+		s.Min = other.Min
+	}
+	if s.Count == 0 || other.Max > s.Max {
+		s.Max = other.Max
+	}
+	s.Count += other.Count
+	s.Sum += other.Sum
+
+	return nil
+}
+
+// Validate проверяет скетч, пришедший извне: точность в (0, 1), число наблюдений
+// равно сумме бакетов, сумма и границы конечны. Без этого испорченный скетч
+// сохранился бы и ломал все последующие слияния.
+func (s *DDSketch) Validate() error {
+	if s == nil {
+		return fmt.Errorf("%w: missing", ErrInvalidSketch)
+	}
+	if !(s.RelativeAccuracy > 0 && s.RelativeAccuracy < 1) {
+		return fmt.Errorf("%w: relative accuracy %v", ErrInvalidSketch, s.RelativeAccuracy)
+	}
+
+	total, carry := s.Zero, uint64(0)
+	for _, buckets := range []map[int]uint64{s.Positive, s.Negative} {
+		for _, v := range buckets {
+			var c uint64
+			total, c = bits.Add64(total, v, 0)
+			carry |= c
+		}
+	}
+	if carry != 0 || total != s.Count {
+		return fmt.Errorf("%w: count %d does not match buckets", ErrInvalidSketch, s.Count)
+	}
+
+	for _, v := range []float64{s.Sum, s.Min, s.Max} {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return fmt.Errorf("%w: %w", ErrInvalidSketch, ErrInvalidValue)
+		}
+	}
+	if s.Count > 0 && s.Min > s.Max {
+		return fmt.Errorf("%w: min is greater than max", ErrInvalidSketch)
+	}
+
+	return nil
+}
+
+func (s *DDSketch) Quantile(q float64) (float64, error) {
+	if q < 0 || q > 1 || math.IsNaN(q) {
+		return 0, ErrInvalidQuantile
+	}
+	if s.Count == 0 {
+		return 0, ErrEmpty
+	}
+
+	rank := q * float64(s.Count-1)
+	var cumulative float64
+
+	negative := sortedKeys(s.Negative)
+	for i := len(negative) - 1; i >= 0; i-- {
+		cumulative += float64(s.Negative[negative[i]])
+		if cumulative > rank {
+			return s.clamp(-s.bucketValue(negative[i])), nil
+		}
+	}
+
+	cumulative += float64(s.Zero)
+	if cumulative > rank {
+		return s.clamp(0), nil
+	}
+
+	for _, k := range sortedKeys(s.Positive) {
+		cumulative += float64(s.Positive[k])
+		if cumulative > rank {
+			return s.clamp(s.bucketValue(k)), nil
+		}
+	}
+
+	return s.Max, nil
+}
+
+func (s *DDSketch) clamp(value float64) float64 {
+	return math.Max(s.Min, math.Min(s.Max, value))
+}
+
+func (s *DDSketch) Copy() *DDSketch {
+	c := *s
+	c.Positive = make(map[int]uint64, len(s.Positive))
+	for k, v := range s.Positive {
+		c.Positive[k] = v
+	}
+	c.Negative = make(map[int]uint64, len(s.Negative))
+	for k, v := range s.Negative {
+		c.Negative[k] = v
+	}
+
+	return &c
+}
+
+func sortedKeys(m map[int]uint64) []int {
+	keys := make([]int, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+
+	return keys
+}
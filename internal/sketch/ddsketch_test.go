@@ -0,0 +1,109 @@
+package sketch
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math"
+	"testing"
+)
+
+func TestDDSketch_Quantile(t *testing.T) {
+	s := NewDDSketch(DefaultRelativeAccuracy)
+	for i := 1; i <= 1000; i++ {
+		require.NoError(t, s.Add(float64(i)))
+	}
+
+	tests := []struct {
+		name     string
+		quantile float64
+		expected float64
+	}{
+		{name: "Минимум", quantile: 0, expected: 1},
+		{name: "Медиана", quantile: 0.5, expected: 500},
+		{name: "p90", quantile: 0.9, expected: 900},
+		{name: "p99", quantile: 0.99, expected: 990},
+		{name: "Максимум", quantile: 1, expected: 1000},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			value, err := s.Quantile(test.quantile)
+			require.NoError(t, err)
+			assert.InEpsilon(t, test.expected, value, 2*DefaultRelativeAccuracy, "Квантиль вне допустимой погрешности")
+		})
+	}
+}
+
+func TestDDSketch_Merge(t *testing.T) {
+	a := NewDDSketch(DefaultRelativeAccuracy)
+	b := NewDDSketch(DefaultRelativeAccuracy)
+	for i := 1; i <= 500; i++ {
+		require.NoError(t, a.Add(float64(i)))
+		require.NoError(t, b.Add(float64(i+500)))
+	}
+
+	require.NoError(t, a.Merge(b))
+	assert.Equal(t, uint64(1000), a.Count)
+	assert.Equal(t, float64(1), a.Min)
+	assert.Equal(t, float64(1000), a.Max)
+
+	median, err := a.Quantile(0.5)
+	require.NoError(t, err)
+	assert.InEpsilon(t, 500, median, 2*DefaultRelativeAccuracy)
+
+	assert.ErrorIs(t, a.Merge(&DDSketch{RelativeAccuracy: 0.05, Count: 1}), ErrIncompatible)
+}
+
+func TestDDSketch_NegativeAndZero(t *testing.T) {
+	s := NewDDSketch(DefaultRelativeAccuracy)
+	for _, v := range []float64{-10, -1, 0, 1, 10} {
+		require.NoError(t, s.Add(v))
+	}
+
+	minValue, err := s.Quantile(0)
+	require.NoError(t, err)
+	assert.Equal(t, float64(-10), minValue)
+
+	median, err := s.Quantile(0.5)
+	require.NoError(t, err)
+	assert.Equal(t, float64(0), median)
+
+	assert.ErrorIs(t, s.Add(math.NaN()), ErrInvalidValue)
+	_, err = s.Quantile(1.5)
+	assert.ErrorIs(t, err, ErrInvalidQuantile)
+	_, err = NewDDSketch(DefaultRelativeAccuracy).Quantile(0.5)
+	assert.ErrorIs(t, err, ErrEmpty)
+}
+
+func TestDDSketch_Validate(t *testing.T) {
+	valid := NewDDSketch(DefaultRelativeAccuracy)
+	require.NoError(t, valid.Add(3))
+	require.NoError(t, valid.Add(0))
+
+	tests := []struct {
+		name    string
+		sketch  *DDSketch
+		wantErr bool
+	}{
+		{name: "Корректный", sketch: valid},
+		{name: "Пустой", sketch: NewDDSketch(DefaultRelativeAccuracy)},
+		{name: "nil", sketch: nil, wantErr: true},
+		{name: "Нулевая точность", sketch: &DDSketch{}, wantErr: true},
+		{name: "Точность NaN", sketch: &DDSketch{RelativeAccuracy: math.NaN()}, wantErr: true},
+		{name: "Точность 1", sketch: &DDSketch{RelativeAccuracy: 1}, wantErr: true},
+		{name: "Count не совпадает с бакетами", sketch: &DDSketch{RelativeAccuracy: 0.01, Positive: map[int]uint64{1: 2}, Count: 5}, wantErr: true},
+		{name: "Переполнение бакетов", sketch: &DDSketch{RelativeAccuracy: 0.01, Positive: map[int]uint64{1: math.MaxUint64, 2: 2}, Count: 1}, wantErr: true},
+		{name: "Бесконечная сумма", sketch: &DDSketch{RelativeAccuracy: 0.01, Zero: 1, Count: 1, Sum: math.Inf(1)}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.sketch.Validate()
+			if test.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidSketch)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
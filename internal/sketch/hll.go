@@ -0,0 +1,133 @@
+package sketch
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/bits"
+)
+
+const (
+	DefaultPrecision = 14
+	minPrecision     = 4
+	maxPrecision     = 18
+)
+
+// HyperLogLog оценивает число уникальных элементов, не храня сами элементы.
+// Регистры двух скетчей с одинаковой точностью объединяются взятием максимума.
+type HyperLogLog struct {
+	Precision uint8  `json:"precision"`
+	Registers []byte `json:"registers"`
+}
+
+func NewHyperLogLog(precision uint8) *HyperLogLog {
+	if precision < minPrecision || precision > maxPrecision {
+		precision = DefaultPrecision
+	}
+
+	return &HyperLogLog{
+		Precision: precision,
+		Registers: make([]byte, 1<<precision),
+	}
+}
+
+func (h *HyperLogLog) valid() bool {
+	return h.Precision >= minPrecision && h.Precision <= maxPrecision &&
+		len(h.Registers) == 1<<h.Precision
+}
+
+// Validate проверяет скетч, пришедший извне: точность в допустимых пределах, число
+// регистров 2^precision, и ни один регистр не больше возможной длины серии нулей.
+func (h *HyperLogLog) Validate() error {
+	if h == nil {
+		return fmt.Errorf("%w: missing", ErrInvalidSketch)
+	}
+	if !h.valid() {
+		return fmt.Errorf("%w: precision %d with %d registers", ErrInvalidSketch, h.Precision, len(h.Registers))
+	}
+
+	maxRho := 64 - h.Precision + 1
+	for _, r := range h.Registers {
+		if r > maxRho {
+			return fmt.Errorf("%w: register value %d", ErrInvalidSketch, r)
+		}
+	}
+
+	return nil
+}
+
+func (h *HyperLogLog) Add(member string) {
+	hash := hashMember(member)
+	idx := hash >> (64 - h.Precision)
+	w := hash<<h.Precision | 1<<(h.Precision-1)
+	rho := uint8(bits.LeadingZeros64(w)) + 1
+
+	if rho > h.Registers[idx] {
+		h.Registers[idx] = rho
+	}
+}
+
+func (h *HyperLogLog) Merge(other *HyperLogLog) error {
+	if other == nil {
+		return nil
+	}
+	if !other.valid() || !h.valid() || h.Precision != other.Precision {
+		return ErrIncompatible
+	}
+
+	for i, r := range other.Registers {
+		if r > h.Registers[i] {
+			h.Registers[i] = r
+		}
+	}
+
+	return nil
+}
+
+func (h *HyperLogLog) Estimate() uint64 {
+	m := float64(len(h.Registers))
+	if m == 0 {
+		return 0
+	}
+
+	var sum float64
+	var zeros int
+	for _, r := range h.Registers {
+		sum += math.Pow(2, -float64(r))
+		if r == 0 {
+			zeros++
+		}
+	}
+
+	alpha := 0.7213 / (1 + 1.079/m)
+	estimate := alpha * m * m / sum
+
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros))
+	}
+
+	return uint64(estimate + 0.5)
+}
+
+func (h *HyperLogLog) Copy() *HyperLogLog {
+	registers := make([]byte, len(h.Registers))
+	copy(registers, h.Registers)
+
+	return &HyperLogLog{Precision: h.Precision, Registers: registers}
+}
+
+// hashMember перемешивает FNV-1a финализатором splitmix64:
+// у голого FNV старшие биты плохо распределены для коротких строк.
+func hashMember(member string) uint64 {
+	f := fnv.New64a()
+	f.Write([]byte(member))
+
+	x := f.Sum64()
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+
+	return x
+}
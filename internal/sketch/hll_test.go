@@ -0,0 +1,68 @@
+package sketch
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strconv"
+	"testing"
+)
+
+func TestHyperLogLog_Estimate(t *testing.T) {
+	tests := []struct {
+		name        string
+		cardinality int
+	}{
+		{name: "Малое множество", cardinality: 100},
+		{name: "Среднее множество", cardinality: 10000},
+		{name: "Большое множество", cardinality: 200000},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := NewHyperLogLog(DefaultPrecision)
+			for i := 0; i < test.cardinality; i++ {
+				h.Add("user-" + strconv.Itoa(i))
+				h.Add("user-" + strconv.Itoa(i))
+			}
+
+			assert.InEpsilon(t, test.cardinality, h.Estimate(), 0.03, "Оценка вне допустимой погрешности")
+		})
+	}
+}
+
+func TestHyperLogLog_Merge(t *testing.T) {
+	a := NewHyperLogLog(DefaultPrecision)
+	b := NewHyperLogLog(DefaultPrecision)
+	for i := 0; i < 5000; i++ {
+		a.Add("ip-" + strconv.Itoa(i))
+		b.Add("ip-" + strconv.Itoa(i+2500))
+	}
+
+	require.NoError(t, a.Merge(b))
+	assert.InEpsilon(t, 7500, a.Estimate(), 0.03)
+
+	assert.ErrorIs(t, a.Merge(NewHyperLogLog(10)), ErrIncompatible)
+	assert.ErrorIs(t, a.Merge(&HyperLogLog{Precision: DefaultPrecision}), ErrIncompatible)
+}
+
+func TestHyperLogLog_Validate(t *testing.T) {
+	h := NewHyperLogLog(DefaultPrecision)
+	h.Add("user")
+	assert.NoError(t, h.Validate())
+
+	tests := []struct {
+		name string
+		hll  *HyperLogLog
+	}{
+		{name: "nil", hll: nil},
+		{name: "Точность вне пределов", hll: &HyperLogLog{Precision: 30, Registers: make([]byte, 16)}},
+		{name: "Число регистров не 2^precision", hll: &HyperLogLog{Precision: 4, Registers: make([]byte, 15)}},
+		{name: "Слишком большой регистр", hll: &HyperLogLog{Precision: 4, Registers: append(make([]byte, 15), 100)}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.ErrorIs(t, test.hll.Validate(), ErrInvalidSketch)
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package stream
+
+import (
+	"github.com/Guram-Gurych/metricserver.git/internal/model"
+	"path"
+	"sync"
+	"sync/atomic"
+)
+
+const DefaultBufferSize = 256
+
+const (
+	// OpUpdate - метрика создана или изменена, в событии её текущее значение.
+	OpUpdate = "update"
+	// OpDelete - метрика удалена.
+	OpDelete = "delete"
+	// OpResync - хранилище изменилось целиком (Restore, вытеснение устаревших),
+	// подписчику нужно заново прочитать метрики через /api/v1/metrics.
+	OpResync = "resync"
+)
+
+type Event struct {
+	Op     string          `json:"op"`
+	Metric *models.Metrics `json:"metric,omitempty"`
+}
+
+// Filter отбирает события для подписчика. Пустые поля не фильтруют.
+type Filter struct {
+	Type  string
+	Match string
+}
+
+func (f Filter) Valid() bool {
+	if f.Match == "" {
+		return true
+	}
+	_, err := path.Match(f.Match, "")
+	return err == nil
+}
+
+func (f Filter) matches(e Event) bool {
+	if e.Metric == nil {
+		return true
+	}
+	if f.Type != "" && f.Type != e.Metric.MType {
+		return false
+	}
+	if f.Match != "" {
+		matched, _ := path.Match(f.Match, e.Metric.ID)
+		return matched
+	}
+
+	return true
+}
+
+// Hub раздаёт события подписчикам. Publish никогда не блокируется: если буфер
+// подписчика заполнен, событие для него отбрасывается и учитывается в Dropped.
+type Hub struct {
+	mu          sync.RWMutex
+	subscribers map[*Subscription]struct{}
+	bufferSize  int
+}
+
+func NewHub(bufferSize int) *Hub {
+	if bufferSize <= 0 {
+		bufferSize = DefaultBufferSize
+	}
+
+	return &Hub{subscribers: make(map[*Subscription]struct{}), bufferSize: bufferSize}
+}
+
+type Subscription struct {
+	hub     *Hub
+	filter  Filter
+	events  chan Event
+	dropped atomic.Uint64
+	once    sync.Once
+}
+
+func (h *Hub) Subscribe(filter Filter) *Subscription {
+	sub := &Subscription{hub: h, filter: filter, events: make(chan Event, h.bufferSize)}
+
+	h.mu.Lock()
+	h.subscribers[sub] = struct{}{}
+	h.mu.Unlock()
+
+	return sub
+}
+
+func (h *Hub) Publish(e Event) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for sub := range h.subscribers {
+		if !sub.filter.matches(e) {
+			continue
+		}
+
+		select {
+		case sub.events <- e:
+		default:
+			sub.dropped.Add(1)
+		}
+	}
+}
+
+func (h *Hub) Subscribers() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return len(h.subscribers)
+}
+
+// Events закрывается после Close.
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Dropped возвращает число событий, отброшенных с прошлого вызова, и обнуляет счётчик.
+// Если оно больше нуля, клиент пропустил обновления и должен перечитать состояние.
+func (s *Subscription) Dropped() uint64 {
+	return s.dropped.Swap(0)
+}
+
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		s.hub.mu.Lock()
+		delete(s.hub.subscribers, s)
+		s.hub.mu.Unlock()
+
+		close(s.events)
+	})
+}
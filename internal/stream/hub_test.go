@@ -0,0 +1,64 @@
+package stream
+
+import (
+	"context"
+	"github.com/Guram-Gurych/metricserver.git/internal/model"
+	"github.com/Guram-Gurych/metricserver.git/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestHub_Filter(t *testing.T) {
+	hub := NewHub(8)
+	sub := hub.Subscribe(Filter{Type: models.Gauge, Match: "Heap*"})
+	defer sub.Close()
+
+	hub.Publish(Event{Op: OpUpdate, Metric: &models.Metrics{ID: "HeapAlloc", MType: models.Gauge}})
+	hub.Publish(Event{Op: OpUpdate, Metric: &models.Metrics{ID: "Alloc", MType: models.Gauge}})
+	hub.Publish(Event{Op: OpUpdate, Metric: &models.Metrics{ID: "HeapAlloc", MType: models.Counter}})
+	hub.Publish(Event{Op: OpResync})
+
+	require.Len(t, sub.Events(), 2)
+	assert.Equal(t, "HeapAlloc", (<-sub.Events()).Metric.ID)
+	assert.Equal(t, OpResync, (<-sub.Events()).Op, "Resync должен доходить до всех подписчиков")
+}
+
+func TestHub_SlowSubscriber(t *testing.T) {
+	hub := NewHub(2)
+	slow := hub.Subscribe(Filter{})
+	fast := hub.Subscribe(Filter{})
+
+	for i := 0; i < 5; i++ {
+		hub.Publish(Event{Op: OpResync})
+		<-fast.Events()
+	}
+
+	assert.Len(t, slow.Events(), 2)
+	assert.Equal(t, uint64(3), slow.Dropped(), "Лишние события должны отбрасываться, а не блокировать Publish")
+	assert.Equal(t, uint64(0), slow.Dropped())
+	assert.Equal(t, uint64(0), fast.Dropped())
+
+	slow.Close()
+	slow.Close()
+	assert.Equal(t, 1, hub.Subscribers())
+	fast.Close()
+}
+
+func TestPublishingStorage(t *testing.T) {
+	ctx := context.Background()
+	hub := NewHub(8)
+	storage := NewPublishingStorage(repository.NewMemStorage(), hub)
+	sub := hub.Subscribe(Filter{})
+	defer sub.Close()
+
+	require.NoError(t, storage.UpdateCounter(ctx, "PollCount", 2))
+	require.NoError(t, storage.UpdateCounter(ctx, "PollCount", 3))
+	require.NoError(t, storage.DeleteCounter(ctx, "PollCount"))
+	assert.ErrorIs(t, storage.DeleteCounter(ctx, "PollCount"), repository.ErrNotFound)
+
+	require.Len(t, sub.Events(), 3, "Неудачные записи не должны публиковаться")
+	assert.Equal(t, int64(2), *(<-sub.Events()).Metric.Delta)
+	assert.Equal(t, int64(5), *(<-sub.Events()).Metric.Delta, "В событии должно быть итоговое значение counter")
+	assert.Equal(t, OpDelete, (<-sub.Events()).Op)
+}
@@ -0,0 +1,215 @@
+package stream
+
+import (
+	"context"
+	"github.com/Guram-Gurych/metricserver.git/internal/model"
+	"github.com/Guram-Gurych/metricserver.git/internal/repository"
+	"github.com/Guram-Gurych/metricserver.git/internal/sketch"
+	"time"
+)
+
+const summaryQuantile = 0.5
+
+// PublishingStorage публикует в Hub каждое успешное изменение хранилища.
+// Чтения проходят к хранилищу напрямую.
+type PublishingStorage struct {
+	repo repository.MetricRepository
+	hub  *Hub
+}
+
+func NewPublishingStorage(repo repository.MetricRepository, hub *Hub) *PublishingStorage {
+	return &PublishingStorage{repo: repo, hub: hub}
+}
+
+// publishUpdate читает значение метрики после записи: для counter, summary и set
+// подписчику нужно итоговое значение, а не присланное приращение.
+func (ps *PublishingStorage) publishUpdate(ctx context.Context, metricType, name string) {
+	if ps.hub.Subscribers() == 0 {
+		return
+	}
+
+	m := models.Metrics{ID: name, MType: metricType}
+	switch metricType {
+	case models.Gauge:
+		value, err := ps.repo.GetGauge(ctx, name)
+		if err != nil {
+			return
+		}
+		m.Value = &value
+	case models.Counter:
+		delta, err := ps.repo.GetCounter(ctx, name)
+		if err != nil {
+			return
+		}
+		m.Delta = &delta
+	case models.Summary:
+		s, err := ps.repo.GetSummary(ctx, name)
+		if err != nil {
+			return
+		}
+		value, _ := s.Quantile(summaryQuantile)
+		count := int64(s.Count)
+		q := summaryQuantile
+		m.Value, m.Delta, m.Quantile = &value, &count, &q
+	case models.Set:
+		s, err := ps.repo.GetSet(ctx, name)
+		if err != nil {
+			return
+		}
+		cardinality := int64(s.Estimate())
+		m.Delta = &cardinality
+	}
+
+	now := time.Now()
+	m.UpdatedAt = &now
+	ps.hub.Publish(Event{Op: OpUpdate, Metric: &m})
+}
+
+func (ps *PublishingStorage) publishDelete(metricType, name string) {
+	ps.hub.Publish(Event{Op: OpDelete, Metric: &models.Metrics{ID: name, MType: metricType}})
+}
+
+func (ps *PublishingStorage) UpdateGauge(ctx context.Context, name string, value float64) error {
+	if err := ps.repo.UpdateGauge(ctx, name, value); err != nil {
+		return err
+	}
+	ps.publishUpdate(ctx, models.Gauge, name)
+	return nil
+}
+
+func (ps *PublishingStorage) AddGauge(ctx context.Context, name string, delta float64) (float64, error) {
+	value, err := ps.repo.AddGauge(ctx, name, delta)
+	if err != nil {
+		return value, err
+	}
+	ps.publishUpdate(ctx, models.Gauge, name)
+	return value, nil
+}
+
+func (ps *PublishingStorage) UpdateCounter(ctx context.Context, name string, value int64) error {
+	if err := ps.repo.UpdateCounter(ctx, name, value); err != nil {
+		return err
+	}
+	ps.publishUpdate(ctx, models.Counter, name)
+	return nil
+}
+
+func (ps *PublishingStorage) SetCounter(ctx context.Context, name string, value int64) error {
+	if err := ps.repo.SetCounter(ctx, name, value); err != nil {
+		return err
+	}
+	ps.publishUpdate(ctx, models.Counter, name)
+	return nil
+}
+
+func (ps *PublishingStorage) UpdateSummary(ctx context.Context, name string, value *sketch.DDSketch) error {
+	if err := ps.repo.UpdateSummary(ctx, name, value); err != nil {
+		return err
+	}
+	ps.publishUpdate(ctx, models.Summary, name)
+	return nil
+}
+
+func (ps *PublishingStorage) UpdateSet(ctx context.Context, name string, value *sketch.HyperLogLog) error {
+	if err := ps.repo.UpdateSet(ctx, name, value); err != nil {
+		return err
+	}
+	ps.publishUpdate(ctx, models.Set, name)
+	return nil
+}
+
+func (ps *PublishingStorage) DeleteGauge(ctx context.Context, name string) error {
+	if err := ps.repo.DeleteGauge(ctx, name); err != nil {
+		return err
+	}
+	ps.publishDelete(models.Gauge, name)
+	return nil
+}
+
+func (ps *PublishingStorage) DeleteCounter(ctx context.Context, name string) error {
+	if err := ps.repo.DeleteCounter(ctx, name); err != nil {
+		return err
+	}
+	ps.publishDelete(models.Counter, name)
+	return nil
+}
+
+func (ps *PublishingStorage) ResetCounter(ctx context.Context, name string) error {
+	if err := ps.repo.ResetCounter(ctx, name); err != nil {
+		return err
+	}
+	ps.publishUpdate(ctx, models.Counter, name)
+	return nil
+}
+
+func (ps *PublishingStorage) DeleteSummary(ctx context.Context, name string) error {
+	if err := ps.repo.DeleteSummary(ctx, name); err != nil {
+		return err
+	}
+	ps.publishDelete(models.Summary, name)
+	return nil
+}
+
+func (ps *PublishingStorage) DeleteSet(ctx context.Context, name string) error {
+	if err := ps.repo.DeleteSet(ctx, name); err != nil {
+		return err
+	}
+	ps.publishDelete(models.Set, name)
+	return nil
+}
+
+func (ps *PublishingStorage) EvictStale(ctx context.Context, before time.Time) (int, error) {
+	evicted, err := ps.repo.EvictStale(ctx, before)
+	if evicted > 0 {
+		ps.hub.Publish(Event{Op: OpResync})
+	}
+	return evicted, err
+}
+
+func (ps *PublishingStorage) Restore(ctx context.Context, snapshot repository.Snapshot, mode repository.RestoreMode) error {
+	if err := ps.repo.Restore(ctx, snapshot, mode); err != nil {
+		return err
+	}
+	ps.hub.Publish(Event{Op: OpResync})
+	return nil
+}
+
+func (ps *PublishingStorage) GetGauge(ctx context.Context, name string) (float64, error) {
+	return ps.repo.GetGauge(ctx, name)
+}
+
+func (ps *PublishingStorage) GetCounter(ctx context.Context, name string) (int64, error) {
+	return ps.repo.GetCounter(ctx, name)
+}
+
+func (ps *PublishingStorage) GetAllGauges(ctx context.Context) (map[string]float64, error) {
+	return ps.repo.GetAllGauges(ctx)
+}
+
+func (ps *PublishingStorage) GetAllCounters(ctx context.Context) (map[string]int64, error) {
+	return ps.repo.GetAllCounters(ctx)
+}
+
+func (ps *PublishingStorage) GetSummary(ctx context.Context, name string) (*sketch.DDSketch, error) {
+	return ps.repo.GetSummary(ctx, name)
+}
+
+func (ps *PublishingStorage) GetAllSummaries(ctx context.Context) (map[string]*sketch.DDSketch, error) {
+	return ps.repo.GetAllSummaries(ctx)
+}
+
+func (ps *PublishingStorage) GetSet(ctx context.Context, name string) (*sketch.HyperLogLog, error) {
+	return ps.repo.GetSet(ctx, name)
+}
+
+func (ps *PublishingStorage) GetAllSets(ctx context.Context) (map[string]*sketch.HyperLogLog, error) {
+	return ps.repo.GetAllSets(ctx)
+}
+
+func (ps *PublishingStorage) GetUpdatedAt(ctx context.Context, metricType, name string) (time.Time, error) {
+	return ps.repo.GetUpdatedAt(ctx, metricType, name)
+}
+
+func (ps *PublishingStorage) List(ctx context.Context, opts repository.ListOptions) (*repository.ListPage, error) {
+	return ps.repo.List(ctx, opts)
+}
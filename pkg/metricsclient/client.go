@@ -0,0 +1,363 @@
+// Package metricsclient отправляет метрики приложения на metricserver.
+//
+// Значения копятся локально в типизированных хэндлах и раз в интервал уходят одним
+// пакетом на /updates/: для gauge отправляется последнее значение, для counter - сумма
+// приращений с прошлой отправки.
+//
+//	client, err := metricsclient.New("localhost:8080", metricsclient.WithToken(token))
+//	if err != nil { ... }
+//	defer client.Close(context.Background())
+//
+//	requests := client.Counter("http_requests_total")
+//	requests.Inc()
+//	client.Gauge("queue_length").Set(float64(len(queue)))
+package metricsclient
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Guram-Gurych/metricserver.git/internal/model"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultFlushInterval = 10 * time.Second
+	DefaultRetries       = 3
+	DefaultRetryBackoff  = time.Second
+
+	batchIDHeader   = "X-Batch-ID"
+	signatureHeader = "HashSHA256"
+)
+
+var (
+	ErrClosed         = errors.New("metricsclient: client is closed")
+	ErrInvalidAddress = errors.New("metricsclient: invalid server address")
+)
+
+// StatusError - ответ сервера с кодом, отличным от 200.
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("metricsclient: server responded %d: %s", e.StatusCode, e.Body)
+}
+
+// retryable - стоит ли повторять запрос: 4xx, кроме 429, повтор не исправит.
+func (e *StatusError) retryable() bool {
+	return e.StatusCode >= 500 || e.StatusCode == http.StatusTooManyRequests
+}
+
+// Client копит метрики и отправляет их в фоне. Методы безопасны для конкурентного вызова.
+type Client struct {
+	url           string
+	httpClient    *http.Client
+	token         string
+	key           []byte
+	flushInterval time.Duration
+	retries       int
+	retryBackoff  time.Duration
+	onError       func(error)
+
+	mu       sync.Mutex
+	gauges   map[string]*Gauge
+	counters map[string]*Counter
+	closed   bool
+
+	// sendMu не даёт фоновой и ручной отправке идти одновременно.
+	sendMu sync.Mutex
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+type Option func(*Client)
+
+// WithToken задаёт токен записи сервера (-write-token).
+func WithToken(token string) Option {
+	return func(c *Client) { c.token = token }
+}
+
+// WithSigningKey включает подпись тела запроса HMAC-SHA256 ключом сервера (-k).
+func WithSigningKey(key string) Option {
+	return func(c *Client) { c.key = []byte(key) }
+}
+
+// WithFlushInterval задаёт период фоновой отправки.
+func WithFlushInterval(d time.Duration) Option {
+	return func(c *Client) { c.flushInterval = d }
+}
+
+// WithRetries задаёт число повторов неудачной отправки и начальную задержку,
+// которая удваивается с каждой попыткой.
+func WithRetries(retries int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.retries = retries
+		c.retryBackoff = backoff
+	}
+}
+
+// WithHTTPClient заменяет HTTP-клиент, например чтобы задать TLS или таймаут.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) { c.httpClient = httpClient }
+}
+
+// WithErrorHandler получает ошибки фоновой отправки; по умолчанию они игнорируются.
+func WithErrorHandler(fn func(error)) Option {
+	return func(c *Client) { c.onError = fn }
+}
+
+// New создаёт клиент для сервера addr (host:port или URL) и запускает фоновую отправку.
+func New(addr string, opts ...Option) (*Client, error) {
+	if !strings.Contains(addr, "://") {
+		addr = "http://" + addr
+	}
+	u, err := url.Parse(addr)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidAddress, addr)
+	}
+
+	c := &Client{
+		url:           strings.TrimSuffix(u.String(), "/") + "/updates/",
+		httpClient:    &http.Client{Timeout: 10 * time.Second},
+		flushInterval: DefaultFlushInterval,
+		retries:       DefaultRetries,
+		retryBackoff:  DefaultRetryBackoff,
+		onError:       func(error) {},
+		gauges:        make(map[string]*Gauge),
+		counters:      make(map[string]*Counter),
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.flushInterval <= 0 {
+		return nil, errors.New("metricsclient: flush interval must be positive")
+	}
+
+	go c.run()
+
+	return c, nil
+}
+
+// Gauge возвращает хэндл gauge с именем name; повторный вызов вернёт тот же хэндл.
+func (c *Client) Gauge(name string) *Gauge {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	g, ok := c.gauges[name]
+	if !ok {
+		g = &Gauge{name: name}
+		c.gauges[name] = g
+	}
+	return g
+}
+
+// Counter возвращает хэндл counter с именем name; повторный вызов вернёт тот же хэндл.
+func (c *Client) Counter(name string) *Counter {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ctr, ok := c.counters[name]
+	if !ok {
+		ctr = &Counter{name: name}
+		c.counters[name] = ctr
+	}
+	return ctr
+}
+
+// Flush сразу отправляет накопленное. Если отправить не удалось, приращения counter-ов
+// и ещё не перезаписанные gauge возвращаются в хэндлы и уйдут со следующим пакетом.
+func (c *Client) Flush(ctx context.Context) error {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+
+	c.mu.Lock()
+	gauges := make([]*Gauge, 0, len(c.gauges))
+	for _, g := range c.gauges {
+		gauges = append(gauges, g)
+	}
+	counters := make([]*Counter, 0, len(c.counters))
+	for _, ctr := range c.counters {
+		counters = append(counters, ctr)
+	}
+	c.mu.Unlock()
+
+	var batch []models.Metrics
+	takenGauges := make(map[*Gauge]float64)
+	takenCounters := make(map[*Counter]int64)
+	for _, g := range gauges {
+		if value, ok := g.take(); ok {
+			batch = append(batch, models.Metrics{ID: g.name, MType: models.Gauge, Value: &value})
+			takenGauges[g] = value
+		}
+	}
+	for _, ctr := range counters {
+		if delta := ctr.take(); delta != 0 {
+			batch = append(batch, models.Metrics{ID: ctr.name, MType: models.Counter, Delta: &delta})
+			takenCounters[ctr] = delta
+		}
+	}
+	if len(batch) == 0 {
+		return nil
+	}
+	sort.Slice(batch, func(i, j int) bool { return batch[i].ID < batch[j].ID })
+
+	if err := c.send(ctx, batch); err != nil {
+		for g, value := range takenGauges {
+			g.restore(value)
+		}
+		for ctr, delta := range takenCounters {
+			ctr.Add(delta)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// Close останавливает фоновую отправку и отправляет остаток. После Close хэндлы
+// продолжают принимать значения, но отправлять их уже некому.
+func (c *Client) Close(ctx context.Context) error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return ErrClosed
+	}
+	c.closed = true
+	c.mu.Unlock()
+
+	close(c.stop)
+	select {
+	case <-c.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	return c.Flush(ctx)
+}
+
+// run отправляет накопленное раз в flushInterval. Close прерывает текущую отправку:
+// её данные вернутся в хэндлы и уйдут с последним пакетом из Close.
+func (c *Client) run() {
+	defer close(c.done)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-c.stop
+		cancel()
+	}()
+
+	ticker := time.NewTicker(c.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.Flush(ctx); err != nil && ctx.Err() == nil {
+				c.onError(err)
+			}
+		}
+	}
+}
+
+// send отправляет пакет, повторяя его с тем же X-Batch-ID: если ответ потерялся,
+// сервер узнает повтор и не учтёт counter-ы дважды.
+func (c *Client) send(ctx context.Context, batch []models.Metrics) error {
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return err
+	}
+
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	if _, err := zw.Write(body); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	var signature string
+	if len(c.key) > 0 {
+		mac := hmac.New(sha256.New, c.key)
+		mac.Write(body)
+		signature = hex.EncodeToString(mac.Sum(nil))
+	}
+
+	batchID, err := newBatchID()
+	if err != nil {
+		return err
+	}
+
+	backoff := c.retryBackoff
+	for attempt := 0; ; attempt++ {
+		err = c.post(ctx, compressed.Bytes(), batchID, signature)
+		var statusErr *StatusError
+		if err == nil || attempt >= c.retries || (errors.As(err, &statusErr) && !statusErr.retryable()) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (c *Client) post(ctx context.Context, body []byte, batchID, signature string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Encoding", "gzip")
+	req.Header.Set(batchIDHeader, batchID)
+	if signature != "" {
+		req.Header.Set(signatureHeader, signature)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return &StatusError{StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(msg))}
+	}
+
+	return nil
+}
+
+func newBatchID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,139 @@
+package metricsclient
+
+import (
+	"context"
+	"github.com/Guram-Gurych/metricserver.git/internal/handler"
+	"github.com/Guram-Gurych/metricserver.git/internal/middleware"
+	"github.com/Guram-Gurych/metricserver.git/internal/repository"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newServer поднимает настоящий /updates/ с токеном и проверкой подписи.
+func newServer(t *testing.T, wrap func(http.Handler) http.Handler) (*httptest.Server, repository.MetricRepository) {
+	repo := repository.NewMemStorage()
+	h := handler.NewMetricHandler(repo, nil)
+
+	r := chi.NewRouter()
+	r.Use(middleware.GzipMiddleware)
+	r.Use(middleware.WriteAuth("token"))
+	r.Use(middleware.VerifySignature("key", 1<<20))
+	r.Post("/updates/", h.PostBatch)
+
+	var root http.Handler = r
+	if wrap != nil {
+		root = wrap(r)
+	}
+	srv := httptest.NewServer(root)
+	t.Cleanup(srv.Close)
+
+	return srv, repo
+}
+
+func TestClient_Flush(t *testing.T) {
+	srv, repo := newServer(t, nil)
+	c, err := New(srv.URL, WithToken("token"), WithSigningKey("key"), WithFlushInterval(time.Hour))
+	require.NoError(t, err)
+	defer c.Close(context.Background())
+
+	requests := c.Counter("requests")
+	requests.Inc()
+	requests.Add(4)
+	c.Gauge("queue").Set(1)
+	c.Gauge("queue").Set(7)
+
+	require.NoError(t, c.Flush(t.Context()))
+	require.NoError(t, c.Flush(t.Context()), "Пустая отправка не должна быть ошибкой")
+
+	counter, err := repo.GetCounter(t.Context(), "requests")
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), counter, "Приращения counter-а должны суммироваться локально")
+	gauge, err := repo.GetGauge(t.Context(), "queue")
+	require.NoError(t, err)
+	assert.Equal(t, float64(7), gauge, "Для gauge отправляется последнее значение")
+}
+
+func TestClient_Retry(t *testing.T) {
+	var mu sync.Mutex
+	var batchIDs []string
+	failures := 1
+	srv, repo := newServer(t, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			batchIDs = append(batchIDs, r.Header.Get(batchIDHeader))
+			fail := failures > 0
+			failures--
+			mu.Unlock()
+
+			// Сервер применяет пакет, но ответ теряется: повтор не должен учесть его дважды.
+			next.ServeHTTP(w, r)
+			if fail {
+				panic(http.ErrAbortHandler)
+			}
+		})
+	})
+
+	c, err := New(srv.URL, WithToken("token"), WithSigningKey("key"), WithFlushInterval(time.Hour), WithRetries(2, time.Millisecond))
+	require.NoError(t, err)
+	defer c.Close(context.Background())
+
+	c.Counter("requests").Add(3)
+	require.NoError(t, c.Flush(t.Context()))
+
+	counter, err := repo.GetCounter(t.Context(), "requests")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), counter)
+	require.Len(t, batchIDs, 2)
+	assert.Equal(t, batchIDs[0], batchIDs[1], "Повтор должен идти с тем же X-Batch-ID")
+}
+
+func TestClient_FlushFailureKeepsValues(t *testing.T) {
+	srv, repo := newServer(t, nil)
+	c, err := New(srv.URL, WithToken("wrong"), WithSigningKey("key"), WithFlushInterval(time.Hour), WithRetries(3, time.Millisecond))
+	require.NoError(t, err)
+	defer c.Close(context.Background())
+
+	c.Counter("requests").Add(2)
+	c.Gauge("queue").Set(1)
+
+	err = c.Flush(t.Context())
+	var statusErr *StatusError
+	require.ErrorAs(t, err, &statusErr)
+	assert.Equal(t, http.StatusUnauthorized, statusErr.StatusCode)
+
+	c.Counter("requests").Add(1)
+	c.token = "token"
+	require.NoError(t, c.Flush(t.Context()))
+
+	counter, err := repo.GetCounter(t.Context(), "requests")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), counter, "Неотправленные приращения не должны теряться")
+	gauge, err := repo.GetGauge(t.Context(), "queue")
+	require.NoError(t, err)
+	assert.Equal(t, float64(1), gauge)
+}
+
+func TestClient_Close(t *testing.T) {
+	srv, repo := newServer(t, nil)
+	c, err := New(srv.URL, WithToken("token"), WithSigningKey("key"), WithFlushInterval(time.Hour))
+	require.NoError(t, err)
+
+	c.Counter("requests").Inc()
+	require.NoError(t, c.Close(t.Context()))
+	assert.ErrorIs(t, c.Close(t.Context()), ErrClosed)
+
+	counter, err := repo.GetCounter(t.Context(), "requests")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), counter, "Close должен отправить остаток")
+}
+
+func TestNew_InvalidAddress(t *testing.T) {
+	_, err := New("http://")
+	assert.ErrorIs(t, err, ErrInvalidAddress)
+}
@@ -0,0 +1,79 @@
+package metricsclient
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+)
+
+// Gauge - метрика, для которой сервер хранит последнее значение.
+type Gauge struct {
+	name string
+
+	mu    sync.Mutex
+	value float64
+	// dirty - значение изменилось с прошлой отправки.
+	dirty bool
+}
+
+func (g *Gauge) Name() string {
+	return g.name
+}
+
+// Set запоминает значение до следующей отправки. NaN и бесконечности JSON не передаёт,
+// поэтому они пропускаются.
+func (g *Gauge) Set(value float64) {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.value = value
+	g.dirty = true
+}
+
+func (g *Gauge) take() (float64, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if !g.dirty {
+		return 0, false
+	}
+	g.dirty = false
+	return g.value, true
+}
+
+// restore возвращает неотправленное значение, если за время отправки не пришло новое.
+func (g *Gauge) restore(value float64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if !g.dirty {
+		g.value = value
+		g.dirty = true
+	}
+}
+
+// Counter - метрика, которую сервер увеличивает на присланное приращение.
+type Counter struct {
+	name    string
+	pending atomic.Int64
+}
+
+func (c *Counter) Name() string {
+	return c.name
+}
+
+func (c *Counter) Add(delta int64) {
+	c.pending.Add(delta)
+}
+
+func (c *Counter) Inc() {
+	c.pending.Add(1)
+}
+
+func (c *Counter) take() int64 {
+	return c.pending.Swap(0)
+}